@@ -13,14 +13,52 @@ import (
 	"github.com/prachin77/insight-hub/models"
 	"github.com/prachin77/insight-hub/proto/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type server struct {
 	pb.UnimplementedMessagingServiceServer
-	clients sync.Map // Map[UserID]chan *pb.Message
+	clients sync.Map // Map[UserID]*clientStream, the stream messages are delivered to
+	streams sync.Map // Map[*clientStream]UserID, every open stream including superseded ones
+}
+
+// clientStream is a connected user's message channel plus a signal that ends their stream early.
+type clientStream struct {
+	messages  chan *pb.Message
+	kicked    chan struct{}
+	closeOnce sync.Once
+}
+
+func (cs *clientStream) kick() {
+	cs.closeOnce.Do(func() { close(cs.kicked) })
+}
+
+// messaging is the server StartServer registers, kept so the HTTP side can act on open streams.
+var messaging = &server{}
+
+// DisconnectUser ends all of the user's open message streams. The chat WebSockets bridged
+// to them close as a result.
+func DisconnectUser(userID string) {
+	messaging.streams.Range(func(key, value interface{}) bool {
+		if value.(string) == userID {
+			key.(*clientStream).kick()
+		}
+		return true
+	})
+}
+
+// activeSuspension reports whether the user is currently suspended.
+func activeSuspension(ctx context.Context, userID string) bool {
+	user, err := db.GetUserByID(ctx, userID)
+	return err == nil && user.Suspension.Active(time.Now())
 }
 
 func (s *server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*pb.SendMessageResponse, error) {
+	if activeSuspension(ctx, req.SenderId) {
+		return nil, status.Error(codes.PermissionDenied, "your account has been suspended")
+	}
+
 	msgID := uuid.New().String()
 	timestamp := time.Now()
 
@@ -64,7 +102,7 @@ func (s *server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*
 
 	if val, ok := s.clients.Load(req.ReceiverId); ok {
 		log.Printf("Receiver %s is online, broadcasting message", req.ReceiverId)
-		ch := val.(chan *pb.Message)
+		ch := val.(*clientStream).messages
 		// Non-blocking send
 		select {
 		case ch <- pbMsg:
@@ -87,9 +125,17 @@ func (s *server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*
 }
 
 func (s *server) StreamMessages(req *pb.StreamMessagesRequest, stream pb.MessagingService_StreamMessagesServer) error {
-	msgChan := make(chan *pb.Message, 100)
-	s.clients.Store(req.UserId, msgChan)
-	defer s.clients.Delete(req.UserId)
+	if activeSuspension(stream.Context(), req.UserId) {
+		return status.Error(codes.PermissionDenied, "your account has been suspended")
+	}
+
+	cs := &clientStream{messages: make(chan *pb.Message, 100), kicked: make(chan struct{})}
+	s.clients.Store(req.UserId, cs)
+	s.streams.Store(cs, req.UserId)
+	defer func() {
+		s.clients.CompareAndDelete(req.UserId, cs)
+		s.streams.Delete(cs)
+	}()
 
 	log.Printf("User %s connected to message stream", req.UserId)
 
@@ -98,10 +144,14 @@ func (s *server) StreamMessages(req *pb.StreamMessagesRequest, stream pb.Messagi
 
 	for {
 		select {
-		case msg := <-msgChan:
+		case msg := <-cs.messages:
 			if err := stream.Send(msg); err != nil {
 				return err
 			}
+		case <-cs.kicked:
+			log.Printf("User %s was disconnected from message stream", req.UserId)
+			db.UpdateLastSeen(context.Background(), req.UserId)
+			return status.Error(codes.Unavailable, "stream closed by server")
 		case <-stream.Context().Done():
 			log.Printf("User %s disconnected from message stream", req.UserId)
 			// Update LastSeen in DB when they disconnect
@@ -165,7 +215,7 @@ func StartServer(port int) {
 		log.Fatalf("failed to listen: %v", err)
 	}
 	s := grpc.NewServer()
-	pb.RegisterMessagingServiceServer(s, &server{})
+	pb.RegisterMessagingServiceServer(s, messaging)
 	log.Printf("🚀 gRPC Messaging Server starting on %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
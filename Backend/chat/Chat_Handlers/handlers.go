@@ -1,18 +1,23 @@
 package chat_handlers
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
 	"github.com/prachin77/insight-hub/models"
 	"github.com/prachin77/insight-hub/proto/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 var upgrader = websocket.Upgrader{
@@ -31,6 +36,50 @@ var wsHub = struct {
 	conns map[string]*websocket.Conn
 }{conns: make(map[string]*websocket.Conn)}
 
+// wsStreams tracks open chat WebSockets with the user and the session or personal access token
+// that opened them, so signing a session out or revoking a token can close its sockets.
+var wsStreams = struct {
+	sync.Mutex
+	conns map[*websocket.Conn]wsStream
+}{conns: make(map[*websocket.Conn]wsStream)}
+
+type wsStream struct {
+	userID        string
+	sessionID     string
+	accessTokenID string
+	cancel        context.CancelFunc
+}
+
+// CloseSessionStreams closes every chat WebSocket opened with the given session.
+func CloseSessionStreams(sessionID string) {
+	closeStreams(func(s wsStream) bool { return s.sessionID == sessionID })
+}
+
+// CloseAccessTokenStreams closes every chat WebSocket opened with the given personal access token.
+func CloseAccessTokenStreams(tokenID string) {
+	closeStreams(func(s wsStream) bool { return s.accessTokenID == tokenID })
+}
+
+// CloseUserStreams closes every chat WebSocket the user has open.
+func CloseUserStreams(userID string) {
+	closeStreams(func(s wsStream) bool { return s.userID == userID })
+}
+
+func closeStreams(match func(wsStream) bool) {
+	wsStreams.Lock()
+	defer wsStreams.Unlock()
+	for ws, s := range wsStreams.conns {
+		if !match(s) {
+			continue
+		}
+		s.cancel()
+		_ = ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"),
+			time.Now().Add(time.Second))
+		_ = ws.Close()
+	}
+}
+
 // WSEvent represents a control event sent over WebSocket (delete, edit, new message).
 type WSEvent struct {
 	Type       string `json:"type"`                  // "message", "delete", "edit"
@@ -68,11 +117,7 @@ func InitClient(serverAddr string) {
 
 // GetChatSidebar fetches the list of followed users and their chat metadata.
 func GetChatSidebar(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("user_id is required", nil))
-		return
-	}
+	userID := middleware.CurrentUserID(c)
 
 	// 1. Get Following list
 	followingIDs, err := db.GetFollowing(c.Request.Context(), userID)
@@ -105,6 +150,8 @@ func GetChatSidebar(c *gin.Context) {
 	type SidebarItem struct {
 		ID          string `json:"id"`
 		Name        string `json:"name"`
+		FullName    string `json:"fullName"`
+		Avatar      string `json:"avatar"`
 		LastMessage string `json:"lastMessage"`
 		Time        string `json:"time"`
 		Unread      int    `json:"unread"`
@@ -122,8 +169,10 @@ func GetChatSidebar(c *gin.Context) {
 		status, _ := client.GetOnlineStatus(c.Request.Context(), &pb.GetOnlineStatusRequest{UserId: id})
 
 		item := SidebarItem{
-			ID:   id,
-			Name: user.Username,
+			ID:       id,
+			Name:     user.Username,
+			FullName: user.FullName,
+			Avatar:   user.AvatarURL,
 		}
 
 		if convo, ok := convoMap[id]; ok {
@@ -145,7 +194,6 @@ func GetChatSidebar(c *gin.Context) {
 // ReadMessages marks a conversation as read.
 func ReadMessages(c *gin.Context) {
 	var req struct {
-		UserID  string `json:"user_id"`
 		OtherID string `json:"other_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -153,8 +201,9 @@ func ReadMessages(c *gin.Context) {
 		return
 	}
 
-	convoID := db.GenerateConversationID(req.UserID, req.OtherID)
-	if err := db.MarkAsRead(c.Request.Context(), convoID, req.UserID); err != nil {
+	userID := middleware.CurrentUserID(c)
+	convoID := db.GenerateConversationID(userID, req.OtherID)
+	if err := db.MarkAsRead(c.Request.Context(), convoID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
@@ -165,7 +214,6 @@ func ReadMessages(c *gin.Context) {
 // SendMessage sends a message via gRPC.
 func SendMessage(c *gin.Context) {
 	var req struct {
-		SenderID   string `json:"sender_id"`
 		ReceiverID string `json:"receiver_id"`
 		Content    string `json:"content"`
 	}
@@ -175,22 +223,34 @@ func SendMessage(c *gin.Context) {
 	}
 
 	resp, err := client.SendMessage(c.Request.Context(), &pb.SendMessageRequest{
-		SenderId:   req.SenderID,
+		SenderId:   middleware.CurrentUserID(c),
 		ReceiverId: req.ReceiverID,
 		Content:    req.Content,
 	})
+	if status.Code(err) == codes.PermissionDenied {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(status.Convert(err).Message(), nil))
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
+	if !resp.Success {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(resp.Message, nil))
+		return
+	}
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("message sent", resp.Data))
 }
 
 // GetMessages fetches message history via gRPC.
 func GetMessages(c *gin.Context) {
-	u1 := c.Query("user_id_1")
+	u1 := middleware.CurrentUserID(c)
 	u2 := c.Query("user_id_2")
+	if u2 == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("user_id_2 is required", nil))
+		return
+	}
 
 	// Mark as read in DB first
 	convoID := db.GenerateConversationID(u1, u2)
@@ -212,7 +272,6 @@ func GetMessages(c *gin.Context) {
 func DeleteMessage(c *gin.Context) {
 	var req struct {
 		MessageID  string `json:"message_id"`
-		SenderID   string `json:"sender_id"`
 		ReceiverID string `json:"receiver_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -220,15 +279,16 @@ func DeleteMessage(c *gin.Context) {
 		return
 	}
 
-	if err := db.DeleteMessage(c.Request.Context(), req.MessageID, req.SenderID); err != nil {
+	senderID := middleware.CurrentUserID(c)
+	if err := db.DeleteMessage(c.Request.Context(), req.MessageID, senderID); err != nil {
 		c.JSON(http.StatusForbidden, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
 	// Fetch the message to know the receiver (before it was deleted we stored sender)
 	// We need to broadcast to the other user. Since the message is deleted,
-	// we know the sender is req.SenderID, so broadcast to all conversations.
-	broadcastEvent(req.ReceiverID, WSEvent{Type: "delete", MessageID: req.MessageID, SenderID: req.SenderID})
+	// we know the sender is the caller, so broadcast to all conversations.
+	broadcastEvent(req.ReceiverID, WSEvent{Type: "delete", MessageID: req.MessageID, SenderID: senderID})
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("message deleted", nil))
 }
@@ -237,7 +297,6 @@ func DeleteMessage(c *gin.Context) {
 func EditMessage(c *gin.Context) {
 	var req struct {
 		MessageID  string `json:"message_id"`
-		SenderID   string `json:"sender_id"`
 		ReceiverID string `json:"receiver_id"`
 		NewContent string `json:"new_content"`
 	}
@@ -246,24 +305,23 @@ func EditMessage(c *gin.Context) {
 		return
 	}
 
-	if err := db.EditMessage(c.Request.Context(), req.MessageID, req.SenderID, req.NewContent); err != nil {
+	senderID := middleware.CurrentUserID(c)
+	if err := db.EditMessage(c.Request.Context(), req.MessageID, senderID, req.NewContent); err != nil {
 		c.JSON(http.StatusForbidden, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
 	// Broadcast edit event to the receiver
-	broadcastEvent(req.ReceiverID, WSEvent{Type: "edit", MessageID: req.MessageID, SenderID: req.SenderID, Content: req.NewContent})
+	broadcastEvent(req.ReceiverID, WSEvent{Type: "edit", MessageID: req.MessageID, SenderID: senderID, Content: req.NewContent})
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("message edited", nil))
 }
 
 // StreamMessagesWS bridges gRPC stream to WebSocket for the browser.
 func StreamMessagesWS(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("user_id required", nil))
-		return
-	}
+	userID := middleware.CurrentUserID(c)
+	sessionID := middleware.CurrentSessionID(c)
+	accessTokenID := middleware.CurrentAccessTokenID(c)
 
 	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -272,7 +330,11 @@ func StreamMessagesWS(c *gin.Context) {
 	}
 	defer ws.Close()
 
-	stream, err := client.StreamMessages(c.Request.Context(), &pb.StreamMessagesRequest{UserId: userID})
+	// Cancelling ends the gRPC stream, which is the only way to unblock a pending Recv
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	stream, err := client.StreamMessages(ctx, &pb.StreamMessagesRequest{UserId: userID})
 	if err != nil {
 		log.Printf("❌ Failed to open gRPC stream for user %s: %v", userID, err)
 		return
@@ -290,12 +352,22 @@ func StreamMessagesWS(c *gin.Context) {
 		wsHub.Unlock()
 	}()
 
+	wsStreams.Lock()
+	wsStreams.conns[ws] = wsStream{userID: userID, sessionID: sessionID, accessTokenID: accessTokenID, cancel: cancel}
+	wsStreams.Unlock()
+	defer func() {
+		wsStreams.Lock()
+		delete(wsStreams.conns, ws)
+		wsStreams.Unlock()
+	}()
+
 	// Channel to signal closure
 	done := make(chan struct{})
 
 	// Read loop to detect client disconnection
 	go func() {
 		defer close(done)
+		defer cancel()
 		for {
 			if _, _, err := ws.ReadMessage(); err != nil {
 				log.Printf("🔌 WebSocket closed by client %s: %v", userID, err)
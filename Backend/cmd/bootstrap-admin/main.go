@@ -0,0 +1,80 @@
+// Command bootstrap-admin grants the admin role to an existing account, or creates a new admin
+// account, so a fresh deployment has someone who can manage roles through the API.
+// Run it from the Backend directory so the Firestore credentials are found:
+//
+//	go run ./cmd/bootstrap-admin -email admin@example.com
+//	go run ./cmd/bootstrap-admin -email admin@example.com -username admin -name "Site Admin" -password '...'
+//
+// It refuses to run once an admin exists unless -force is given.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the account to make admin (required)")
+	username := flag.String("username", "", "username for a new account, when no account uses the email")
+	fullName := flag.String("name", "", "full name for a new account")
+	password := flag.String("password", "", "password for a new account")
+	force := flag.Bool("force", false, "grant the role even if an admin already exists")
+	flag.Parse()
+
+	if strings.TrimSpace(*email) == "" {
+		log.Fatal("❌ -email is required")
+	}
+
+	if err := db.Init(); err != nil {
+		log.Fatalf("❌ Failed to initialize Firestore: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if !*force {
+		exists, err := db.AdminExists(ctx)
+		if err != nil {
+			log.Fatalf("❌ Failed to check for existing admins: %v", err)
+		}
+		if exists {
+			log.Fatal("❌ An admin already exists; ask them to grant the role, or pass -force")
+		}
+	}
+
+	if user, err := db.GetUserByEmail(ctx, *email); err == nil {
+		if err := db.SetUserRole(ctx, user.ID, models.RoleAdmin); err != nil {
+			log.Fatalf("❌ Failed to grant admin role: %v", err)
+		}
+		log.Printf("✅ %s (%s) is now an admin", user.Username, user.ID)
+		return
+	}
+
+	if *username == "" || *password == "" {
+		log.Fatalf("❌ No account uses %s; pass -username and -password to create one", *email)
+	}
+	if err := db.ValidateUsername(*username); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	user := &models.User{
+		FullName:      *fullName,
+		Username:      *username,
+		Email:         *email,
+		EmailVerified: true,
+		Password:      *password,
+		Role:          models.RoleAdmin,
+	}
+	if user.FullName == "" {
+		user.FullName = *username
+	}
+	userID, err := db.CreateUser(ctx, user)
+	if err != nil {
+		log.Fatalf("❌ Failed to create admin account: %v", err)
+	}
+	log.Printf("✅ Created admin %s (%s)", *username, userID)
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+const accessTokensCollection = "personal_access_tokens"
+
+// AccessTokenPrefix starts every personal access token, which lets the auth layer tell them apart from JWTs.
+const AccessTokenPrefix = "ihp_"
+
+// lastUsedResolution limits how often last-used details are written for a busy token.
+const lastUsedResolution = time.Minute
+
+// ErrInvalidAccessToken is returned when a personal access token is unknown, revoked or expired.
+var ErrInvalidAccessToken = errors.New("invalid or expired access token")
+
+// CreateAccessToken issues a personal access token and returns it together with the token itself,
+// which is shown to the user once. Tokens have the form "ihp_<token id>.<secret>".
+// A zero ttl creates a token that never expires.
+func CreateAccessToken(ctx context.Context, userID, name string, scopes []string, ttl time.Duration) (*models.PersonalAccessToken, string, error) {
+	if FirestoreClient == nil {
+		return nil, "", errors.New("firestore client is not initialized")
+	}
+
+	docRef := FirestoreClient.Collection(accessTokensCollection).NewDoc()
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	token := AccessTokenPrefix + docRef.ID + "." + secret
+
+	now := time.Now()
+	pat := &models.PersonalAccessToken{
+		ID:        docRef.ID,
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		TokenHash: utils.HashToken(token),
+		Hint:      token[len(token)-4:],
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		pat.ExpiresAt = now.Add(ttl)
+	}
+
+	if _, err := docRef.Set(ctx, pat); err != nil {
+		return nil, "", err
+	}
+	return pat, token, nil
+}
+
+// ListAccessTokens returns a user's personal access tokens, newest first.
+func ListAccessTokens(ctx context.Context, userID string) ([]models.PersonalAccessToken, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(accessTokensCollection).Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]models.PersonalAccessToken, 0, len(docs))
+	for _, doc := range docs {
+		var t models.PersonalAccessToken
+		if err := doc.DataTo(&t); err != nil {
+			continue
+		}
+		tokens = append(tokens, t)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// RevokeAccessToken revokes one of the user's personal access tokens.
+func RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(accessTokensCollection).Doc(tokenID)
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return errors.New("token not found")
+		}
+		var t models.PersonalAccessToken
+		if err := doc.DataTo(&t); err != nil {
+			return err
+		}
+		if t.UserID != userID {
+			return errors.New("token not found")
+		}
+		if t.Revoked {
+			return nil
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revoked_at", Value: time.Now()},
+		})
+	})
+}
+
+// RevokeAllAccessTokens revokes every active personal access token belonging to a user.
+func RevokeAllAccessTokens(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(accessTokensCollection).
+		Where("user_id", "==", userID).
+		Where("revoked", "==", false).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	// Batches are limited to 500 writes
+	const batchSize = 400
+	now := time.Now()
+	for start := 0; start < len(docs); start += batchSize {
+		end := start + batchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+		batch := FirestoreClient.Batch()
+		for _, doc := range docs[start:end] {
+			batch.Update(doc.Ref, []firestore.Update{
+				{Path: "revoked", Value: true},
+				{Path: "revoked_at", Value: now},
+			})
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuthenticateAccessToken resolves a presented personal access token and records its use.
+func AuthenticateAccessToken(ctx context.Context, token, clientIP string) (*models.PersonalAccessToken, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, AccessTokenPrefix), ".")
+	if !ok || id == "" || !strings.HasPrefix(token, AccessTokenPrefix) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	doc, err := FirestoreClient.Collection(accessTokensCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	var pat models.PersonalAccessToken
+	if err := doc.DataTo(&pat); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(pat.TokenHash), []byte(utils.HashToken(token))) != 1 || !pat.Active(now) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if now.Sub(pat.LastUsedAt) >= lastUsedResolution || pat.LastUsedIP != clientIP {
+		_, _ = doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "last_used_at", Value: now},
+			{Path: "last_used_ip", Value: clientIP},
+		})
+	}
+	return &pat, nil
+}
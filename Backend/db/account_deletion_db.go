@@ -0,0 +1,516 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const accountDeletionsCollection = "account_deletions"
+
+// Placeholder shown in place of a deleted user's name on content that is kept.
+const deletedUsername = "[deleted]"
+
+// AccountDeletionSteps are the purge steps in the order they run. Each step is idempotent,
+// so a purge interrupted half way through a step can simply run it again.
+var AccountDeletionSteps = []string{
+	"blogs",
+	"comments",
+	"likes",
+	"follows",
+	"notifications",
+	"messages",
+	"conversations",
+	"bookmarks",
+	"reads",
+	"exports",
+	"credentials",
+	"user",
+}
+
+// ScheduleAccountDeletion records a deletion request that becomes due after the grace period.
+func ScheduleAccountDeletion(ctx context.Context, userID, username string, grace time.Duration) (*models.AccountDeletion, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(accountDeletionsCollection).Doc(userID)
+	now := time.Now()
+	deletion := &models.AccountDeletion{
+		UserID:         userID,
+		Username:       username,
+		Status:         models.AccountDeletionPending,
+		RequestedAt:    now,
+		ScheduledFor:   now.Add(grace),
+		CompletedSteps: []string{},
+		UpdatedAt:      now,
+	}
+
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err == nil && doc.Exists() {
+			var existing models.AccountDeletion
+			if err := doc.DataTo(&existing); err != nil {
+				return err
+			}
+			if existing.Status == models.AccountDeletionPending || existing.Status == models.AccountDeletionRunning {
+				return errors.New("account deletion is already scheduled")
+			}
+		}
+		return tx.Set(ref, deletion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// GetAccountDeletion retrieves the deletion request for a user.
+func GetAccountDeletion(ctx context.Context, userID string) (*models.AccountDeletion, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := FirestoreClient.Collection(accountDeletionsCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		return nil, errors.New("no account deletion requested")
+	}
+	var deletion models.AccountDeletion
+	if err := doc.DataTo(&deletion); err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// CancelAccountDeletion cancels a deletion that is still in its grace period.
+func CancelAccountDeletion(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(accountDeletionsCollection).Doc(userID)
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return errors.New("no account deletion requested")
+		}
+		var deletion models.AccountDeletion
+		if err := doc.DataTo(&deletion); err != nil {
+			return err
+		}
+		if deletion.Status != models.AccountDeletionPending {
+			return errors.New("account deletion can no longer be cancelled")
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: models.AccountDeletionCancelled},
+			{Path: "updated_at", Value: time.Now()},
+		})
+	})
+}
+
+// ClaimDueAccountDeletion picks one deletion that is ready to run, either because its grace period
+// has ended or because the worker running it stopped renewing its lease, and leases it to the caller.
+// It returns nil when there is nothing to do.
+func ClaimDueAccountDeletion(ctx context.Context, lease time.Duration) (*models.AccountDeletion, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	// Each query matches only deletions that are claimable right now, so a page cannot be filled
+	// by running deletions whose workers still hold their lease
+	now := time.Now()
+	deletions := FirestoreClient.Collection(accountDeletionsCollection)
+	for _, query := range []firestore.Query{
+		deletions.Where("status", "==", models.AccountDeletionPending).Where("scheduled_for", "<=", now).OrderBy("scheduled_for", firestore.Asc),
+		deletions.Where("status", "==", models.AccountDeletionRunning).Where("lease_expires_at", "<=", now).OrderBy("lease_expires_at", firestore.Asc),
+	} {
+		claimed, err := claimFirstDeletion(ctx, query.Limit(10), now, lease)
+		if err != nil || claimed != nil {
+			return claimed, err
+		}
+	}
+	return nil, nil
+}
+
+// claimFirstDeletion leases the first deletion the query returns that is still claimable once read
+// inside a transaction; another worker may have claimed the others in the meantime.
+func claimFirstDeletion(ctx context.Context, query firestore.Query, now time.Time, lease time.Duration) (*models.AccountDeletion, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var claimed *models.AccountDeletion
+		err = FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			claimed = nil
+			snap, err := tx.Get(doc.Ref)
+			if err != nil {
+				return err
+			}
+			var deletion models.AccountDeletion
+			if err := snap.DataTo(&deletion); err != nil {
+				return err
+			}
+			claimable := (deletion.Status == models.AccountDeletionPending && !now.Before(deletion.ScheduledFor)) ||
+				(deletion.Status == models.AccountDeletionRunning && !now.Before(deletion.LeaseExpiresAt))
+			if !claimable {
+				return nil
+			}
+
+			deletion.Status = models.AccountDeletionRunning
+			deletion.LeaseExpiresAt = now.Add(lease)
+			deletion.UpdatedAt = now
+			claimed = &deletion
+			return tx.Update(doc.Ref, []firestore.Update{
+				{Path: "status", Value: deletion.Status},
+				{Path: "lease_expires_at", Value: deletion.LeaseExpiresAt},
+				{Path: "updated_at", Value: now},
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		if claimed != nil {
+			return claimed, nil
+		}
+	}
+}
+
+// CompleteAccountDeletionStep records a finished purge step and renews the lease.
+func CompleteAccountDeletionStep(ctx context.Context, userID, step string, lease time.Duration) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(accountDeletionsCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "completed_steps", Value: firestore.ArrayUnion(step)},
+		{Path: "lease_expires_at", Value: time.Now().Add(lease)},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	return err
+}
+
+// FinishAccountDeletion marks a purge as complete.
+func FinishAccountDeletion(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(accountDeletionsCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.AccountDeletionCompleted},
+		{Path: "last_error", Value: firestore.Delete},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	return err
+}
+
+// FailAccountDeletion records why a purge stopped and leaves it to be retried once retryAfter has passed.
+func FailAccountDeletion(ctx context.Context, userID string, cause error, retryAfter time.Duration) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(accountDeletionsCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "last_error", Value: cause.Error()},
+		{Path: "lease_expires_at", Value: time.Now().Add(retryAfter)},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	return err
+}
+
+// PurgeAccountStep runs one purge step for a deletion.
+func PurgeAccountStep(ctx context.Context, deletion *models.AccountDeletion, step string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	userID := deletion.UserID
+	switch step {
+	case "blogs":
+		return purgeBlogs(ctx, userID)
+	case "comments":
+		return anonymizeComments(ctx, userID)
+	case "likes":
+		usernames, err := deletionUsernames(ctx, deletion)
+		if err != nil {
+			return err
+		}
+		for _, username := range usernames {
+			if err := removeLikes(ctx, username); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "follows":
+		return removeFollowEdges(ctx, userID)
+	case "notifications":
+		if err := deleteQuery(ctx, FirestoreClient.Collection(notificationsCollection).Where("recipient", "==", userID)); err != nil {
+			return err
+		}
+		usernames, err := deletionUsernames(ctx, deletion)
+		if err != nil {
+			return err
+		}
+		for _, username := range usernames {
+			if err := deleteQuery(ctx, FirestoreClient.Collection(notificationsCollection).Where("sender", "==", username)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "messages":
+		if err := deleteQuery(ctx, FirestoreClient.Collection("messages").Where("sender_id", "==", userID)); err != nil {
+			return err
+		}
+		return deleteQuery(ctx, FirestoreClient.Collection("messages").Where("receiver_id", "==", userID))
+	case "conversations":
+		return deleteQuery(ctx, FirestoreClient.Collection("conversations").Where("participant_ids", "array-contains", userID))
+	case "bookmarks":
+		return deleteQuery(ctx, FirestoreClient.Collection(bookmarksCollection).Where("user_id", "==", userID))
+	case "reads":
+		return deleteQuery(ctx, FirestoreClient.Collection(blogReadsCollection).Where("user_id", "==", userID))
+	case "exports":
+		// The archives themselves are removed by the caller before this step runs
+		return deleteQuery(ctx, FirestoreClient.Collection(dataExportsCollection).Where("user_id", "==", userID))
+	case "credentials":
+		if err := deleteQuery(ctx, FirestoreClient.Collection(sessionsCollection).Where("user_id", "==", userID)); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(tokensCollection).Where("user_id", "==", userID)); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(accessTokensCollection).Where("user_id", "==", userID)); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(identityClaimsCollection).Where("user_id", "==", userID)); err != nil {
+			return err
+		}
+		return deleteQuery(ctx, FirestoreClient.Collection(usernamesCollection).Where("user_id", "==", userID))
+	case "user":
+		_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Delete(ctx)
+		return err
+	default:
+		return errors.New("unknown account deletion step: " + step)
+	}
+}
+
+// deletionUsernames returns the usernames likes and notifications of the user are recorded
+// under: the current one and, if the account was renamed during the grace period, the one it
+// had when deletion was requested.
+func deletionUsernames(ctx context.Context, deletion *models.AccountDeletion) ([]string, error) {
+	var usernames []string
+	if deletion.Username != "" {
+		usernames = append(usernames, deletion.Username)
+	}
+	doc, err := FirestoreClient.Collection(usersCollection).Doc(deletion.UserID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return usernames, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := doc.DataTo(&user); err != nil {
+		return nil, err
+	}
+	if user.Username != "" && user.Username != deletion.Username {
+		usernames = append(usernames, user.Username)
+	}
+	return usernames, nil
+}
+
+// purgeBlogs deletes the user's blogs together with every comment on them.
+func purgeBlogs(ctx context.Context, userID string) error {
+	iter := FirestoreClient.Collection(blogsCollection).Where("author_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection("comments").Where("blog_id", "==", doc.Ref.ID)); err != nil {
+			return err
+		}
+		if err := releaseSlugs(ctx, doc.Ref.ID); err != nil {
+			return err
+		}
+		if err := releaseTitles(ctx, doc.Ref.ID); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(bookmarksCollection).Where("blog_id", "==", doc.Ref.ID)); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(blogRevisionsCollection).Where("blog_id", "==", doc.Ref.ID)); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(engagementCollection).Where("blog_id", "==", doc.Ref.ID)); err != nil {
+			return err
+		}
+		if err := deleteQuery(ctx, FirestoreClient.Collection(blogReadsCollection).Where("blog_id", "==", doc.Ref.ID)); err != nil {
+			return err
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return err
+		}
+		unindexBlog(doc.Ref.ID)
+	}
+}
+
+// anonymizeComments keeps the user's comments on other people's blogs, so reply threads stay
+// intact, but strips the content and authorship.
+func anonymizeComments(ctx context.Context, userID string) error {
+	iter := FirestoreClient.Collection("comments").Where("author_id", "==", userID).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "author_id", Value: ""},
+			{Path: "author_username", Value: deletedUsername},
+			{Path: "content", Value: deletedUsername},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// removeLikes takes the user out of every liked_by list and corrects the like counters.
+func removeLikes(ctx context.Context, username string) error {
+	if username == "" {
+		return nil
+	}
+	iter := FirestoreClient.Collection(blogsCollection).Where("liked_by", "array-contains", username).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			snap, err := tx.Get(doc.Ref)
+			if err != nil {
+				return err
+			}
+			var b models.Blog
+			if err := snap.DataTo(&b); err != nil {
+				return err
+			}
+			if !containsString(b.LikedBy, username) {
+				return nil
+			}
+			return tx.Update(doc.Ref, []firestore.Update{
+				{Path: "liked_by", Value: firestore.ArrayRemove(username)},
+				{Path: "likes", Value: firestore.Increment(-1)},
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// removeFollowEdges deletes the user from other users' follower and following lists,
+// correcting their counters. Each edge is only removed (and counted) once.
+func removeFollowEdges(ctx context.Context, userID string) error {
+	edges := []struct{ listField, countField string }{
+		{"followers_list", "Followers"},  // users the deleted user followed
+		{"following_list", "Followings"}, // users who followed the deleted user
+	}
+	for _, edge := range edges {
+		iter := FirestoreClient.Collection(usersCollection).Where(edge.listField, "array-contains", userID).Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return err
+			}
+			err = FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+				snap, err := tx.Get(doc.Ref)
+				if err != nil {
+					return err
+				}
+				list, _ := snap.Data()[edge.listField].([]interface{})
+				found := false
+				for _, v := range list {
+					if id, ok := v.(string); ok && id == userID {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return nil
+				}
+				return tx.Update(doc.Ref, []firestore.Update{
+					{Path: edge.listField, Value: firestore.ArrayRemove(userID)},
+					{Path: edge.countField, Value: firestore.Increment(-1)},
+				})
+			})
+			if err != nil {
+				iter.Stop()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteQuery deletes every document matched by q, a page at a time.
+func deleteQuery(ctx context.Context, q firestore.Query) error {
+	const pageSize = 400
+	for {
+		docs, err := q.Limit(pageSize).Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		batch := FirestoreClient.Batch()
+		for _, doc := range docs {
+			batch.Delete(doc.Ref)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+		if len(docs) < pageSize {
+			return nil
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,9 @@ import (
 
 const usersCollection = "users"
 
+// ErrNoPassword is returned for accounts that only sign in through an external provider.
+var ErrNoPassword = errors.New("this account does not have a password")
+
 // CreateUser stores a new user in Firestore after basic checks and returns the document ID.
 func CreateUser(ctx context.Context, user *models.User) (string, error) {
 	if FirestoreClient == nil {
@@ -30,16 +33,34 @@ func CreateUser(ctx context.Context, user *models.User) (string, error) {
 
 	// Hash the password before storing (only if provided, e.g. not for Google users)
 	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		hashedPassword, err := hashPassword(user.Password)
 		if err != nil {
 			return "", err
 		}
-		user.Password = string(hashedPassword)
+		user.Password = hashedPassword
 	}
 
 	user.CreatedAt = time.Now()
 
-	docRef, _, err := FirestoreClient.Collection(usersCollection).Add(ctx, user)
+	// Claim the username and any linked identities and create the user atomically, so two
+	// signups cannot share a name or an external account
+	docRef := FirestoreClient.Collection(usersCollection).NewDoc()
+	err = FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		for _, identity := range user.Identities {
+			if err := checkIdentityFree(tx, identity, docRef.ID); err != nil {
+				return err
+			}
+		}
+		if err := reserveUsername(ctx, tx, user.Username, docRef.ID); err != nil {
+			return err
+		}
+		for _, identity := range user.Identities {
+			if err := claimIdentity(tx, identity, docRef.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Create(docRef, user)
+	})
 	if err != nil {
 		return "", err
 	}
@@ -178,3 +199,64 @@ func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	user.Password = ""
 	return &user, nil
 }
+
+// MarkEmailVerified records that the user has proven ownership of their email address.
+func MarkEmailVerified(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "EmailVerified", Value: true},
+	})
+	return err
+}
+
+// CheckPassword verifies a user's current password.
+func CheckPassword(ctx context.Context, userID, password string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	doc, err := FirestoreClient.Collection(usersCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	var stored models.User
+	if err := doc.DataTo(&stored); err != nil {
+		return errors.New("invalid user data")
+	}
+	if stored.Password == "" {
+		return ErrNoPassword
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte(password)); err != nil {
+		return errors.New("current password is incorrect")
+	}
+	return nil
+}
+
+// UpdatePassword replaces a user's password with the bcrypt hash of newPassword.
+func UpdatePassword(ctx context.Context, userID, newPassword string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	hashedPassword, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "Password", Value: hashedPassword},
+	})
+	return err
+}
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
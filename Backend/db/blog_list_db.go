@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// ErrInvalidCursor is returned for cursors that were not issued for the same query.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrUnsupportedBlogQuery is returned for filter and sort combinations Firestore cannot serve:
+// a date range orders by published_at first, so it only combines with the newest-first sort.
+var ErrUnsupportedBlogQuery = errors.New("date range filters can only be combined with sort=newest")
+
+// blogCursor is the position after the last blog of a page: the value of the sort field,
+// the publication time and the document ID, which together order blogs without ties.
+type blogCursor struct {
+	Sort        string    `json:"s"`
+	Count       int64     `json:"n,omitempty"`
+	PublishedAt time.Time `json:"t"`
+	ID          string    `json:"id"`
+}
+
+func (c blogCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeBlogCursor(s, sort string) (*blogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c blogCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// blogSortField is the counter a sort mode orders by, or "" for newest first.
+func blogSortField(sort string) string {
+	switch sort {
+	case models.BlogSortMostViewed:
+		return "views"
+	case models.BlogSortMostLiked:
+		return "likes"
+	}
+	return ""
+}
+
+// ListBlogs returns one page of published blogs matching q, with author details filled in from a
+// single batched read. Blogs of banned authors are left out, so a page may hold fewer than q.Limit
+// blogs while NextCursor is still set.
+func ListBlogs(ctx context.Context, q models.BlogListQuery) (*models.BlogPage, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	if q.Sort == "" {
+		q.Sort = models.BlogSortNewest
+	}
+	if q.Limit <= 0 {
+		q.Limit = models.DefaultBlogPageSize
+	}
+	if q.Limit > models.MaxBlogPageSize {
+		q.Limit = models.MaxBlogPageSize
+	}
+	sortField := blogSortField(q.Sort)
+	if sortField != "" && (!q.From.IsZero() || !q.To.IsZero()) {
+		return nil, ErrUnsupportedBlogQuery
+	}
+
+	query := FirestoreClient.Collection(blogsCollection).Where("status", "==", models.BlogStatusPublished)
+	if q.Category != "" {
+		query = query.Where("category", "==", q.Category)
+	}
+	if q.Tag != "" {
+		query = query.Where("tags", "array-contains", q.Tag)
+	}
+	if q.AuthorID != "" {
+		query = query.Where("author_id", "==", q.AuthorID)
+	}
+	if q.Featured != nil {
+		query = query.Where("featured", "==", *q.Featured)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("published_at", ">=", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("published_at", "<", q.To)
+	}
+
+	if sortField != "" {
+		query = query.OrderBy(sortField, firestore.Desc)
+	}
+	query = query.OrderBy("published_at", firestore.Desc).OrderBy(firestore.DocumentID, firestore.Desc)
+
+	if q.Cursor != "" {
+		cursor, err := decodeBlogCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		if sortField != "" {
+			query = query.StartAfter(cursor.Count, cursor.PublishedAt, cursor.ID)
+		} else {
+			query = query.StartAfter(cursor.PublishedAt, cursor.ID)
+		}
+	}
+
+	// Fetch one extra document to learn whether another page follows.
+	docs, err := query.Limit(q.Limit + 1).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.BlogPage{Blogs: []models.Blog{}}
+	if len(docs) > q.Limit {
+		docs = docs[:q.Limit]
+		last := docs[len(docs)-1]
+		var b models.Blog
+		if err := last.DataTo(&b); err != nil {
+			return nil, err
+		}
+		cursor := blogCursor{Sort: q.Sort, PublishedAt: b.PublishedAt, ID: last.Ref.ID}
+		switch sortField {
+		case "views":
+			cursor.Count = int64(b.Views)
+		case "likes":
+			cursor.Count = int64(b.Likes)
+		}
+		page.NextCursor = cursor.encode()
+	}
+
+	blogs := make([]models.Blog, 0, len(docs))
+	for _, doc := range docs {
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		blogs = append(blogs, b)
+	}
+	if page.Blogs, err = attachAuthors(ctx, blogs); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// attachAuthors fills in the author fields of blogs with one batched read of their authors and
+// drops the blogs of banned authors. Blogs whose cached HTML is stale are rendered again for the
+// response only; GetBlog is what saves the result.
+func attachAuthors(ctx context.Context, blogs []models.Blog) ([]models.Blog, error) {
+	seen := make(map[string]bool, len(blogs))
+	var refs []*firestore.DocumentRef
+	for _, b := range blogs {
+		if b.AuthorID == "" || seen[b.AuthorID] {
+			continue
+		}
+		seen[b.AuthorID] = true
+		refs = append(refs, FirestoreClient.Collection(usersCollection).Doc(b.AuthorID))
+	}
+
+	authors := make(map[string]*models.User, len(refs))
+	if len(refs) > 0 {
+		docs, err := FirestoreClient.GetAll(ctx, refs)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			if !doc.Exists() {
+				continue
+			}
+			var u models.User
+			if err := doc.DataTo(&u); err == nil {
+				authors[doc.Ref.ID] = &u
+			}
+		}
+	}
+
+	visible := make([]models.Blog, 0, len(blogs))
+	for _, b := range blogs {
+		author, ok := authors[b.AuthorID]
+		switch {
+		case !ok:
+			b.AuthorName = "Unknown Author"
+			b.AuthorUsername = "unknown"
+		case author.Suspension.Banned():
+			continue
+		default:
+			b.AuthorName = author.FullName
+			b.AuthorUsername = author.Username
+			b.AuthorAvatar = author.AvatarURL
+		}
+		renderIfStale(&b)
+		visible = append(visible, b)
+	}
+	return visible, nil
+}
@@ -24,14 +24,52 @@ func CreateBlog(ctx context.Context, blog *models.Blog) (string, error) {
 	blog.Likes = 0
 	blog.Comments = 0
 	blog.Views = 0
+	if blog.Status == "" {
+		blog.Status = models.BlogStatusPublished
+	}
+	blog.PublishedAt = time.Time{}
+	if blog.Published() {
+		blog.PublishAt = time.Time{}
+		blog.PublishedAt = blog.CreatedAt
+	}
+
+	renderContent(blog)
 
 	docRef := FirestoreClient.Collection(blogsCollection).NewDoc()
 	blog.ID = docRef.ID
 
-	_, err := docRef.Set(ctx, blog)
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := checkTitleFree(ctx, tx, blog.AuthorID, blog.Title, blog.ID); err != nil {
+			return err
+		}
+		uploads, err := useUploads(tx, blog.Uploads)
+		if err != nil {
+			return err
+		}
+		slug, err := reserveSlug(ctx, tx, blog.Title, blog.ID)
+		if err != nil {
+			return err
+		}
+		if err := claimTitle(tx, blog.AuthorID, blog.Title, blog.ID); err != nil {
+			return err
+		}
+		if err := touchUploads(tx, uploads); err != nil {
+			return err
+		}
+		blog.Slug = slug
+		blog.Revision = 1
+		if err := tx.Create(revisionRef(blog.ID, 1), revisionOf(blog, 1, blog.CreatedAt)); err != nil {
+			return err
+		}
+		return tx.Create(docRef, blog)
+	})
 	if err != nil {
 		return "", err
 	}
+	if !blog.Published() {
+		return docRef.ID, nil
+	}
+	indexBlog(blog)
 
 	// Increment user's blog count
 	if blog.AuthorID != "" {
@@ -47,98 +85,75 @@ func CreateBlog(ctx context.Context, blog *models.Blog) (string, error) {
 	return docRef.ID, nil
 }
 
-// GetBlogID retrieves the document ID for a given title.
-func GetBlogID(ctx context.Context, title string) (string, error) {
+// GetBlogByID retrieves a single blog by its document ID.
+func GetBlogByID(ctx context.Context, id string) (*models.Blog, error) {
 	if FirestoreClient == nil {
-		return "", errors.New("firestore client is not initialized")
+		return nil, errors.New("firestore client is not initialized")
 	}
 
-	doc, err := FirestoreClient.Collection(blogsCollection).Where("title", "==", title).Limit(1).Documents(ctx).Next()
+	doc, err := FirestoreClient.Collection(blogsCollection).Doc(id).Get(ctx)
 	if err != nil {
-		return "", errors.New("blog not found")
+		return nil, errors.New("blog not found")
 	}
 
-	return doc.Ref.ID, nil
+	var b models.Blog
+	if err := doc.DataTo(&b); err != nil {
+		return nil, err
+	}
+	b.ID = doc.Ref.ID
+	return &b, nil
 }
 
-// GetAllBlogs fetches all blogs from Firestore.
-func GetAllBlogs(ctx context.Context) ([]models.Blog, error) {
+// GetBlogByTitle retrieves a blog by its title, for clients that still identify blogs that way.
+// Titles are only unique per author, so pass authorID when it is known; otherwise the first match is returned.
+func GetBlogByTitle(ctx context.Context, title, authorID string) (*models.Blog, error) {
 	if FirestoreClient == nil {
 		return nil, errors.New("firestore client is not initialized")
 	}
 
-	var blogs []models.Blog
-	iter := FirestoreClient.Collection(blogsCollection).OrderBy("created_at", firestore.Desc).Documents(ctx)
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		var b models.Blog
-		if err := doc.DataTo(&b); err != nil {
-			continue
-		}
-		b.ID = doc.Ref.ID
-
-		// Fetch author details
-		userDoc, err := FirestoreClient.Collection("users").Doc(b.AuthorID).Get(ctx)
-		if err == nil {
-			var u models.User
-			if err := userDoc.DataTo(&u); err == nil {
-				b.AuthorName = u.FullName
-				b.AuthorUsername = u.Username
-			}
-		} else {
-			b.AuthorName = "Unknown Author"
-			b.AuthorUsername = "unknown"
-		}
-
-		blogs = append(blogs, b)
+	query := FirestoreClient.Collection(blogsCollection).Where("title", "==", title)
+	if authorID != "" {
+		query = query.Where("author_id", "==", authorID)
 	}
-	return blogs, nil
-}
-
-// TitleExists checks if a blog with the same title already exists.
-func TitleExists(ctx context.Context, title string) (bool, error) {
-	if FirestoreClient == nil {
-		return false, errors.New("firestore client is not initialized")
+	doc, err := query.Limit(1).Documents(ctx).Next()
+	if err != nil {
+		return nil, errors.New("blog not found")
 	}
 
-	iter := FirestoreClient.Collection(blogsCollection).Where("title", "==", title).Limit(1).Documents(ctx)
-	_, err := iter.Next()
-	if err != nil {
-		return false, nil
+	var b models.Blog
+	if err := doc.DataTo(&b); err != nil {
+		return nil, err
 	}
-	return true, nil
+	b.ID = doc.Ref.ID
+	return &b, nil
 }
 
-// IncrementViews increases the view count for a blog with the given title.
-func IncrementViews(ctx context.Context, title string) error {
+// IncrementViews increases the view count of a blog. Only a viewer's first view of the blog in an
+// hour counts towards trending; viewer names a signed-in user or the address of a visitor.
+func IncrementViews(ctx context.Context, blogID, viewer string) error {
 	if FirestoreClient == nil {
 		return errors.New("firestore client is not initialized")
 	}
 
-	doc, err := FirestoreClient.Collection(blogsCollection).Where("title", "==", title).Limit(1).Documents(ctx).Next()
+	_, err := FirestoreClient.Collection(blogsCollection).Doc(blogID).Update(ctx, []firestore.Update{
+		{Path: "views", Value: firestore.Increment(1)},
+	})
 	if err != nil {
 		return errors.New("blog not found")
 	}
-
-	_, err = doc.Ref.Update(ctx, []firestore.Update{
-		{Path: "views", Value: firestore.Increment(1)},
-	})
-	return err
+	if firstViewThisHour(ctx, blogID, viewer) {
+		recordEngagement(ctx, blogID, engagementViews, 1)
+	}
+	return nil
 }
 
 // ToggleLike toggles the like status for a blog and increments/decrements the count.
-func ToggleLike(ctx context.Context, username, title string) (bool, error) {
+func ToggleLike(ctx context.Context, username, blogID string) (bool, error) {
 	if FirestoreClient == nil {
 		return false, errors.New("firestore client is not initialized")
 	}
 
-	doc, err := FirestoreClient.Collection(blogsCollection).Where("title", "==", title).Limit(1).Documents(ctx).Next()
+	doc, err := FirestoreClient.Collection(blogsCollection).Doc(blogID).Get(ctx)
 	if err != nil {
 		return false, errors.New("blog not found")
 	}
@@ -169,8 +184,15 @@ func ToggleLike(ctx context.Context, username, title string) (bool, error) {
 		}
 	}
 
-	_, err = doc.Ref.Update(ctx, updates)
-	return !alreadyLiked, err
+	if _, err = doc.Ref.Update(ctx, updates); err != nil {
+		return false, err
+	}
+	if alreadyLiked {
+		recordEngagement(ctx, blogID, engagementLikes, -1)
+	} else {
+		recordEngagement(ctx, blogID, engagementLikes, 1)
+	}
+	return !alreadyLiked, nil
 }
 
 // AddComment stores a new comment in Firestore.
@@ -194,6 +216,7 @@ func AddComment(ctx context.Context, comment *models.Comment) error {
 		_, _ = doc.Ref.Update(ctx, []firestore.Update{
 			{Path: "comments", Value: firestore.Increment(1)},
 		})
+		recordEngagement(ctx, comment.BlogID, engagementComments, 1)
 	}
 
 	return nil
@@ -231,34 +254,179 @@ func GetComments(ctx context.Context, blogID string) ([]models.Comment, error) {
 	return comments, nil
 }
 
-// UpdateBlog updates an existing blog post.
+// GetVisibleComments fetches the comments on a blog that should be shown to readers,
+// leaving out those written by banned users.
+func GetVisibleComments(ctx context.Context, blogID string) ([]models.Comment, error) {
+	comments, err := GetComments(ctx, blogID)
+	if err != nil {
+		return nil, err
+	}
+
+	authorIDs := make([]string, 0, len(comments))
+	for _, c := range comments {
+		authorIDs = append(authorIDs, c.AuthorID)
+	}
+	banned, err := bannedUsers(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := comments[:0]
+	for _, c := range comments {
+		if !banned[c.AuthorID] {
+			visible = append(visible, c)
+		}
+	}
+	return visible, nil
+}
+
+// bannedUsers looks up the given users in one batch and returns the set of those who are banned.
+func bannedUsers(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	seen := make(map[string]bool, len(userIDs))
+	var refs []*firestore.DocumentRef
+	for _, id := range userIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		refs = append(refs, FirestoreClient.Collection(usersCollection).Doc(id))
+	}
+
+	banned := make(map[string]bool)
+	if len(refs) == 0 {
+		return banned, nil
+	}
+	docs, err := FirestoreClient.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var u models.User
+		if err := doc.DataTo(&u); err == nil && u.Suspension.Banned() {
+			banned[doc.Ref.ID] = true
+		}
+	}
+	return banned, nil
+}
+
+// UpdateBlog updates an existing blog post identified by blog.ID and records the result as a new
+// revision. When the title changes the blog gets a new slug and the old one keeps redirecting to it.
+// The fields the author cannot edit, such as the slug and revision number, are set on blog.
 func UpdateBlog(ctx context.Context, blog *models.Blog) error {
 	if FirestoreClient == nil {
 		return errors.New("firestore client is not initialized")
 	}
+	return updateBlog(ctx, blog, 0)
+}
 
-	doc, err := FirestoreClient.Collection(blogsCollection).Where("title", "==", blog.Title).Limit(1).Documents(ctx).Next()
-	if err != nil {
-		return errors.New("blog not found")
-	}
+// updateBlog saves blog as its next revision, noting restoredFrom when it brings back an older one.
+func updateBlog(ctx context.Context, blog *models.Blog, restoredFrom int) error {
+	renderContent(blog)
+	ref := FirestoreClient.Collection(blogsCollection).Doc(blog.ID)
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return errors.New("blog not found")
+		}
+		var existing models.Blog
+		if err := doc.DataTo(&existing); err != nil {
+			return err
+		}
+		existing.ID = doc.Ref.ID
+
+		blog.AuthorID = existing.AuthorID
+		blog.CreatedAt = existing.CreatedAt
+		blog.Status = existing.Status
+		blog.PublishAt = existing.PublishAt
+		blog.PublishedAt = existing.PublishedAt
+		blog.Slug = existing.Slug
+		blog.Revision = existing.Revision
+
+		// Titles are unique per author; every read has to happen before the first write
+		titleChanged := blog.Title != existing.Title
+		releaseOld := false
+		if titleChanged {
+			if err := checkTitleFree(ctx, tx, blog.AuthorID, blog.Title, blog.ID); err != nil {
+				return err
+			}
+			if normalizeTitle(blog.Title) != normalizeTitle(existing.Title) {
+				if releaseOld, err = holdsTitle(tx, blog.AuthorID, existing.Title, blog.ID); err != nil {
+					return err
+				}
+			}
+		}
+		uploads, err := useUploads(tx, blog.Uploads)
+		if err != nil {
+			return err
+		}
+		if titleChanged || existing.Slug == "" {
+			blog.Slug, err = reserveSlug(ctx, tx, blog.Title, blog.ID)
+			if err != nil {
+				return err
+			}
+		}
+		if titleChanged {
+			if err := claimTitle(tx, blog.AuthorID, blog.Title, blog.ID); err != nil {
+				return err
+			}
+			if releaseOld {
+				if err := releaseTitle(tx, blog.AuthorID, existing.Title); err != nil {
+					return err
+				}
+			}
+		}
+		if err := touchUploads(tx, uploads); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// Blogs from before revisions were kept get their current state recorded first
+		if existing.Revision == 0 {
+			blog.Revision = 1
+			if err := tx.Create(revisionRef(blog.ID, 1), revisionOf(&existing, 1, existing.UpdatedAt)); err != nil {
+				return err
+			}
+		}
+		latest := revisionOf(&existing, blog.Revision, now)
+		if !latest.SameContent(blog) || restoredFrom != 0 {
+			blog.Revision++
+			rev := revisionOf(blog, blog.Revision, now)
+			rev.RestoredFrom = restoredFrom
+			if err := tx.Create(revisionRef(blog.ID, blog.Revision), rev); err != nil {
+				return err
+			}
+		}
 
-	_, err = doc.Ref.Update(ctx, []firestore.Update{
-		{Path: "blog_content", Value: blog.BlogContent},
-		{Path: "updated_at", Value: time.Now()},
-		{Path: "category", Value: blog.Category},
-		{Path: "tags", Value: blog.Tags},
-		{Path: "blog_image", Value: blog.BlogImage},
+		return tx.Update(ref, append([]firestore.Update{
+			{Path: "title", Value: blog.Title},
+			{Path: "slug", Value: blog.Slug},
+			{Path: "blog_content", Value: blog.BlogContent},
+			{Path: "updated_at", Value: now},
+			{Path: "category", Value: blog.Category},
+			{Path: "tags", Value: blog.Tags},
+			{Path: "blog_image", Value: blog.BlogImage},
+			{Path: "revision", Value: blog.Revision},
+		}, renderUpdates(blog)...))
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	if blog.Published() {
+		indexBlog(blog)
+	}
+	return nil
 }
 
-// DeleteBlog deletes a blog post and its comments, and decrements the author's blog count.
-func DeleteBlog(ctx context.Context, title string) error {
+// DeleteBlog deletes a blog post, its comments and slugs, and decrements the author's blog count.
+func DeleteBlog(ctx context.Context, blogID string) error {
 	if FirestoreClient == nil {
 		return errors.New("firestore client is not initialized")
 	}
 
-	doc, err := FirestoreClient.Collection(blogsCollection).Where("title", "==", title).Limit(1).Documents(ctx).Next()
+	doc, err := FirestoreClient.Collection(blogsCollection).Doc(blogID).Get(ctx)
 	if err != nil {
 		return errors.New("blog not found")
 	}
@@ -279,9 +447,16 @@ func DeleteBlog(ctx context.Context, title string) error {
 	if err != nil {
 		return err
 	}
-
-	// Decrement author blog count
-	if b.AuthorID != "" {
+	_ = releaseSlugs(ctx, doc.Ref.ID)
+	_ = releaseTitles(ctx, doc.Ref.ID)
+	_ = deleteQuery(ctx, FirestoreClient.Collection(bookmarksCollection).Where("blog_id", "==", doc.Ref.ID))
+	_ = deleteQuery(ctx, FirestoreClient.Collection(blogRevisionsCollection).Where("blog_id", "==", doc.Ref.ID))
+	_ = deleteQuery(ctx, FirestoreClient.Collection(engagementCollection).Where("blog_id", "==", doc.Ref.ID))
+	_ = deleteQuery(ctx, FirestoreClient.Collection(blogReadsCollection).Where("blog_id", "==", doc.Ref.ID))
+	unindexBlog(doc.Ref.ID)
+
+	// Decrement author blog count, which only includes published blogs
+	if b.AuthorID != "" && b.Published() {
 		_, _ = FirestoreClient.Collection("users").Doc(b.AuthorID).Update(ctx, []firestore.Update{
 			{Path: "NoOfBlogs", Value: firestore.Increment(-1)},
 		})
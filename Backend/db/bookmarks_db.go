@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const bookmarksCollection = "bookmarks"
+
+func bookmarkRef(userID, blogID string) *firestore.DocumentRef {
+	return FirestoreClient.Collection(bookmarksCollection).Doc(userID + "_" + blogID)
+}
+
+// AddBookmark saves a blog for a user. Bookmarking twice is not an error.
+func AddBookmark(ctx context.Context, userID, blogID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := bookmarkRef(userID, blogID).Set(ctx, models.Bookmark{
+		UserID:    userID,
+		BlogID:    blogID,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+// RemoveBookmark removes a saved blog.
+func RemoveBookmark(ctx context.Context, userID, blogID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := bookmarkRef(userID, blogID).Delete(ctx)
+	return err
+}
+
+// IsBookmarked reports whether the user has saved the blog.
+func IsBookmarked(ctx context.Context, userID, blogID string) (bool, error) {
+	if FirestoreClient == nil {
+		return false, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := bookmarkRef(userID, blogID).Get(ctx)
+	if err != nil {
+		if doc != nil && !doc.Exists() {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetBookmarkedBlogs returns the blogs a user saved, most recently saved first.
+// Bookmarks of blogs that no longer exist are skipped.
+func GetBookmarkedBlogs(ctx context.Context, userID string) ([]models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(bookmarksCollection).Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	bookmarks := make([]models.Bookmark, 0, len(docs))
+	for _, doc := range docs {
+		var b models.Bookmark
+		if err := doc.DataTo(&b); err == nil {
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].CreatedAt.After(bookmarks[j].CreatedAt)
+	})
+
+	ids := make([]string, len(bookmarks))
+	for i, b := range bookmarks {
+		ids[i] = b.BlogID
+	}
+	return GetBlogsByIDs(ctx, ids)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/markdown"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// renderContent fills in the sanitized HTML and table of contents of a blog from its content, and
+// notes the uploaded images it uses so they are not garbage collected.
+func renderContent(b *models.Blog) {
+	if b.ContentFormat == "" {
+		b.ContentFormat = models.ContentFormatPlain
+	}
+
+	var result markdown.Result
+	if b.ContentFormat == models.ContentFormatMarkdown {
+		result = markdown.Render(b.BlogContent)
+	} else {
+		result = markdown.RenderPlain(b.BlogContent)
+	}
+
+	b.ContentHTML = result.HTML
+	b.TOC = make([]models.TOCEntry, len(result.Headings))
+	for i, h := range result.Headings {
+		b.TOC[i] = models.TOCEntry{Level: h.Level, Text: h.Text, ID: h.ID}
+	}
+	b.RenderVersion = markdown.Version
+	b.Uploads = models.UploadIDs(b.BlogImage, b.BlogContent)
+}
+
+func renderUpdates(b *models.Blog) []firestore.Update {
+	return []firestore.Update{
+		{Path: "content_format", Value: b.ContentFormat},
+		{Path: "content_html", Value: b.ContentHTML},
+		{Path: "toc", Value: b.TOC},
+		{Path: "render_version", Value: b.RenderVersion},
+		{Path: "uploads", Value: b.Uploads},
+	}
+}
+
+// renderIfStale renders blogs whose cached HTML is missing or was made by an older renderer.
+// It reports whether it did.
+func renderIfStale(b *models.Blog) bool {
+	if b.RenderVersion == markdown.Version {
+		return false
+	}
+	renderContent(b)
+	return true
+}
+
+// EnsureRendered re-renders a blog whose cached HTML is stale and saves the result, so it is only
+// rendered again when the renderer changes.
+func EnsureRendered(ctx context.Context, b *models.Blog) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+	if !renderIfStale(b) {
+		return nil
+	}
+	_, err := FirestoreClient.Collection(blogsCollection).Doc(b.ID).Update(ctx, renderUpdates(b))
+	return err
+}
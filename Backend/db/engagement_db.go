@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	engagementCollection = "blog_engagement"
+	// blog_views notes who has viewed a blog this hour, so repeated views are not counted again.
+	// Documents are named by a hash, which keeps the viewer's identity out of the database.
+	viewersCollection = "blog_views"
+)
+
+// Engagement counted per hour in blog_engagement.
+const (
+	engagementViews    = "views"
+	engagementLikes    = "likes"
+	engagementComments = "comments"
+)
+
+// recordEngagement adds delta to this hour's count of field for a blog. The buckets only feed
+// ranking, so a failure is logged rather than failing the request.
+func recordEngagement(ctx context.Context, blogID, field string, delta int) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	ref := FirestoreClient.Collection(engagementCollection).Doc(blogID + "_" + hour.Format("2006010215"))
+	_, err := ref.Set(ctx, map[string]interface{}{
+		"blog_id": blogID,
+		"hour":    hour,
+		field:     firestore.Increment(delta),
+	}, firestore.MergeAll)
+	if err != nil {
+		log.Printf("⚠️ Failed to record %s of blog %s: %v", field, blogID, err)
+	}
+}
+
+// firstViewThisHour records that viewer saw the blog this hour, reporting whether it is the first
+// time. When that cannot be told, the view is not counted.
+func firstViewThisHour(ctx context.Context, blogID, viewer string) bool {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	ref := FirestoreClient.Collection(viewersCollection).Doc(utils.HashToken(blogID + "\x00" + viewer + "\x00" + hour.Format("2006010215")))
+	_, err := ref.Create(ctx, map[string]interface{}{
+		"blog_id": blogID,
+		"hour":    hour,
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return false
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to record a view of blog %s: %v", blogID, err)
+		return false
+	}
+	return true
+}
+
+// GetEngagementSince returns the hourly engagement buckets of all blogs from since onwards.
+func GetEngagementSince(ctx context.Context, since time.Time) ([]models.EngagementBucket, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	var buckets []models.EngagementBucket
+	iter := FirestoreClient.Collection(engagementCollection).Where("hour", ">=", since).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return buckets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var b models.EngagementBucket
+		if err := doc.DataTo(&b); err != nil {
+			continue
+		}
+		buckets = append(buckets, b)
+	}
+}
+
+// DeleteEngagementBefore deletes the engagement buckets and viewer notes of hours before the given time.
+func DeleteEngagementBefore(ctx context.Context, before time.Time) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+	if err := deleteQuery(ctx, FirestoreClient.Collection(engagementCollection).Where("hour", "<", before)); err != nil {
+		return err
+	}
+	return deleteQuery(ctx, FirestoreClient.Collection(viewersCollection).Where("hour", "<", before))
+}
+
+// SetTrending marks exactly the blogs in scores as trending, storing their scores, and clears the
+// flag on every other blog.
+func SetTrending(ctx context.Context, scores map[string]float64) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	current, err := FirestoreClient.Collection(blogsCollection).Where("trending", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	type change struct {
+		ref     *firestore.DocumentRef
+		updates []firestore.Update
+	}
+	var changes []change
+	for _, doc := range current {
+		if _, ok := scores[doc.Ref.ID]; !ok {
+			changes = append(changes, change{doc.Ref, []firestore.Update{
+				{Path: "trending", Value: false},
+				{Path: "trending_score", Value: firestore.Delete},
+			}})
+		}
+	}
+	for id, score := range scores {
+		changes = append(changes, change{FirestoreClient.Collection(blogsCollection).Doc(id), []firestore.Update{
+			{Path: "trending", Value: true},
+			{Path: "trending_score", Value: score},
+		}})
+	}
+
+	// Batches are limited to 500 writes
+	const batchSize = 400
+	for start := 0; start < len(changes); start += batchSize {
+		end := start + batchSize
+		if end > len(changes) {
+			end = len(changes)
+		}
+		batch := FirestoreClient.Batch()
+		for _, c := range changes[start:end] {
+			batch.Update(c.ref, c.updates)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTrendingBlogs returns up to limit trending blogs, highest score first, optionally only those
+// in one category. Blogs of banned authors are left out.
+func GetTrendingBlogs(ctx context.Context, category string, limit int) ([]models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	// Only the top few blogs per category are trending, so they are filtered and sorted in memory
+	docs, err := FirestoreClient.Collection(blogsCollection).Where("trending", "==", true).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	blogs := make([]models.Blog, 0, len(docs))
+	for _, doc := range docs {
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil || !b.Published() {
+			continue
+		}
+		if category != "" && b.Category != category {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		blogs = append(blogs, b)
+	}
+	sort.Slice(blogs, func(i, j int) bool {
+		if blogs[i].TrendingScore != blogs[j].TrendingScore {
+			return blogs[i].TrendingScore > blogs[j].TrendingScore
+		}
+		return blogs[i].ID < blogs[j].ID
+	})
+
+	blogs, err = attachAuthors(ctx, blogs)
+	if err != nil {
+		return nil, err
+	}
+	if len(blogs) > limit {
+		blogs = blogs[:limit]
+	}
+	return blogs, nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const dataExportsCollection = "data_exports"
+
+// ErrDataExportNotFound is returned for export IDs that have no record.
+var ErrDataExportNotFound = errors.New("export not found")
+
+// CreateDataExport queues a new personal data export for a user.
+func CreateDataExport(ctx context.Context, userID string) (*models.DataExport, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(dataExportsCollection).NewDoc()
+	now := time.Now()
+	export := &models.DataExport{
+		ID:          ref.ID,
+		UserID:      userID,
+		Status:      models.DataExportPending,
+		RequestedAt: now,
+		UpdatedAt:   now,
+	}
+	if _, err := ref.Set(ctx, export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// GetDataExport retrieves a data export by ID.
+func GetDataExport(ctx context.Context, id string) (*models.DataExport, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := FirestoreClient.Collection(dataExportsCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrDataExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var export models.DataExport
+	if err := doc.DataTo(&export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetDataExports returns a user's data exports, newest first.
+func GetDataExports(ctx context.Context, userID string) ([]models.DataExport, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(dataExportsCollection).Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	exports := make([]models.DataExport, 0, len(docs))
+	for _, doc := range docs {
+		var e models.DataExport
+		if err := doc.DataTo(&e); err != nil {
+			continue
+		}
+		exports = append(exports, e)
+	}
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].RequestedAt.After(exports[j].RequestedAt)
+	})
+	return exports, nil
+}
+
+// UpdateDataExport applies updates to a data export and bumps its updated_at.
+func UpdateDataExport(ctx context.Context, id string, updates []firestore.Update) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	updates = append(updates, firestore.Update{Path: "updated_at", Value: time.Now()})
+	_, err := FirestoreClient.Collection(dataExportsCollection).Doc(id).Update(ctx, updates)
+	return err
+}
+
+// GetBlogsByAuthor fetches every blog written by a user, newest first.
+func GetBlogsByAuthor(ctx context.Context, authorID string) ([]models.Blog, error) {
+	return queryBlogs(ctx, FirestoreClient.Collection(blogsCollection).Where("author_id", "==", authorID))
+}
+
+// GetBlogsLikedBy fetches every blog a user has liked, newest first.
+func GetBlogsLikedBy(ctx context.Context, username string) ([]models.Blog, error) {
+	return queryBlogs(ctx, FirestoreClient.Collection(blogsCollection).Where("liked_by", "array-contains", username))
+}
+
+func queryBlogs(ctx context.Context, q firestore.Query) ([]models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := q.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	blogs := make([]models.Blog, 0, len(docs))
+	for _, doc := range docs {
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		blogs = append(blogs, b)
+	}
+	sort.Slice(blogs, func(i, j int) bool {
+		return blogs[i].CreatedAt.After(blogs[j].CreatedAt)
+	})
+	return blogs, nil
+}
+
+// GetCommentsByAuthor fetches every comment a user has written, oldest first.
+func GetCommentsByAuthor(ctx context.Context, authorID string) ([]models.Comment, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection("comments").Where("author_id", "==", authorID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]models.Comment, 0, len(docs))
+	for _, doc := range docs {
+		var c models.Comment
+		if err := doc.DataTo(&c); err != nil {
+			continue
+		}
+		c.CommentID = doc.Ref.ID
+		comments = append(comments, c)
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
+
+// ForEachMessage calls fn for every chat message the user sent or received, without loading the
+// whole history into memory. Sent messages are visited before received ones.
+func ForEachMessage(ctx context.Context, userID string, fn func(models.Message) error) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	for _, field := range []string{"sender_id", "receiver_id"} {
+		iter := FirestoreClient.Collection("messages").Where(field, "==", userID).Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return err
+			}
+			var msg models.Message
+			if err := doc.DataTo(&msg); err != nil {
+				continue
+			}
+			if field == "receiver_id" && msg.SenderID == userID {
+				continue // messages to oneself were already visited as sent
+			}
+			if err := fn(msg); err != nil {
+				iter.Stop()
+				return err
+			}
+		}
+		iter.Stop()
+	}
+	return nil
+}
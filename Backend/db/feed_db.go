@@ -0,0 +1,425 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const blogReadsCollection = "blog_reads"
+
+const (
+	// feedTrendingEvery puts a trending blog in every fifth slot of a feed page.
+	feedTrendingEvery = 5
+	// feedInLimit is how many values Firestore accepts in one "in" or "array-contains-any" filter.
+	feedInLimit = 30
+	// feedMaxAuthors bounds how many followed authors are queried for a page.
+	feedMaxAuthors = 600
+	// feedMaxRounds bounds how often a page is topped up after leaving out blogs already read.
+	feedMaxRounds = 4
+	// feedTrendingPool is how many trending blogs are considered for interleaving.
+	feedTrendingPool = 100
+	// feedMaxTrendingShown bounds the trending blogs remembered in the cursor.
+	feedMaxTrendingShown = 200
+)
+
+// feedCursor is the position after the last followed blog of a page, in newest-first order, and
+// the trending blogs shown so far, which are not ordered by time.
+type feedCursor struct {
+	PublishedAt time.Time `json:"t,omitempty"`
+	ID          string    `json:"id,omitempty"`
+	Done        bool      `json:"d,omitempty"` // followed blogs are exhausted; only trending ones remain
+	Trending    []string  `json:"tr,omitempty"`
+}
+
+func (c feedCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeFeedCursor(s string) (*feedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c feedCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// FollowTag adds a tag to the ones whose blogs appear in the user's feed.
+func FollowTag(ctx context.Context, userID, tag string) error {
+	return updateFollowedInterest(ctx, userID, "FollowedTags", firestore.ArrayUnion(tag))
+}
+
+// UnfollowTag removes a tag from the user's feed.
+func UnfollowTag(ctx context.Context, userID, tag string) error {
+	return updateFollowedInterest(ctx, userID, "FollowedTags", firestore.ArrayRemove(tag))
+}
+
+// FollowCategory adds a category to the ones whose blogs appear in the user's feed.
+func FollowCategory(ctx context.Context, userID, category string) error {
+	return updateFollowedInterest(ctx, userID, "FollowedCategories", firestore.ArrayUnion(category))
+}
+
+// UnfollowCategory removes a category from the user's feed.
+func UnfollowCategory(ctx context.Context, userID, category string) error {
+	return updateFollowedInterest(ctx, userID, "FollowedCategories", firestore.ArrayRemove(category))
+}
+
+func updateFollowedInterest(ctx context.Context, userID, field string, value interface{}) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: field, Value: value},
+	})
+	return err
+}
+
+// RecordRead notes that the user opened a blog, so it no longer shows up in their feed.
+func RecordRead(ctx context.Context, userID, blogID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(blogReadsCollection).Doc(userID+"_"+blogID).Set(ctx, models.BlogRead{
+		UserID: userID,
+		BlogID: blogID,
+		ReadAt: time.Now(),
+	})
+	return err
+}
+
+// readBlogs returns the set of the given blogs the user has read, with one batched read.
+func readBlogs(ctx context.Context, userID string, blogs []models.Blog) (map[string]bool, error) {
+	read := make(map[string]bool)
+	if len(blogs) == 0 {
+		return read, nil
+	}
+	refs := make([]*firestore.DocumentRef, len(blogs))
+	for i, b := range blogs {
+		refs[i] = FirestoreClient.Collection(blogReadsCollection).Doc(userID + "_" + b.ID)
+	}
+	docs, err := FirestoreClient.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for i, doc := range docs {
+		if doc.Exists() {
+			read[blogs[i].ID] = true
+		}
+	}
+	return read, nil
+}
+
+// feedInterests are what a user follows.
+type feedInterests struct {
+	authors    map[string]bool
+	tags       map[string]bool
+	categories map[string]bool
+}
+
+// reason says why a followed blog is in the feed, preferring the most personal reason.
+func (in *feedInterests) reason(b *models.Blog) string {
+	if in.authors[b.AuthorID] {
+		return models.FeedReasonFollowing
+	}
+	for _, tag := range b.Tags {
+		if in.tags[tag] {
+			return models.FeedReasonTag
+		}
+	}
+	if in.categories[b.Category] {
+		return models.FeedReasonCategory
+	}
+	return ""
+}
+
+// queries are the Firestore queries that together find every followed blog, newest first.
+func (in *feedInterests) queries() []firestore.Query {
+	base := FirestoreClient.Collection(blogsCollection).Where("status", "==", models.BlogStatusPublished)
+	var queries []firestore.Query
+	add := func(path, op string, values map[string]bool, max int) {
+		list := make([]string, 0, len(values))
+		for v := range values {
+			list = append(list, v)
+		}
+		sort.Strings(list)
+		if len(list) > max {
+			list = list[:max]
+		}
+		for start := 0; start < len(list); start += feedInLimit {
+			end := start + feedInLimit
+			if end > len(list) {
+				end = len(list)
+			}
+			queries = append(queries, base.Where(path, op, list[start:end]).
+				OrderBy("published_at", firestore.Desc).
+				OrderBy(firestore.DocumentID, firestore.Desc))
+		}
+	}
+	add("author_id", "in", in.authors, feedMaxAuthors)
+	add("tags", "array-contains-any", in.tags, len(in.tags))
+	add("category", "in", in.categories, len(in.categories))
+	return queries
+}
+
+// GetFeed returns one page of the user's feed: blogs from the authors, tags and categories they
+// follow, newest first, with every fifth slot given to a trending blog. Blogs the user has read,
+// their own blogs and those of banned authors are left out, so a page may be shorter than the
+// limit while NextCursor is still set. Users who follow nothing get trending blogs only.
+func GetFeed(ctx context.Context, q models.FeedQuery) (*models.FeedPage, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = models.DefaultBlogPageSize
+	}
+	if q.Limit > models.MaxBlogPageSize {
+		q.Limit = models.MaxBlogPageSize
+	}
+	cursor := &feedCursor{}
+	if q.Cursor != "" {
+		var err error
+		if cursor, err = decodeFeedCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := GetUserByID(ctx, q.UserID)
+	if err != nil {
+		return nil, err
+	}
+	following, err := GetFollowing(ctx, q.UserID)
+	if err != nil {
+		return nil, err
+	}
+	in := &feedInterests{
+		authors:    make(map[string]bool),
+		tags:       make(map[string]bool),
+		categories: make(map[string]bool),
+	}
+	for _, id := range following {
+		in.authors[id] = true
+	}
+	for _, tag := range user.FollowedTags {
+		in.tags[tag] = true
+	}
+	for _, category := range user.FollowedCategories {
+		in.categories[category] = true
+	}
+
+	var followed []models.FeedItem
+	more := false
+	if queries := in.queries(); !cursor.Done && len(queries) > 0 {
+		want := q.Limit - q.Limit/feedTrendingEvery
+		if followed, more, err = followedBlogs(ctx, q.UserID, in, queries, cursor, want); err != nil {
+			return nil, err
+		}
+	}
+
+	// Once followed blogs run out, trending ones fill the rest of the page
+	slots := q.Limit / feedTrendingEvery
+	if !more {
+		slots = q.Limit - len(followed)
+	}
+	trending, trendingLeft, err := trendingForFeed(ctx, q.UserID, in, cursor, slots)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.FeedPage{Items: interleaveFeed(followed, trending)}
+	if more || trendingLeft {
+		cursor.Done = !more
+		page.NextCursor = cursor.encode()
+	}
+	return page, nil
+}
+
+// followedBlogs collects up to want followed blogs after the cursor, moving the cursor past every
+// blog it looked at, and reports whether more may follow.
+func followedBlogs(ctx context.Context, userID string, in *feedInterests, queries []firestore.Query, cursor *feedCursor, want int) ([]models.FeedItem, bool, error) {
+	var items []models.FeedItem
+	for round := 0; round < feedMaxRounds; round++ {
+		batch, full, err := fetchFollowed(ctx, queries, cursor, want-len(items)+1)
+		if err != nil {
+			return nil, false, err
+		}
+		keep, err := feedVisible(ctx, userID, batch)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, b := range batch {
+			if len(items) == want {
+				return items, true, nil
+			}
+			cursor.PublishedAt, cursor.ID = b.PublishedAt, b.ID
+			if kept, ok := keep[b.ID]; ok {
+				items = append(items, models.FeedItem{Blog: kept, Reason: in.reason(&kept)})
+			}
+		}
+		if !full {
+			return items, false, nil
+		}
+		if len(items) == want {
+			return items, true, nil
+		}
+	}
+	return items, true, nil
+}
+
+// fetchFollowed runs the feed queries in parallel from the cursor and merges their results into
+// the newest n blogs overall. full reports whether more blogs may follow those n.
+func fetchFollowed(ctx context.Context, queries []firestore.Query, cursor *feedCursor, n int) ([]models.Blog, bool, error) {
+	results := make([][]models.Blog, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		if cursor.ID != "" {
+			query = query.StartAfter(cursor.PublishedAt, cursor.ID)
+		}
+		wg.Add(1)
+		go func(i int, query firestore.Query) {
+			defer wg.Done()
+			docs, err := query.Limit(n).Documents(ctx).GetAll()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for _, doc := range docs {
+				var b models.Blog
+				if err := doc.DataTo(&b); err != nil {
+					continue
+				}
+				b.ID = doc.Ref.ID
+				results[i] = append(results[i], b)
+			}
+		}(i, query)
+	}
+	wg.Wait()
+
+	full := false
+	seen := make(map[string]bool)
+	var merged []models.Blog
+	for i, blogs := range results {
+		if errs[i] != nil {
+			return nil, false, errs[i]
+		}
+		if len(blogs) == n {
+			full = true
+		}
+		// A blog can match several queries, e.g. a followed author's post in a followed category
+		for _, b := range blogs {
+			if !seen[b.ID] {
+				seen[b.ID] = true
+				merged = append(merged, b)
+			}
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].PublishedAt.Equal(merged[j].PublishedAt) {
+			return merged[i].PublishedAt.After(merged[j].PublishedAt)
+		}
+		return merged[i].ID > merged[j].ID
+	})
+	if len(merged) > n {
+		merged = merged[:n]
+		full = true
+	}
+	return merged, full, nil
+}
+
+// feedVisible returns the blogs of a batch that belong in the user's feed, with their authors
+// filled in: not the user's own, not read yet and not by a banned author.
+func feedVisible(ctx context.Context, userID string, blogs []models.Blog) (map[string]models.Blog, error) {
+	read, err := readBlogs(ctx, userID, blogs)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]models.Blog, 0, len(blogs))
+	for _, b := range blogs {
+		if b.AuthorID != userID && !read[b.ID] {
+			candidates = append(candidates, b)
+		}
+	}
+	visible, err := attachAuthors(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]models.Blog, len(visible))
+	for _, b := range visible {
+		keep[b.ID] = b
+	}
+	return keep, nil
+}
+
+// trendingForFeed picks up to slots trending blogs the user has not seen yet, remembering them in
+// the cursor, and reports whether more are left. Blogs the user follows anyway are skipped, since
+// they appear among the followed blogs in their turn.
+func trendingForFeed(ctx context.Context, userID string, in *feedInterests, cursor *feedCursor, slots int) ([]models.FeedItem, bool, error) {
+	blogs, err := GetTrendingBlogs(ctx, "", feedTrendingPool)
+	if err != nil {
+		return nil, false, err
+	}
+
+	shown := make(map[string]bool, len(cursor.Trending))
+	for _, id := range cursor.Trending {
+		shown[id] = true
+	}
+	candidates := make([]models.Blog, 0, len(blogs))
+	for _, b := range blogs {
+		if !shown[b.ID] && b.AuthorID != userID && in.reason(&b) == "" {
+			candidates = append(candidates, b)
+		}
+	}
+	read, err := readBlogs(ctx, userID, candidates)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var items []models.FeedItem
+	left := false
+	for _, b := range candidates {
+		if read[b.ID] {
+			continue
+		}
+		if len(items) == slots {
+			left = true
+			break
+		}
+		items = append(items, models.FeedItem{Blog: b, Reason: models.FeedReasonTrending})
+		cursor.Trending = append(cursor.Trending, b.ID)
+	}
+	if len(cursor.Trending) > feedMaxTrendingShown {
+		cursor.Trending = cursor.Trending[len(cursor.Trending)-feedMaxTrendingShown:]
+	}
+	return items, left, nil
+}
+
+// interleaveFeed gives every fifth slot to a trending blog and appends the trending blogs left
+// over when there are not enough followed ones.
+func interleaveFeed(followed, trending []models.FeedItem) []models.FeedItem {
+	items := make([]models.FeedItem, 0, len(followed)+len(trending))
+	for _, item := range followed {
+		if len(trending) > 0 && (len(items)+1)%feedTrendingEvery == 0 {
+			items = append(items, trending[0])
+			trending = trending[1:]
+		}
+		items = append(items, item)
+	}
+	return append(items, trending...)
+}
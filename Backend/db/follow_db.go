@@ -144,9 +144,12 @@ func GetNetwork(ctx context.Context, userID string) (*models.Followers, error) {
 				var u models.User
 				userDoc.DataTo(&u)
 				profiles = append(profiles, models.FollowUser{
-					FullName: u.FullName,
-					Username: u.Username,
-					Email:    u.Email,
+					ID:        id,
+					FullName:  u.FullName,
+					Username:  u.Username,
+					Email:     u.Email,
+					AvatarURL: u.AvatarURL,
+					Bio:       u.Bio,
 				})
 			}
 		}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// identities holds one entry per linked external identity, so that two requests linking the same
+// Google account at the same time cannot attach it to two users.
+const identityClaimsCollection = "identities"
+
+type identityClaim struct {
+	UserID    string    `firestore:"user_id"`
+	Provider  string    `firestore:"provider"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// ErrIdentityInUse is returned when an external identity is already linked to another account.
+var ErrIdentityInUse = errors.New("this account is already linked to another user")
+
+// ErrLastLoginMethod is returned when unlinking would leave an account with no way to sign in.
+var ErrLastLoginMethod = errors.New("cannot remove the only way to sign in; set a password first")
+
+// GetUserByIdentity retrieves the user an external identity is linked to.
+// Accounts created before identities were a list are matched on the legacy provider fields.
+func GetUserByIdentity(ctx context.Context, provider, providerID string) (*models.User, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	users := FirestoreClient.Collection(usersCollection)
+	doc, err := users.Where("IdentityKeys", "array-contains", models.IdentityKey(provider, providerID)).Limit(1).Documents(ctx).Next()
+	if err != nil {
+		doc, err = users.Where("AuthProvider", "==", provider).Where("ProviderID", "==", providerID).Limit(1).Documents(ctx).Next()
+		if err != nil {
+			return nil, errors.New("user not found")
+		}
+	}
+
+	var user models.User
+	if err := doc.DataTo(&user); err != nil {
+		return nil, err
+	}
+	user.ID = doc.Ref.ID
+	user.Password = ""
+	return &user, nil
+}
+
+// GetLoginMethods reports whether a user has a password and which external identities are linked.
+func GetLoginMethods(ctx context.Context, userID string) (bool, []models.LinkedIdentity, error) {
+	if FirestoreClient == nil {
+		return false, nil, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := FirestoreClient.Collection(usersCollection).Doc(userID).Get(ctx)
+	if err != nil {
+		return false, nil, errors.New("user not found")
+	}
+
+	var user models.User
+	if err := doc.DataTo(&user); err != nil {
+		return false, nil, err
+	}
+	return user.Password != "", identitiesOf(&user), nil
+}
+
+// LinkIdentity connects an external identity to a user, migrating any legacy provider fields.
+func LinkIdentity(ctx context.Context, userID string, identity models.LinkedIdentity) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	userRef := FirestoreClient.Collection(usersCollection).Doc(userID)
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return errors.New("user not found")
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if err := checkIdentityFree(tx, identity, userID); err != nil {
+			return err
+		}
+
+		identities := identitiesOf(&user)
+		for _, id := range identities {
+			if id.Provider != identity.Provider {
+				continue
+			}
+			if id.ProviderID == identity.ProviderID {
+				// Already linked; rewriting still migrates legacy fields and claims the identity
+				if err := claimIdentity(tx, identity, userID); err != nil {
+					return err
+				}
+				return tx.Update(userRef, identityUpdates(identities))
+			}
+			return errors.New("a " + identity.Provider + " account is already linked; unlink it first")
+		}
+
+		if identity.LinkedAt.IsZero() {
+			identity.LinkedAt = time.Now()
+		}
+		identities = append(identities, identity)
+		if err := claimIdentity(tx, identity, userID); err != nil {
+			return err
+		}
+		return tx.Update(userRef, identityUpdates(identities))
+	})
+}
+
+// UnlinkIdentity disconnects a provider from a user as long as another way to sign in remains.
+func UnlinkIdentity(ctx context.Context, userID, provider string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	userRef := FirestoreClient.Collection(usersCollection).Doc(userID)
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return errors.New("user not found")
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		var remaining, removed []models.LinkedIdentity
+		for _, id := range identitiesOf(&user) {
+			if id.Provider == provider {
+				removed = append(removed, id)
+				continue
+			}
+			remaining = append(remaining, id)
+		}
+		if len(removed) == 0 {
+			return errors.New("no " + provider + " account is linked")
+		}
+		if user.Password == "" && len(remaining) == 0 {
+			return ErrLastLoginMethod
+		}
+
+		// Only claims this user holds are released; links made before claims existed have none
+		var held []models.LinkedIdentity
+		for _, id := range removed {
+			holds, err := holdsIdentity(tx, id, userID)
+			if err != nil {
+				return err
+			}
+			if holds {
+				held = append(held, id)
+			}
+		}
+		for _, id := range held {
+			if err := releaseIdentity(tx, id); err != nil {
+				return err
+			}
+		}
+		return tx.Update(userRef, identityUpdates(remaining))
+	})
+}
+
+// checkIdentityFree fails with ErrIdentityInUse if another user holds the identity. Its reads
+// take part in tx and must come before the transaction's writes; claimIdentity then records it.
+func checkIdentityFree(tx *firestore.Transaction, identity models.LinkedIdentity, userID string) error {
+	doc, err := tx.Get(identityRef(identity))
+	if err == nil && doc.Exists() {
+		var claim identityClaim
+		if err := doc.DataTo(&claim); err != nil {
+			return err
+		}
+		if claim.UserID != userID {
+			return ErrIdentityInUse
+		}
+		return nil
+	}
+
+	// Identities linked before the claims existed are only found on the user documents
+	users := FirestoreClient.Collection(usersCollection)
+	for _, query := range []firestore.Query{
+		users.Where("IdentityKeys", "array-contains", models.IdentityKey(identity.Provider, identity.ProviderID)).Limit(2),
+		users.Where("AuthProvider", "==", identity.Provider).Where("ProviderID", "==", identity.ProviderID).Limit(2),
+	} {
+		docs, err := tx.Documents(query).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if doc.Ref.ID != userID {
+				return ErrIdentityInUse
+			}
+		}
+	}
+	return nil
+}
+
+// holdsIdentity reports whether the user's claim is the one recorded for the identity, reading inside tx.
+func holdsIdentity(tx *firestore.Transaction, identity models.LinkedIdentity, userID string) (bool, error) {
+	doc, err := tx.Get(identityRef(identity))
+	if err != nil || !doc.Exists() {
+		return false, nil
+	}
+	var claim identityClaim
+	if err := doc.DataTo(&claim); err != nil {
+		return false, err
+	}
+	return claim.UserID == userID, nil
+}
+
+// claimIdentity records the identity as the user's inside tx, once checkIdentityFree has passed.
+func claimIdentity(tx *firestore.Transaction, identity models.LinkedIdentity, userID string) error {
+	return tx.Set(identityRef(identity), identityClaim{
+		UserID:    userID,
+		Provider:  identity.Provider,
+		CreatedAt: time.Now(),
+	})
+}
+
+// releaseIdentity frees an identity the user has unlinked, inside tx.
+func releaseIdentity(tx *firestore.Transaction, identity models.LinkedIdentity) error {
+	return tx.Delete(identityRef(identity))
+}
+
+// identityRef names claims by a hash of the identity key, since provider IDs may contain
+// characters document IDs cannot.
+func identityRef(identity models.LinkedIdentity) *firestore.DocumentRef {
+	return FirestoreClient.Collection(identityClaimsCollection).Doc(utils.HashToken(models.IdentityKey(identity.Provider, identity.ProviderID)))
+}
+
+// identitiesOf returns a user's linked identities, including one described by the legacy fields.
+func identitiesOf(user *models.User) []models.LinkedIdentity {
+	identities := append([]models.LinkedIdentity(nil), user.Identities...)
+	if user.LegacyAuthProvider != "" && user.LegacyProviderID != "" {
+		for _, id := range identities {
+			if id.Provider == user.LegacyAuthProvider {
+				return identities
+			}
+		}
+		identities = append(identities, models.LinkedIdentity{
+			Provider:   user.LegacyAuthProvider,
+			ProviderID: user.LegacyProviderID,
+			Email:      user.Email,
+			LinkedAt:   user.CreatedAt,
+		})
+	}
+	return identities
+}
+
+// identityUpdates rewrites the identity list and its lookup keys, dropping the legacy fields.
+func identityUpdates(identities []models.LinkedIdentity) []firestore.Update {
+	keys := make([]string, 0, len(identities))
+	for _, id := range identities {
+		keys = append(keys, models.IdentityKey(id.Provider, id.ProviderID))
+	}
+	if identities == nil {
+		identities = []models.LinkedIdentity{}
+	}
+	return []firestore.Update{
+		{Path: "Identities", Value: identities},
+		{Path: "IdentityKeys", Value: keys},
+		{Path: "AuthProvider", Value: firestore.Delete},
+		{Path: "ProviderID", Value: firestore.Delete},
+	}
+}
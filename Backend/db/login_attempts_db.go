@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/loginguard"
+	"github.com/prachin77/insight-hub/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const loginAttemptsCollection = "login_attempts"
+
+// LoginAttemptStore is a loginguard.Store backed by Firestore, so every instance of the
+// server sees the same failure counters.
+type LoginAttemptStore struct{}
+
+func (LoginAttemptStore) Get(ctx context.Context, key string) (loginguard.Record, error) {
+	var rec loginguard.Record
+	if FirestoreClient == nil {
+		return rec, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := loginAttemptRef(key).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, err
+	}
+	err = doc.DataTo(&rec)
+	return rec, err
+}
+
+func (LoginAttemptStore) Update(ctx context.Context, key string, fn func(*loginguard.Record)) (loginguard.Record, error) {
+	var rec loginguard.Record
+	if FirestoreClient == nil {
+		return rec, errors.New("firestore client is not initialized")
+	}
+
+	ref := loginAttemptRef(key)
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		rec = loginguard.Record{}
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&rec); err != nil {
+				return err
+			}
+		}
+		fn(&rec)
+		return tx.Set(ref, rec)
+	})
+	return rec, err
+}
+
+func (LoginAttemptStore) Delete(ctx context.Context, key string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+	_, err := loginAttemptRef(key).Delete(ctx)
+	return err
+}
+
+// loginAttemptRef hashes the key so emails and IPs are not used verbatim as document IDs.
+func loginAttemptRef(key string) *firestore.DocumentRef {
+	return FirestoreClient.Collection(loginAttemptsCollection).Doc(utils.HashToken(key))
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"google.golang.org/api/iterator"
+)
+
+// GetUserRole returns a user's role, RoleUser if none is stored.
+func GetUserRole(ctx context.Context, userID string) (models.Role, error) {
+	user, err := GetUserByID(ctx, userID)
+	if err != nil {
+		return "", errors.New("user not found")
+	}
+	return user.Role.OrDefault(), nil
+}
+
+// SetUserRole changes a user's role.
+func SetUserRole(ctx context.Context, userID string, role models.Role) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	var value interface{} = role
+	if role == models.RoleUser {
+		value = firestore.Delete
+	}
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "Role", Value: value},
+	})
+	if err != nil {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// AdminExists reports whether at least one user has the admin role.
+func AdminExists(ctx context.Context) (bool, error) {
+	if FirestoreClient == nil {
+		return false, errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Where("Role", "==", models.RoleAdmin).Limit(1).Documents(ctx).Next()
+	if err == iterator.Done {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetBlogFeatured features or unfeatures a blog.
+func SetBlogFeatured(ctx context.Context, blogID string, featured bool) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(blogsCollection).Doc(blogID).Update(ctx, []firestore.Update{
+		{Path: "featured", Value: featured},
+	})
+	if err != nil {
+		return errors.New("blog not found")
+	}
+	return nil
+}
+
+// RemoveComment deletes a comment together with its replies and corrects the blog's comment count.
+func RemoveComment(ctx context.Context, commentID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	comments := FirestoreClient.Collection("comments")
+	doc, err := comments.Doc(commentID).Get(ctx)
+	if err != nil {
+		return errors.New("comment not found")
+	}
+	var comment models.Comment
+	if err := doc.DataTo(&comment); err != nil {
+		return err
+	}
+
+	// Walk the reply tree breadth first so no reply is left pointing at a removed parent
+	toDelete := []*firestore.DocumentRef{doc.Ref}
+	for i := 0; i < len(toDelete); i++ {
+		replies, err := comments.Where("parent_id", "==", toDelete[i].ID).Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		for _, reply := range replies {
+			toDelete = append(toDelete, reply.Ref)
+		}
+	}
+
+	for _, ref := range toDelete {
+		if _, err := ref.Delete(ctx); err != nil {
+			return err
+		}
+	}
+
+	_, _ = FirestoreClient.Collection(blogsCollection).Doc(comment.BlogID).Update(ctx, []firestore.Update{
+		{Path: "comments", Value: firestore.Increment(-len(toDelete))},
+	})
+	return nil
+}
+
+// SuspendUser stores a suspension on a user, replacing any earlier one. A ban also takes the
+// user's blogs out of the search index.
+func SuspendUser(ctx context.Context, userID string, suspension models.Suspension) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "Suspension", Value: suspension},
+	})
+	if err != nil {
+		return errors.New("user not found")
+	}
+	return reindexAuthor(ctx, userID, !suspension.Banned())
+}
+
+// LiftSuspension removes a user's suspension and puts their blogs back in the search index.
+func LiftSuspension(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "Suspension", Value: firestore.Delete},
+	})
+	if err != nil {
+		return errors.New("user not found")
+	}
+	return reindexAuthor(ctx, userID, true)
+}
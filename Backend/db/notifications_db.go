@@ -61,12 +61,25 @@ func GetNotifications(ctx context.Context, userID string) ([]models.Notification
 	return notifications, nil
 }
 
-func MarkNotificationRead(ctx context.Context, id string) error {
+// MarkNotificationRead marks a notification as read if it belongs to the given user.
+func MarkNotificationRead(ctx context.Context, id, userID string) error {
 	if FirestoreClient == nil {
 		return errors.New("firestore client is not initialized")
 	}
 
-	_, err := FirestoreClient.Collection(notificationsCollection).Doc(id).Update(ctx, []firestore.Update{
+	doc, err := FirestoreClient.Collection(notificationsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return errors.New("notification not found")
+	}
+	var n models.Notification
+	if err := doc.DataTo(&n); err != nil {
+		return err
+	}
+	if n.Recipient != userID {
+		return errors.New("you can only mark your own notifications as read")
+	}
+
+	_, err = doc.Ref.Update(ctx, []firestore.Update{
 		{Path: "is_read", Value: true},
 	})
 	return err
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"google.golang.org/api/iterator"
+)
+
+// UpdateProfile applies profile field updates to a user document. An avatar that is an uploaded
+// image marks the upload as used in the same transaction, or fails with ErrUploadDeleting if it
+// is being garbage collected.
+func UpdateProfile(ctx context.Context, userID string, updates []firestore.Update) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	ref := FirestoreClient.Collection(usersCollection).Doc(userID)
+	avatarUploads := avatarUploadsOf(updates)
+	if len(avatarUploads) == 0 {
+		_, err := ref.Update(ctx, updates)
+		return err
+	}
+
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		uploads, err := useUploads(tx, avatarUploads)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(ref, updates); err != nil {
+			return err
+		}
+		return touchUploads(tx, uploads)
+	})
+}
+
+// ChangeUsername moves a user to a new username, claiming it in the username index and
+// releasing the old one in the same transaction, which also applies any other profile updates.
+// Copies of the username stored on comments and in blog like lists are rewritten afterwards.
+func ChangeUsername(ctx context.Context, userID, newUsername string, updates []firestore.Update) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	userRef := FirestoreClient.Collection(usersCollection).Doc(userID)
+	var oldUsername string
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return errors.New("user not found")
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		oldUsername = user.Username
+
+		renamed := oldUsername != newUsername
+		if renamed {
+			taken, err := usernameTaken(ctx, tx, newUsername, userID)
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrUsernameTaken
+			}
+		}
+		uploads, err := useUploads(tx, avatarUploadsOf(updates))
+		if err != nil {
+			return err
+		}
+
+		if err := touchUploads(tx, uploads); err != nil {
+			return err
+		}
+		if !renamed {
+			if len(updates) == 0 {
+				return nil
+			}
+			return tx.Update(userRef, updates)
+		}
+		if usernameKey(oldUsername) != usernameKey(newUsername) {
+			if err := releaseUsername(tx, oldUsername); err != nil {
+				return err
+			}
+		}
+		if err := tx.Set(FirestoreClient.Collection(usernamesCollection).Doc(usernameKey(newUsername)), usernameEntry{
+			UserID:    userID,
+			Username:  newUsername,
+			CreatedAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+		return tx.Update(userRef, append([]firestore.Update{{Path: "Username", Value: newUsername}}, updates...))
+	})
+	if err != nil {
+		return err
+	}
+
+	if oldUsername != "" && oldUsername != newUsername {
+		propagateUsernameChange(ctx, userID, oldUsername, newUsername)
+	}
+	return nil
+}
+
+// avatarUploadsOf returns the uploaded image a profile update points the avatar at, if any.
+func avatarUploadsOf(updates []firestore.Update) []string {
+	var ids []string
+	for _, u := range updates {
+		if id, ok := u.Value.(string); ok && u.Path == "AvatarUpload" && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// propagateUsernameChange rewrites denormalized copies of a username. Failures are logged
+// rather than returned because the rename itself has already happened.
+func propagateUsernameChange(ctx context.Context, userID, oldUsername, newUsername string) {
+	comments := FirestoreClient.Collection("comments").Where("author_id", "==", userID).Documents(ctx)
+	for {
+		doc, err := comments.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Printf("⚠️ Failed to list comments of %s for rename: %v", userID, err)
+			break
+		}
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "author_username", Value: newUsername}}); err != nil {
+			log.Printf("⚠️ Failed to rename comment author %s: %v", doc.Ref.ID, err)
+		}
+	}
+
+	liked := FirestoreClient.Collection(blogsCollection).Where("liked_by", "array-contains", oldUsername).Documents(ctx)
+	for {
+		doc, err := liked.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Printf("⚠️ Failed to list blogs liked by %s for rename: %v", oldUsername, err)
+			break
+		}
+		// Two updates: Firestore rejects an ArrayRemove and ArrayUnion on the same field in one write
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "liked_by", Value: firestore.ArrayUnion(newUsername)}}); err != nil {
+			log.Printf("⚠️ Failed to rename like on blog %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "liked_by", Value: firestore.ArrayRemove(oldUsername)}}); err != nil {
+			log.Printf("⚠️ Failed to rename like on blog %s: %v", doc.Ref.ID, err)
+		}
+	}
+}
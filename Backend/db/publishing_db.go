@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"google.golang.org/api/iterator"
+)
+
+// ErrAlreadyPublished is returned when scheduling or publishing a blog that readers can already see.
+var ErrAlreadyPublished = errors.New("blog is already published")
+
+// errNotDue stops the publishing transaction of a blog that was published or rescheduled meanwhile.
+var errNotDue = errors.New("blog is no longer due")
+
+// unscheduleUpdates turns a scheduled blog back into a draft.
+func unscheduleUpdates(b *models.Blog) []firestore.Update {
+	b.Status = models.BlogStatusDraft
+	b.PublishAt = time.Time{}
+	return []firestore.Update{
+		{Path: "status", Value: b.Status},
+		{Path: "publish_at", Value: firestore.Delete},
+	}
+}
+
+// ScheduleBlog sets a draft or scheduled blog to be published at the given time.
+func ScheduleBlog(ctx context.Context, blogID string, at time.Time) (*models.Blog, error) {
+	return changeUnpublishedBlog(ctx, blogID, func(b *models.Blog) []firestore.Update {
+		b.Status = models.BlogStatusScheduled
+		b.PublishAt = at
+		return []firestore.Update{
+			{Path: "status", Value: b.Status},
+			{Path: "publish_at", Value: at},
+		}
+	})
+}
+
+// UnscheduleBlog turns a scheduled blog back into a draft.
+func UnscheduleBlog(ctx context.Context, blogID string) (*models.Blog, error) {
+	return changeUnpublishedBlog(ctx, blogID, unscheduleUpdates)
+}
+
+// PublishBlog publishes a draft or scheduled blog now. Notifying followers is left to the caller.
+func PublishBlog(ctx context.Context, blogID string) (*models.Blog, error) {
+	blog, err := changeUnpublishedBlog(ctx, blogID, publishUpdates)
+	if err != nil {
+		return nil, err
+	}
+	afterPublish(ctx, blog)
+	return blog, nil
+}
+
+// PublishDueBlogs publishes up to limit scheduled blogs whose time has come and returns them.
+// A blog is only returned by the instance whose transaction published it, so followers are
+// notified once even when several instances run the scheduler. Blogs of suspended authors are
+// turned back into drafts instead.
+func PublishDueBlogs(ctx context.Context, now time.Time, limit int) ([]models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(blogsCollection).
+		Where("status", "==", models.BlogStatusScheduled).
+		Where("publish_at", "<=", now).
+		OrderBy("publish_at", firestore.Asc).
+		Limit(limit).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var published []models.Blog
+	for _, doc := range docs {
+		var blog models.Blog
+		held := false
+		err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			snap, err := tx.Get(doc.Ref)
+			if err != nil {
+				return err
+			}
+			blog = models.Blog{}
+			if err := snap.DataTo(&blog); err != nil {
+				return err
+			}
+			blog.ID = snap.Ref.ID
+			if blog.Status != models.BlogStatusScheduled || blog.PublishAt.After(now) {
+				return errNotDue
+			}
+
+			// Suspended authors cannot publish, so their queued blogs go back to drafts
+			held = false
+			if blog.AuthorID != "" {
+				authorDoc, err := tx.Get(FirestoreClient.Collection(usersCollection).Doc(blog.AuthorID))
+				if err == nil && authorDoc.Exists() {
+					var author models.User
+					if err := authorDoc.DataTo(&author); err != nil {
+						return err
+					}
+					held = author.Suspension.Active(now)
+				}
+			}
+			if held {
+				return tx.Update(doc.Ref, unscheduleUpdates(&blog))
+			}
+			return tx.Update(doc.Ref, publishUpdates(&blog))
+		})
+		if errors.Is(err, errNotDue) {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		if held {
+			log.Printf("⏸️ Scheduled blog %s moved back to drafts: its author is suspended", blog.ID)
+			continue
+		}
+		afterPublish(ctx, &blog)
+		published = append(published, blog)
+	}
+	return published, nil
+}
+
+// changeUnpublishedBlog applies change to a blog that is still a draft or scheduled.
+func changeUnpublishedBlog(ctx context.Context, blogID string, change func(*models.Blog) []firestore.Update) (*models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(blogsCollection).Doc(blogID)
+	var blog models.Blog
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return errors.New("blog not found")
+		}
+		blog = models.Blog{}
+		if err := doc.DataTo(&blog); err != nil {
+			return err
+		}
+		blog.ID = doc.Ref.ID
+		if blog.Published() {
+			return ErrAlreadyPublished
+		}
+		updates := change(&blog)
+		blog.UpdatedAt = time.Now()
+		return tx.Update(ref, append(updates, firestore.Update{Path: "updated_at", Value: blog.UpdatedAt}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &blog, nil
+}
+
+func publishUpdates(b *models.Blog) []firestore.Update {
+	b.Status = models.BlogStatusPublished
+	b.PublishAt = time.Time{}
+	b.PublishedAt = time.Now()
+	return []firestore.Update{
+		{Path: "status", Value: b.Status},
+		{Path: "publish_at", Value: firestore.Delete},
+		{Path: "published_at", Value: b.PublishedAt},
+	}
+}
+
+// afterPublish does what CreateBlog does for a blog published straight away: count it towards
+// the author's blogs and make it searchable.
+func afterPublish(ctx context.Context, blog *models.Blog) {
+	if blog.AuthorID != "" {
+		_, _ = FirestoreClient.Collection(usersCollection).Doc(blog.AuthorID).Update(ctx, []firestore.Update{
+			{Path: "NoOfBlogs", Value: firestore.Increment(1)},
+		})
+	}
+	indexBlog(blog)
+}
+
+// NotifyFollowersOfBlog tells the author's followers that the blog has been published.
+func NotifyFollowersOfBlog(ctx context.Context, blog *models.Blog, authorUsername string) error {
+	followers, err := GetFollowers(ctx, blog.AuthorID)
+	if err != nil {
+		return err
+	}
+	for _, followerID := range followers {
+		_ = CreateNotification(ctx, &models.Notification{
+			Recipient: followerID,
+			Sender:    authorUsername,
+			Type:      models.NotificationTypeBlog,
+			Message:   authorUsername + " published a new blog \"" + blog.Title + "\"",
+			BlogID:    blog.ID,
+		})
+	}
+	return nil
+}
+
+// GetDraftsByAuthor returns the author's drafts and scheduled blogs, most recently edited first.
+func GetDraftsByAuthor(ctx context.Context, authorID string) ([]models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(blogsCollection).
+		Where("author_id", "==", authorID).
+		Where("status", "in", []string{models.BlogStatusDraft, models.BlogStatusScheduled}).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := make([]models.Blog, 0, len(docs))
+	for _, doc := range docs {
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		drafts = append(drafts, b)
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].UpdatedAt.After(drafts[j].UpdatedAt)
+	})
+	return drafts, nil
+}
+
+// BackfillBlogStatus marks blogs written before drafts existed as published, using their creation
+// time as the publication time, so the list and search queries keep finding them.
+func BackfillBlogStatus(ctx context.Context) (int, error) {
+	if FirestoreClient == nil {
+		return 0, errors.New("firestore client is not initialized")
+	}
+
+	count := 0
+	iter := FirestoreClient.Collection(blogsCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil || b.Status != "" {
+			continue
+		}
+		_, err = doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "status", Value: models.BlogStatusPublished},
+			{Path: "published_at", Value: b.CreatedAt},
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
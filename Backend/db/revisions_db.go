@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const blogRevisionsCollection = "blog_revisions"
+
+// ErrRevisionNotFound is returned for revision numbers the blog does not have.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// revisionRef names revisions "<blog id>_<number>" so that creating one twice fails instead of
+// overwriting it.
+func revisionRef(blogID string, number int) *firestore.DocumentRef {
+	return FirestoreClient.Collection(blogRevisionsCollection).Doc(fmt.Sprintf("%s_%06d", blogID, number))
+}
+
+func revisionOf(b *models.Blog, number int, at time.Time) models.BlogRevision {
+	format := b.ContentFormat
+	if format == "" {
+		format = models.ContentFormatPlain
+	}
+	return models.BlogRevision{
+		BlogID:        b.ID,
+		Number:        number,
+		AuthorID:      b.AuthorID,
+		CreatedAt:     at,
+		Title:         b.Title,
+		BlogContent:   b.BlogContent,
+		ContentFormat: format,
+		Tags:          b.Tags,
+		Category:      b.Category,
+		BlogImage:     b.BlogImage,
+		Uploads:       b.Uploads,
+	}
+}
+
+// ListRevisions returns a blog's revisions, newest first.
+func ListRevisions(ctx context.Context, blogID string) ([]models.BlogRevision, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(blogRevisionsCollection).Where("blog_id", "==", blogID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]models.BlogRevision, 0, len(docs))
+	for _, doc := range docs {
+		var r models.BlogRevision
+		if err := doc.DataTo(&r); err == nil {
+			revisions = append(revisions, r)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Number > revisions[j].Number
+	})
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a blog.
+func GetRevision(ctx context.Context, blogID string, number int) (*models.BlogRevision, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := revisionRef(blogID, number).Get(ctx)
+	if err != nil {
+		return nil, ErrRevisionNotFound
+	}
+	var r models.BlogRevision
+	if err := doc.DataTo(&r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// RestoreRevision makes an earlier revision current again by saving its snapshot as a new revision,
+// so the history between them is kept. It returns the updated blog.
+func RestoreRevision(ctx context.Context, blogID string, number int) (*models.Blog, error) {
+	rev, err := GetRevision(ctx, blogID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	blog := &models.Blog{
+		ID:            blogID,
+		Title:         rev.Title,
+		BlogContent:   rev.BlogContent,
+		ContentFormat: rev.ContentFormat,
+		Tags:          rev.Tags,
+		Category:      rev.Category,
+		BlogImage:     rev.BlogImage,
+	}
+	if err := updateBlog(ctx, blog, number); err != nil {
+		return nil, err
+	}
+	return blog, nil
+}
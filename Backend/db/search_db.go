@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/search"
+	"google.golang.org/api/iterator"
+)
+
+func searchDocument(b *models.Blog) search.Document {
+	return search.Document{
+		ID:        b.ID,
+		Title:     b.Title,
+		Content:   b.BlogContent,
+		Tags:      b.Tags,
+		Category:  b.Category,
+		CreatedAt: b.CreatedAt,
+	}
+}
+
+// indexBlog brings the search index up to date with a blog that was just written. The index is
+// derived data, so a failure is logged rather than failing the write.
+func indexBlog(b *models.Blog) {
+	if err := search.Default.Upsert(searchDocument(b)); err != nil {
+		log.Printf("⚠️ Failed to index blog %s: %v", b.ID, err)
+	}
+}
+
+func unindexBlog(blogID string) {
+	if err := search.Default.Remove(blogID); err != nil {
+		log.Printf("⚠️ Failed to remove blog %s from the search index: %v", blogID, err)
+	}
+}
+
+// RebuildSearchIndex loads every published blog into the search index. It is run once at startup.
+// Blogs of banned authors are left out, as they are everywhere else, so they cannot show up in
+// search totals and facets either.
+func RebuildSearchIndex(ctx context.Context) (int, error) {
+	if FirestoreClient == nil {
+		return 0, errors.New("firestore client is not initialized")
+	}
+
+	banned := make(map[string]bool)
+	count := 0
+	iter := FirestoreClient.Collection(blogsCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		if !b.Published() {
+			continue
+		}
+		isBanned, checked := banned[b.AuthorID]
+		if !checked && b.AuthorID != "" {
+			if author, err := GetUserByID(ctx, b.AuthorID); err == nil {
+				isBanned = author.Suspension.Banned()
+			}
+			banned[b.AuthorID] = isBanned
+		}
+		if isBanned {
+			continue
+		}
+		indexBlog(&b)
+		count++
+	}
+}
+
+// reindexAuthor adds or removes all of an author's published blogs from the search index, as
+// their author is unbanned or banned.
+func reindexAuthor(ctx context.Context, authorID string, visible bool) error {
+	iter := FirestoreClient.Collection(blogsCollection).Where("author_id", "==", authorID).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		if visible && b.Published() {
+			indexBlog(&b)
+		} else {
+			unindexBlog(b.ID)
+		}
+	}
+}
+
+// GetBlogsByIDs fetches blogs in the given order with one batched read, filling in their authors.
+// Blogs that no longer exist, are not published, or whose authors are banned are left out.
+func GetBlogsByIDs(ctx context.Context, ids []string) ([]models.Blog, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+	if len(ids) == 0 {
+		return []models.Blog{}, nil
+	}
+
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = FirestoreClient.Collection(blogsCollection).Doc(id)
+	}
+	docs, err := FirestoreClient.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	blogs := make([]models.Blog, 0, len(docs))
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var b models.Blog
+		if err := doc.DataTo(&b); err != nil || !b.Published() {
+			continue
+		}
+		b.ID = doc.Ref.ID
+		blogs = append(blogs, b)
+	}
+	return attachAuthors(ctx, blogs)
+}
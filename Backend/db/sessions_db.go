@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+	"google.golang.org/api/iterator"
+)
+
+const sessionsCollection = "sessions"
+
+// sessionActivityResolution limits how often a busy session's last-active details are written.
+const sessionActivityResolution = time.Minute
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired, revoked or already used.
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// CreateSession starts a new session for a user on the device described by userAgent and ip,
+// and returns it together with its refresh token.
+// Refresh tokens have the form "<session id>.<secret>"; only the hash of the whole token is stored.
+func CreateSession(ctx context.Context, userID, userAgent, ip string) (*models.Session, string, error) {
+	if FirestoreClient == nil {
+		return nil, "", errors.New("firestore client is not initialized")
+	}
+
+	docRef := FirestoreClient.Collection(sessionsCollection).NewDoc()
+	refreshToken, err := newRefreshToken(docRef.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               docRef.ID,
+		UserID:           userID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		CreatedAt:        now,
+		RefreshedAt:      now,
+		ExpiresAt:        now.Add(utils.Session.RefreshTTL),
+		DeviceLabel:      utils.DeviceLabel(userAgent),
+		UserAgent:        userAgent,
+		IP:               ip,
+		LastActiveAt:     now,
+	}
+
+	if _, err := docRef.Set(ctx, session); err != nil {
+		return nil, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// GetSession retrieves a session by its ID.
+func GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	doc, err := FirestoreClient.Collection(sessionsCollection).Doc(sessionID).Get(ctx)
+	if err != nil {
+		return nil, errors.New("session not found")
+	}
+
+	var session models.Session
+	if err := doc.DataTo(&session); err != nil {
+		return nil, err
+	}
+	session.ID = doc.Ref.ID
+	return &session, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one on the same session, recording ip as
+// the address it was last used from.
+// Presenting a token that was already rotated away revokes the session, since it means the token leaked.
+func RotateRefreshToken(ctx context.Context, refreshToken, ip string) (*models.Session, string, error) {
+	if FirestoreClient == nil {
+		return nil, "", errors.New("firestore client is not initialized")
+	}
+
+	sessionID, _, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	docRef := FirestoreClient.Collection(sessionsCollection).Doc(sessionID)
+	var session models.Session
+	var newToken string
+	reused := false
+
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if err := doc.DataTo(&session); err != nil {
+			return err
+		}
+		session.ID = doc.Ref.ID
+
+		now := time.Now()
+		if !session.Active(now) {
+			return ErrInvalidRefreshToken
+		}
+		if session.RefreshTokenHash != utils.HashToken(refreshToken) {
+			reused = true
+			return ErrInvalidRefreshToken
+		}
+
+		newToken, err = newRefreshToken(session.ID)
+		if err != nil {
+			return err
+		}
+		session.RefreshTokenHash = utils.HashToken(newToken)
+		session.RefreshedAt = now
+		session.ExpiresAt = now.Add(utils.Session.RefreshTTL)
+		session.LastActiveAt = now
+		session.IP = ip
+
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "refresh_token_hash", Value: session.RefreshTokenHash},
+			{Path: "refreshed_at", Value: session.RefreshedAt},
+			{Path: "expires_at", Value: session.ExpiresAt},
+			{Path: "last_active_at", Value: now},
+			{Path: "ip", Value: ip},
+		})
+	})
+	if reused {
+		_ = RevokeSession(ctx, sessionID)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &session, newToken, nil
+}
+
+// TouchSession records that a session was just used from ip. Writes are skipped when the session
+// was already marked active from the same address within the last minute.
+func TouchSession(ctx context.Context, session *models.Session, ip string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastActiveAt) < sessionActivityResolution && session.IP == ip {
+		return nil
+	}
+	_, err := FirestoreClient.Collection(sessionsCollection).Doc(session.ID).Update(ctx, []firestore.Update{
+		{Path: "last_active_at", Value: now},
+		{Path: "ip", Value: ip},
+	})
+	return err
+}
+
+// ListSessions returns a user's active sessions, most recently used first.
+func ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(sessionsCollection).
+		Where("user_id", "==", userID).
+		Where("revoked", "==", false).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]models.Session, 0, len(docs))
+	for _, doc := range docs {
+		var s models.Session
+		if err := doc.DataTo(&s); err != nil {
+			continue
+		}
+		s.ID = doc.Ref.ID
+		if !s.Active(now) {
+			continue
+		}
+		if s.LastActiveAt.IsZero() {
+			s.LastActiveAt = s.RefreshedAt // sessions created before activity was tracked
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActiveAt.After(sessions[j].LastActiveAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession marks a single session as revoked.
+func RevokeSession(ctx context.Context, sessionID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(sessionsCollection).Doc(sessionID).Update(ctx, []firestore.Update{
+		{Path: "revoked", Value: true},
+		{Path: "revoked_at", Value: time.Now()},
+	})
+	return err
+}
+
+// RevokeAllSessions revokes every active session belonging to a user.
+func RevokeAllSessions(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	iter := FirestoreClient.Collection(sessionsCollection).
+		Where("user_id", "==", userID).
+		Where("revoked", "==", false).
+		Documents(ctx)
+
+	defer iter.Stop()
+
+	// Batches are limited to 500 writes, and long-lived accounts can have more sessions than that
+	const batchSize = 400
+	batch := FirestoreClient.Batch()
+	pending := 0
+	now := time.Now()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		batch.Update(doc.Ref, []firestore.Update{
+			{Path: "revoked", Value: true},
+			{Path: "revoked_at", Value: now},
+		})
+		pending++
+		if pending == batchSize {
+			if _, err := batch.Commit(ctx); err != nil {
+				return err
+			}
+			batch = FirestoreClient.Batch()
+			pending = 0
+		}
+	}
+
+	if pending == 0 {
+		return nil
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+func newRefreshToken(sessionID string) (string, error) {
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return sessionID + "." + secret, nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// blog_slugs maps every slug a blog has ever had to the blog, so links keep working after a
+// title edit. A blog's current slug is the one stored on the blog itself.
+const blogSlugsCollection = "blog_slugs"
+
+const maxSlugLength = 80
+
+type slugEntry struct {
+	BlogID    string    `firestore:"blog_id"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// ResolveBlogSlug returns the ID of the blog a current or former slug belongs to.
+func ResolveBlogSlug(ctx context.Context, slug string) (string, error) {
+	if FirestoreClient == nil {
+		return "", errors.New("firestore client is not initialized")
+	}
+
+	doc, err := FirestoreClient.Collection(blogSlugsCollection).Doc(slug).Get(ctx)
+	if err != nil {
+		return "", errors.New("blog not found")
+	}
+	var entry slugEntry
+	if err := doc.DataTo(&entry); err != nil {
+		return "", err
+	}
+	return entry.BlogID, nil
+}
+
+// reserveSlug claims a slug derived from title for the blog inside tx and returns it. If the
+// plain slug belongs to another blog a numbered variant is used. All reads happen before the write.
+func reserveSlug(ctx context.Context, tx *firestore.Transaction, title, blogID string) (string, error) {
+	base := utils.Slugify(title, maxSlugLength-4)
+	if base == "" {
+		base = "post"
+	}
+
+	candidates := []string{base}
+	for n := 2; n <= 9; n++ {
+		candidates = append(candidates, base+"-"+strconv.Itoa(n))
+	}
+	candidates = append(candidates, base+"-"+strings.ToLower(blogID))
+
+	slugs := FirestoreClient.Collection(blogSlugsCollection)
+	for _, slug := range candidates {
+		ref := slugs.Doc(slug)
+		doc, err := tx.Get(ref)
+		if err == nil && doc.Exists() {
+			var entry slugEntry
+			if err := doc.DataTo(&entry); err != nil {
+				return "", err
+			}
+			if entry.BlogID != blogID {
+				continue
+			}
+			return slug, nil // a former slug of this blog becomes current again
+		}
+		if err := tx.Create(ref, slugEntry{BlogID: blogID, CreatedAt: time.Now()}); err != nil {
+			return "", err
+		}
+		return slug, nil
+	}
+	return "", errors.New("could not find a free URL for this title")
+}
+
+// releaseSlugs deletes every slug that points at a deleted blog so they can be reused.
+func releaseSlugs(ctx context.Context, blogID string) error {
+	return deleteQuery(ctx, FirestoreClient.Collection(blogSlugsCollection).Where("blog_id", "==", blogID))
+}
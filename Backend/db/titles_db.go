@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// blog_titles holds one entry per author and normalized title, so that two requests saving blogs
+// at the same time cannot give the same author two blogs with one title.
+const blogTitlesCollection = "blog_titles"
+
+// ErrTitleTaken is returned when the author already has another blog with the title.
+var ErrTitleTaken = errors.New("you already have a blog with this title")
+
+type titleEntry struct {
+	AuthorID  string    `firestore:"author_id"`
+	BlogID    string    `firestore:"blog_id"`
+	Title     string    `firestore:"title"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// checkTitleFree fails with ErrTitleTaken if another of the author's blogs has the title. Its reads
+// take part in tx and must come before the transaction's writes; claimTitle then records the title.
+func checkTitleFree(ctx context.Context, tx *firestore.Transaction, authorID, title, blogID string) error {
+	doc, err := tx.Get(titleRef(authorID, title))
+	if err == nil && doc.Exists() {
+		var entry titleEntry
+		if err := doc.DataTo(&entry); err != nil {
+			return err
+		}
+		if entry.BlogID != blogID {
+			return ErrTitleTaken
+		}
+		return nil
+	}
+
+	// Blogs saved before the index existed are only found on the blog documents
+	docs, err := tx.Documents(FirestoreClient.Collection(blogsCollection).
+		Where("author_id", "==", authorID).
+		Where("title", "==", title).
+		Limit(2)).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if doc.Ref.ID != blogID {
+			return ErrTitleTaken
+		}
+	}
+	return nil
+}
+
+// holdsTitle reports whether the blog's entry is the one indexing the title, reading inside tx.
+func holdsTitle(tx *firestore.Transaction, authorID, title, blogID string) (bool, error) {
+	doc, err := tx.Get(titleRef(authorID, title))
+	if err != nil || !doc.Exists() {
+		return false, nil
+	}
+	var entry titleEntry
+	if err := doc.DataTo(&entry); err != nil {
+		return false, err
+	}
+	return entry.BlogID == blogID, nil
+}
+
+// claimTitle indexes the blog's title inside tx, once checkTitleFree has passed.
+func claimTitle(tx *firestore.Transaction, authorID, title, blogID string) error {
+	return tx.Set(titleRef(authorID, title), titleEntry{
+		AuthorID:  authorID,
+		BlogID:    blogID,
+		Title:     title,
+		CreatedAt: time.Now(),
+	})
+}
+
+// releaseTitle frees a title the blog no longer has, inside tx.
+func releaseTitle(tx *firestore.Transaction, authorID, title string) error {
+	return tx.Delete(titleRef(authorID, title))
+}
+
+// releaseTitles deletes the title entry of a deleted blog.
+func releaseTitles(ctx context.Context, blogID string) error {
+	return deleteQuery(ctx, FirestoreClient.Collection(blogTitlesCollection).Where("blog_id", "==", blogID))
+}
+
+// titleRef names entries by a hash of the author and title, which is case- and spacing-insensitive
+// and may contain characters document IDs cannot.
+func titleRef(authorID, title string) *firestore.DocumentRef {
+	return FirestoreClient.Collection(blogTitlesCollection).Doc(utils.HashToken(authorID + "\x00" + normalizeTitle(title)))
+}
+
+func normalizeTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+	"google.golang.org/api/iterator"
+)
+
+const tokensCollection = "one_time_tokens"
+
+// ErrInvalidToken is returned when a one-time token is unknown, expired, already used or issued for another purpose.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// CreateOneTimeToken issues a single-use token for a user and returns it. Any earlier unused
+// tokens for the same purpose are invalidated so only the most recent one works.
+func CreateOneTimeToken(ctx context.Context, userID string, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	if FirestoreClient == nil {
+		return "", errors.New("firestore client is not initialized")
+	}
+
+	if err := invalidateOneTimeTokens(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = FirestoreClient.Collection(tokensCollection).Doc(utils.HashToken(token)).Set(ctx, &models.OneTimeToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeOneTimeToken marks a token as used and returns the user it was issued to.
+func ConsumeOneTimeToken(ctx context.Context, token string, purpose models.TokenPurpose) (string, error) {
+	if FirestoreClient == nil {
+		return "", errors.New("firestore client is not initialized")
+	}
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	docRef := FirestoreClient.Collection(tokensCollection).Doc(utils.HashToken(token))
+	var userID string
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return ErrInvalidToken
+		}
+		var t models.OneTimeToken
+		if err := doc.DataTo(&t); err != nil {
+			return err
+		}
+		if t.Used || t.Purpose != purpose || time.Now().After(t.ExpiresAt) {
+			return ErrInvalidToken
+		}
+		userID = t.UserID
+		return tx.Update(docRef, []firestore.Update{
+			{Path: "used", Value: true},
+			{Path: "used_at", Value: time.Now()},
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// AttemptOneTimeToken counts an attempt against a token and returns its record without consuming
+// it, for flows that must check something else (such as a second factor) before the token is
+// spent. The attempt is counted before the caller checks anything, in the same transaction that
+// validates the token, so concurrent guesses cannot exceed maxAttempts.
+func AttemptOneTimeToken(ctx context.Context, token string, purpose models.TokenPurpose, maxAttempts int) (*models.OneTimeToken, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	docRef := FirestoreClient.Collection(tokensCollection).Doc(utils.HashToken(token))
+	var t models.OneTimeToken
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(docRef)
+		if err != nil {
+			return ErrInvalidToken
+		}
+		t = models.OneTimeToken{}
+		if err := doc.DataTo(&t); err != nil {
+			return err
+		}
+		if t.Used || t.Purpose != purpose || time.Now().After(t.ExpiresAt) || t.Attempts >= maxAttempts {
+			return ErrInvalidToken
+		}
+		t.Attempts++
+		return tx.Update(docRef, []firestore.Update{{Path: "attempts", Value: t.Attempts}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func invalidateOneTimeTokens(ctx context.Context, userID string, purpose models.TokenPurpose) error {
+	iter := FirestoreClient.Collection(tokensCollection).
+		Where("user_id", "==", userID).
+		Where("purpose", "==", purpose).
+		Where("used", "==", false).
+		Documents(ctx)
+
+	now := time.Now()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "used", Value: true},
+			{Path: "used_at", Value: now},
+		}); err != nil {
+			return err
+		}
+	}
+}
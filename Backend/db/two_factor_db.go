@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// SetPendingTOTPSecret stores a secret that becomes active once the user confirms a code from it.
+func SetPendingTOTPSecret(ctx context.Context, userID, secret string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "PendingTOTPSecret", Value: secret},
+	})
+	return err
+}
+
+// EnableTwoFactor activates the pending secret and stores the hashes of the recovery codes.
+// step is the TOTP step used to confirm enrolment, so that code cannot be replayed at login.
+func EnableTwoFactor(ctx context.Context, userID, secret string, step int64, recoveryCodes []string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "TwoFactorEnabled", Value: true},
+		{Path: "TOTPSecret", Value: secret},
+		{Path: "PendingTOTPSecret", Value: firestore.Delete},
+		{Path: "TOTPLastStep", Value: step},
+		{Path: "RecoveryCodes", Value: hashRecoveryCodes(recoveryCodes)},
+	})
+	return err
+}
+
+// DisableTwoFactor turns two-factor authentication off and forgets the secret and recovery codes.
+func DisableTwoFactor(ctx context.Context, userID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "TwoFactorEnabled", Value: false},
+		{Path: "TOTPSecret", Value: firestore.Delete},
+		{Path: "PendingTOTPSecret", Value: firestore.Delete},
+		{Path: "TOTPLastStep", Value: firestore.Delete},
+		{Path: "RecoveryCodes", Value: firestore.Delete},
+	})
+	return err
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for a new set.
+func ReplaceRecoveryCodes(ctx context.Context, userID string, recoveryCodes []string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(usersCollection).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "RecoveryCodes", Value: hashRecoveryCodes(recoveryCodes)},
+	})
+	return err
+}
+
+// UseTOTPCode verifies a TOTP code for a user with two-factor enabled. Each time step is
+// accepted at most once, so an intercepted code cannot be replayed.
+func UseTOTPCode(ctx context.Context, userID, code string) (bool, error) {
+	if FirestoreClient == nil {
+		return false, errors.New("firestore client is not initialized")
+	}
+
+	userRef := FirestoreClient.Collection(usersCollection).Doc(userID)
+	valid := false
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		valid = false
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return errors.New("user not found")
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled || user.TOTPSecret == "" {
+			return nil
+		}
+
+		step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return nil
+		}
+		valid = true
+		return tx.Update(userRef, []firestore.Update{{Path: "TOTPLastStep", Value: step}})
+	})
+	return valid, err
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes if it matches.
+func UseRecoveryCode(ctx context.Context, userID, code string) (bool, error) {
+	if FirestoreClient == nil {
+		return false, errors.New("firestore client is not initialized")
+	}
+
+	hash := utils.HashToken(utils.NormalizeRecoveryCode(code))
+	userRef := FirestoreClient.Collection(usersCollection).Doc(userID)
+	valid := false
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		valid = false
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return errors.New("user not found")
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		for _, stored := range user.RecoveryCodes {
+			if stored == hash {
+				valid = true
+				return tx.Update(userRef, []firestore.Update{
+					{Path: "RecoveryCodes", Value: firestore.ArrayRemove(hash)},
+				})
+			}
+		}
+		return nil
+	})
+	return valid, err
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return hashes
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	uploadsCollection = "uploads"
+	// uploadDeletionLease is how long garbage collection has to delete an upload's files before
+	// the upload may be used again.
+	uploadDeletionLease = 10 * time.Minute
+)
+
+// ErrUploadDeleting is returned when an image is used while its files are being garbage collected.
+var ErrUploadDeleting = errors.New("an image is being removed; upload it again in a moment")
+
+// errUploadInUse stops the deletion of an upload that was used again since it was found unreferenced.
+var errUploadInUse = errors.New("upload was used again")
+
+// SaveUpload records an uploaded image before its files are stored. When the same image was
+// uploaded before, the record keeps its creation time and uploader and is marked as used now.
+// It fails with ErrUploadDeleting while garbage collection deletes the image's files.
+func SaveUpload(ctx context.Context, upload *models.Upload) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(uploadsCollection).Doc(upload.ID)
+	now := time.Now()
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		upload.CreatedAt = now
+		upload.LastUsedAt = now
+		upload.DeletingUntil = time.Time{}
+		doc, err := tx.Get(ref)
+		if err == nil && doc.Exists() {
+			var existing models.Upload
+			if err := doc.DataTo(&existing); err != nil {
+				return err
+			}
+			if existing.BeingDeleted(now) {
+				return ErrUploadDeleting
+			}
+			upload.CreatedAt = existing.CreatedAt
+			upload.UploadedBy = existing.UploadedBy
+		}
+		return tx.Set(ref, upload)
+	})
+}
+
+// GetStaleUploads returns up to limit uploads that have not been used since before, least recently used first.
+func GetStaleUploads(ctx context.Context, before time.Time, limit int) ([]models.Upload, error) {
+	if FirestoreClient == nil {
+		return nil, errors.New("firestore client is not initialized")
+	}
+
+	docs, err := FirestoreClient.Collection(uploadsCollection).
+		Where("last_used_at", "<", before).
+		OrderBy("last_used_at", firestore.Asc).
+		Limit(limit).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := make([]models.Upload, 0, len(docs))
+	for _, doc := range docs {
+		var u models.Upload
+		if err := doc.DataTo(&u); err != nil {
+			continue
+		}
+		u.ID = doc.Ref.ID
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
+
+// UploadReferenced reports whether a blog, blog revision or avatar uses the upload.
+func UploadReferenced(ctx context.Context, uploadID string) (bool, error) {
+	if FirestoreClient == nil {
+		return false, errors.New("firestore client is not initialized")
+	}
+
+	queries := []firestore.Query{
+		FirestoreClient.Collection(blogsCollection).Where("uploads", "array-contains", uploadID),
+		FirestoreClient.Collection(blogRevisionsCollection).Where("uploads", "array-contains", uploadID),
+		FirestoreClient.Collection(usersCollection).Where("AvatarUpload", "==", uploadID),
+	}
+	for _, q := range queries {
+		docs, err := q.Limit(1).Documents(ctx).GetAll()
+		if err != nil {
+			return false, err
+		}
+		if len(docs) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TouchUpload marks an upload as used now, so it is not considered for collection for a while.
+func TouchUpload(ctx context.Context, uploadID string) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	_, err := FirestoreClient.Collection(uploadsCollection).Doc(uploadID).Update(ctx, []firestore.Update{
+		{Path: "last_used_at", Value: time.Now()},
+	})
+	return err
+}
+
+// MarkUploadDeleting claims an upload for garbage collection unless it was used after lastUsedAt,
+// reporting whether it did. Blogs, avatars and new uploads of the same image are refused until
+// the returned deadline, by which the caller has to have deleted the files and the record.
+func MarkUploadDeleting(ctx context.Context, uploadID string, lastUsedAt time.Time) (time.Time, bool, error) {
+	if FirestoreClient == nil {
+		return time.Time{}, false, errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(uploadsCollection).Doc(uploadID)
+	// Firestore keeps microseconds, and DeleteUploadRecord compares the deadline it reads back
+	deadline := time.Now().Add(uploadDeletionLease).Truncate(time.Microsecond)
+	err := FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var u models.Upload
+		if err := doc.DataTo(&u); err != nil {
+			return err
+		}
+		if u.LastUsedAt.After(lastUsedAt) || u.BeingDeleted(time.Now()) {
+			return errUploadInUse
+		}
+		return tx.Update(ref, []firestore.Update{{Path: "deleting_until", Value: deadline}})
+	})
+	if errors.Is(err, errUploadInUse) || status.Code(err) == codes.NotFound {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return deadline, true, nil
+}
+
+// DeleteUploadRecord deletes the record of an upload once its files are gone, as long as it is
+// still claimed by the collection that ends at deadline.
+func DeleteUploadRecord(ctx context.Context, uploadID string, deadline time.Time) error {
+	if FirestoreClient == nil {
+		return errors.New("firestore client is not initialized")
+	}
+
+	ref := FirestoreClient.Collection(uploadsCollection).Doc(uploadID)
+	return FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var u models.Upload
+		if err := doc.DataTo(&u); err != nil {
+			return err
+		}
+		if !u.DeletingUntil.Equal(deadline) {
+			return nil
+		}
+		return tx.Delete(ref)
+	})
+}
+
+// useUploads reads, inside tx, the records of uploads a blog or profile is about to refer to,
+// failing with ErrUploadDeleting if one is being garbage collected. Uploads without a record are
+// left out. Its reads must come before the transaction's writes; touchUploads then marks them used.
+func useUploads(tx *firestore.Transaction, ids []string) ([]*firestore.DocumentRef, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	refs := make([]*firestore.DocumentRef, len(ids))
+	for i, id := range ids {
+		refs[i] = FirestoreClient.Collection(uploadsCollection).Doc(id)
+	}
+	docs, err := tx.GetAll(refs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var used []*firestore.DocumentRef
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var u models.Upload
+		if err := doc.DataTo(&u); err != nil {
+			return nil, err
+		}
+		if u.BeingDeleted(now) {
+			return nil, ErrUploadDeleting
+		}
+		used = append(used, doc.Ref)
+	}
+	return used, nil
+}
+
+// touchUploads marks the uploads found by useUploads as used now, inside tx, so that a collection
+// which found them unreferenced before the transaction leaves them alone.
+func touchUploads(tx *firestore.Transaction, refs []*firestore.DocumentRef) error {
+	now := time.Now()
+	for _, ref := range refs {
+		if err := tx.Update(ref, []firestore.Update{{Path: "last_used_at", Value: now}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
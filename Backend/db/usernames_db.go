@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// usernamesCollection indexes every claimed username. The document ID is the lowercased
+// username, so creating it inside a transaction is what guarantees uniqueness.
+const usernamesCollection = "usernames"
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 20
+)
+
+// ErrUsernameTaken is returned when a username is already claimed by another account.
+var ErrUsernameTaken = errors.New("username is already taken")
+
+// reservedUsernames cannot be registered because they collide with routes or could impersonate staff.
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "support": true,
+	"help": true, "moderator": true, "mod": true, "staff": true, "insighthub": true,
+	"official": true, "security": true, "api": true, "auth": true, "login": true,
+	"logout": true, "register": true, "signup": true, "signin": true, "me": true,
+	"id": true, "user": true, "users": true, "blogs": true, "blog": true, "explore": true,
+	"notifications": true, "messages": true, "chat": true, "settings": true, "profile": true,
+	"search": true, "feed": true, "null": true, "undefined": true, "anonymous": true,
+	"unknown": true,
+}
+
+type usernameEntry struct {
+	UserID    string    `firestore:"user_id"`
+	Username  string    `firestore:"username"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// ValidateUsername checks a username's length, characters and the reserved-word blocklist.
+func ValidateUsername(username string) error {
+	if len(username) < minUsernameLength || len(username) > maxUsernameLength {
+		return fmt.Errorf("username must be between %d and %d characters", minUsernameLength, maxUsernameLength)
+	}
+	for _, ch := range username {
+		if !isUsernameChar(ch) {
+			return errors.New("username may only contain letters, numbers, underscores and dots")
+		}
+	}
+	if reservedUsernames[strings.ToLower(username)] {
+		return errors.New("this username is reserved")
+	}
+	return nil
+}
+
+// UsernameAvailable reports whether a username is valid and unclaimed, with a reason when it is not.
+func UsernameAvailable(ctx context.Context, username string) (bool, string, error) {
+	if FirestoreClient == nil {
+		return false, "", errors.New("firestore client is not initialized")
+	}
+
+	if err := ValidateUsername(username); err != nil {
+		return false, err.Error(), nil
+	}
+
+	taken, err := usernameTaken(ctx, nil, username, "")
+	if err != nil {
+		return false, "", err
+	}
+	if taken {
+		return false, ErrUsernameTaken.Error(), nil
+	}
+	return true, "", nil
+}
+
+// GenerateAvailableUsername turns a display name or email prefix into a valid username that is
+// currently free, adding a numeric suffix when the plain form is taken.
+func GenerateAvailableUsername(ctx context.Context, base string) (string, error) {
+	if FirestoreClient == nil {
+		return "", errors.New("firestore client is not initialized")
+	}
+
+	candidate := sanitizeUsername(base)
+	if len(candidate) < minUsernameLength {
+		candidate += "user"
+	}
+
+	for attempt := 0; attempt < 20; attempt++ {
+		name := candidate
+		if attempt > 0 {
+			var suffix string
+			if attempt < 10 {
+				suffix = fmt.Sprint(attempt)
+			} else {
+				suffix = fmt.Sprint(1000 + rand.IntN(9000))
+			}
+			name = truncate(candidate, maxUsernameLength-len(suffix)) + suffix
+		}
+		if ValidateUsername(name) != nil {
+			continue
+		}
+		taken, err := usernameTaken(ctx, nil, name, "")
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return name, nil
+		}
+	}
+	return "", errors.New("could not find an available username")
+}
+
+// reserveUsername claims a username for a user inside a transaction. It fails with
+// ErrUsernameTaken if the name is indexed or, for accounts created before the index, in use.
+func reserveUsername(ctx context.Context, tx *firestore.Transaction, username, userID string) error {
+	taken, err := usernameTaken(ctx, tx, username, userID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+
+	return tx.Create(FirestoreClient.Collection(usernamesCollection).Doc(usernameKey(username)), usernameEntry{
+		UserID:    userID,
+		Username:  username,
+		CreatedAt: time.Now(),
+	})
+}
+
+// releaseUsername frees a username previously claimed by the given user.
+func releaseUsername(tx *firestore.Transaction, username string) error {
+	return tx.Delete(FirestoreClient.Collection(usernamesCollection).Doc(usernameKey(username)))
+}
+
+// usernameTaken reports whether someone other than exceptUserID holds the username.
+// When tx is non-nil the reads take part in that transaction.
+func usernameTaken(ctx context.Context, tx *firestore.Transaction, username, exceptUserID string) (bool, error) {
+	indexRef := FirestoreClient.Collection(usernamesCollection).Doc(usernameKey(username))
+	var doc *firestore.DocumentSnapshot
+	var err error
+	if tx != nil {
+		doc, err = tx.Get(indexRef)
+	} else {
+		doc, err = indexRef.Get(ctx)
+	}
+	if err == nil && doc.Exists() {
+		var entry usernameEntry
+		if err := doc.DataTo(&entry); err != nil {
+			return false, err
+		}
+		return entry.UserID != exceptUserID, nil
+	}
+
+	// Accounts created before the index existed are only found on the user documents
+	query := FirestoreClient.Collection(usersCollection).Where("Username", "==", username).Limit(1)
+	var iter *firestore.DocumentIterator
+	if tx != nil {
+		iter = tx.Documents(query)
+	} else {
+		iter = query.Documents(ctx)
+	}
+	defer iter.Stop()
+	userDoc, err := iter.Next()
+	if err == iterator.Done {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return userDoc.Ref.ID != exceptUserID, nil
+}
+
+func usernameKey(username string) string {
+	return strings.ToLower(username)
+}
+
+func isUsernameChar(ch rune) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_' || ch == '.'
+}
+
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, ch := range strings.ToLower(s) {
+		if isUsernameChar(ch) {
+			b.WriteRune(ch)
+		}
+	}
+	return truncate(strings.Trim(b.String(), "."), maxUsernameLength)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
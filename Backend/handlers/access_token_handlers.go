@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/chat/Chat_Handlers"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const (
+	maxAccessTokens          = 20
+	maxAccessTokenNameLength = 50
+	maxAccessTokenDays       = 365
+)
+
+// ListAccessTokens returns the caller's personal access tokens. Token values are never shown again.
+func ListAccessTokens(c *gin.Context) {
+	tokens, err := db.ListAccessTokens(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("tokens fetched successfully", tokens))
+}
+
+// CreateAccessToken issues a scoped personal access token. The token is only returned in this response.
+// expires_in_days may be left out for a token that never expires.
+func CreateAccessToken(c *gin.Context) {
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAccessTokenNameLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("token name must be between 1 and 50 characters", nil))
+		return
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAccessTokenDays {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("expires_in_days must be between 1 and 365, or omitted", nil))
+		return
+	}
+
+	scopes, ok := normalizeScopes(req.Scopes)
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("unknown scope; valid scopes are "+strings.Join(models.TokenScopes, ", "), nil))
+		return
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("at least one scope is required", nil))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := middleware.CurrentUserID(c)
+	existing, err := db.ListAccessTokens(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	active := 0
+	for i := range existing {
+		if existing[i].Active(time.Now()) {
+			active++
+		}
+	}
+	if active >= maxAccessTokens {
+		c.JSON(http.StatusConflict, models.NewErrorResponse("too many active tokens; revoke one first", nil))
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	pat, token, err := db.CreateAccessToken(ctx, userID, name, scopes, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("token created; copy it now, it will not be shown again", gin.H{
+		"token":    token,
+		"metadata": pat,
+	}))
+}
+
+// RevokeAccessToken revokes one of the caller's personal access tokens and closes the chat
+// connections opened with it.
+func RevokeAccessToken(c *gin.Context) {
+	tokenID := c.Param("id")
+	if err := db.RevokeAccessToken(c.Request.Context(), middleware.CurrentUserID(c), tokenID); err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	chat_handlers.CloseAccessTokenStreams(tokenID)
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("token revoked", nil))
+}
+
+// normalizeScopes removes duplicates and reports false if any scope is unknown.
+func normalizeScopes(requested []string) ([]string, bool) {
+	seen := make(map[string]bool, len(requested))
+	scopes := make([]string, 0, len(requested))
+	for _, s := range requested {
+		s = strings.TrimSpace(s)
+		if seen[s] {
+			continue
+		}
+		known := false
+		for _, valid := range models.TokenScopes {
+			if s == valid {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, false
+		}
+		seen[s] = true
+		scopes = append(scopes, s)
+	}
+	return scopes, true
+}
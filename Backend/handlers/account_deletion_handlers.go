@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/mailer"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// accountDeletionGrace is how long a deletion request can still be cancelled before data is purged.
+const accountDeletionGrace = 14 * 24 * time.Hour
+
+// DeleteAccount schedules the caller's account for deletion after a grace period. The caller confirms
+// with their password or, for accounts that only sign in through Google, by typing their username.
+func DeleteAccount(c *gin.Context) {
+	var req struct {
+		Password        string `json:"password"`
+		ConfirmUsername string `json:"confirm_username"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return
+	}
+
+	if err := db.CheckPassword(ctx, userID, req.Password); err != nil {
+		if !errors.Is(err, db.ErrNoPassword) {
+			c.JSON(http.StatusUnauthorized, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+		if !strings.EqualFold(strings.TrimSpace(req.ConfirmUsername), user.Username) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("type your username to confirm account deletion", nil))
+			return
+		}
+	}
+
+	deletion, err := db.ScheduleAccountDeletion(ctx, userID, user.Username, accountDeletionGrace)
+	if err != nil {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	go notifyAccountDeletion(user.Email, deletion.ScheduledFor)
+
+	c.JSON(http.StatusAccepted, models.NewSuccessResponse("account scheduled for deletion", deletion))
+}
+
+// GetAccountDeletion reports the state of the caller's deletion request.
+func GetAccountDeletion(c *gin.Context) {
+	deletion, err := db.GetAccountDeletion(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("account deletion fetched successfully", deletion))
+}
+
+// CancelAccountDeletion keeps the caller's account if the grace period has not ended yet.
+func CancelAccountDeletion(c *gin.Context) {
+	if err := db.CancelAccountDeletion(c.Request.Context(), middleware.CurrentUserID(c)); err != nil {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("account deletion cancelled", nil))
+}
+
+func notifyAccountDeletion(email string, scheduledFor time.Time) {
+	err := mailer.Default.Send(context.Background(), mailer.Message{
+		To:      email,
+		Subject: "Your Insight Hub account is scheduled for deletion",
+		Body: "We received a request to delete your Insight Hub account. Your blogs, comments, messages and " +
+			"profile will be permanently removed on " + scheduledFor.Format(time.RFC1123) + ".\n\n" +
+			"Until then you can sign in and cancel the deletion from your account settings. " +
+			"If you did not request this, cancel it and change your password right away.",
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to send account deletion notice to %s: %v", email, err)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/chat/Chat_Backend"
+	"github.com/prachin77/insight-hub/chat/Chat_Handlers"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const maxSuspensionReasonLength = 500
+
+// FeatureBlog marks a blog as featured.
+func FeatureBlog(c *gin.Context) {
+	setFeatured(c, true)
+}
+
+// UnfeatureBlog removes a blog from the featured list.
+func UnfeatureBlog(c *gin.Context) {
+	setFeatured(c, false)
+}
+
+func setFeatured(c *gin.Context, featured bool) {
+	if err := db.SetBlogFeatured(c.Request.Context(), c.Param("id"), featured); err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blog featured status updated", gin.H{"featured": featured}))
+}
+
+// RemoveComment deletes a comment and its replies.
+func RemoveComment(c *gin.Context) {
+	if err := db.RemoveComment(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("comment removed", nil))
+}
+
+// SuspendUser suspends a user for duration_hours, or permanently when it is left out,
+// and signs them out everywhere. Staff can only suspend users below their own role.
+func SuspendUser(c *gin.Context) {
+	var req struct {
+		Reason        string `json:"reason"`
+		DurationHours int    `json:"duration_hours"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" || utf8.RuneCountInString(reason) > maxSuspensionReasonLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("reason must be between 1 and 500 characters", nil))
+		return
+	}
+	if req.DurationHours < 0 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("duration_hours must not be negative", nil))
+		return
+	}
+
+	target, ok := staffTarget(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	suspension := models.Suspension{
+		Reason:      reason,
+		SuspendedBy: middleware.CurrentUserID(c),
+		SuspendedAt: now,
+	}
+	if req.DurationHours > 0 {
+		suspension.ExpiresAt = now.Add(time.Duration(req.DurationHours) * time.Hour)
+	}
+
+	ctx := c.Request.Context()
+	if err := db.SuspendUser(ctx, target, suspension); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	if err := db.RevokeAllSessions(ctx, target); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("user suspended but failed to revoke sessions", nil))
+		return
+	}
+	if err := db.RevokeAllAccessTokens(ctx, target); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("user suspended but failed to revoke access tokens", nil))
+		return
+	}
+	chat_backend.DisconnectUser(target)
+	chat_handlers.CloseUserStreams(target)
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("user suspended", suspension))
+}
+
+// LiftSuspension ends a user's suspension early.
+func LiftSuspension(c *gin.Context) {
+	target, ok := staffTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := db.LiftSuspension(c.Request.Context(), target); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("suspension lifted", nil))
+}
+
+// SetUserRole grants or removes a staff role.
+func SetUserRole(c *gin.Context) {
+	var req struct {
+		Role models.Role `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("role must be one of user, moderator or admin", nil))
+		return
+	}
+
+	target, ok := staffTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := db.SetUserRole(c.Request.Context(), target, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("role updated", gin.H{"role": req.Role}))
+}
+
+// staffTarget resolves the user named in the URL and checks the caller outranks them,
+// which also stops staff from acting on their own account.
+func staffTarget(c *gin.Context) (string, bool) {
+	targetID := c.Param("id")
+	if targetID == middleware.CurrentUserID(c) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("you cannot do this to your own account", nil))
+		return "", false
+	}
+
+	role, err := db.GetUserRole(c.Request.Context(), targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return "", false
+	}
+	if !middleware.CurrentRole(c).Outranks(role) {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse("you cannot act on a user with the same or a higher role", nil))
+		return "", false
+	}
+	return targetID, true
+}
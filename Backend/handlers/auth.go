@@ -2,52 +2,90 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/chat/Chat_Handlers"
 	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/loginguard"
+	"github.com/prachin77/insight-hub/mailer"
+	"github.com/prachin77/insight-hub/middleware"
 	"github.com/prachin77/insight-hub/models"
 	"github.com/prachin77/insight-hub/utils"
 	"google.golang.org/api/idtoken"
 )
 
+// Register creates an account from just the sign-up fields. Everything else on the user, from
+// profile details to roles and 2FA, is set through its own endpoint and validation.
 func Register(c *gin.Context) {
-	var req models.User
+	var req struct {
+		FullName string `json:"fullName"`
+		Username string `json:"username"`
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password"`
+	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
-	// Backend validation: username length and password strength
-	if len(req.Username) < 3 || len(req.Username) > 20 {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("username must be between 3 and 20 characters", nil))
+	// Backend validation: full name length, username format and password strength
+	req.FullName = strings.TrimSpace(req.FullName)
+	if req.FullName == "" || utf8.RuneCountInString(req.FullName) > maxFullNameLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("full name must be between 1 and 50 characters", nil))
+		return
+	}
+	if err := db.ValidateUsername(req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 	if !isStrongPassword(req.Password) {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("password must be at least 8 characters and include one uppercase letter and one special character", nil))
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(weakPasswordMessage, nil))
 		return
 	}
 
-	userID, err := db.CreateUser(c.Request.Context(), &req)
+	// Email ownership is only established later through the verification link
+	user := models.User{
+		FullName: req.FullName,
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+	}
+	userID, err := db.CreateUser(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
-	// Set auth cookie valid for 10 minutes using Document ID
-	cookie := utils.NewAuthCookie(userID)
-	http.SetCookie(c.Writer, &cookie)
+	if err := sendVerificationEmail(c.Request.Context(), userID, req.Email); err != nil {
+		log.Printf("⚠️ Failed to send verification email to %s: %v", req.Email, err)
+	}
+
+	// Start a session and set the auth cookies
+	token, refreshToken, err := startSession(c, userID, req.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to start session", nil))
+		return
+	}
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("registration successful", gin.H{
-		"id":       userID,
-		"fullName": req.FullName,
-		"email":    req.Email,
-		"username": req.Username,
+		"token":         token,
+		"refresh_token": refreshToken,
+		"id":            userID,
+		"fullName":      req.FullName,
+		"email":         req.Email,
+		"username":      req.Username,
+		"emailVerified": false,
 	}))
 }
 
@@ -58,8 +96,23 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse attempts while the account or client IP is backing off or locked out
+	ip := c.ClientIP()
+	if wait, err := loginguard.Default.Check(c.Request.Context(), req.Email, ip); err != nil {
+		log.Printf("⚠️ Login guard check failed: %v", err)
+	} else if wait > 0 {
+		tooManyLoginAttempts(c, wait)
+		return
+	}
+
 	userID, err := db.ValidateUser(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
+		outcome, gerr := loginguard.Default.Fail(c.Request.Context(), req.Email, ip)
+		if gerr != nil {
+			log.Printf("⚠️ Failed to record login failure: %v", gerr)
+		} else if outcome.AccountLocked {
+			go notifyLockout(req.Email, outcome.LockedUntil)
+		}
 		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
@@ -71,21 +124,96 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Set auth cookie
-	cookie := utils.NewAuthCookie(userID)
-	http.SetCookie(c.Writer, &cookie)
+	if rejectSuspended(c, user) {
+		return
+	}
+
+	// Accounts with 2FA get a short-lived challenge instead of a session
+	if user.TwoFactorEnabled {
+		issueLoginChallenge(c, userID)
+		return
+	}
+
+	// Start a session and set the auth cookies
+	token, refreshToken, err := startSession(c, userID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to start session", nil))
+		return
+	}
+	resetLoginFailures(c, req.Email)
 
-	c.JSON(http.StatusOK, models.NewSuccessResponse("login successful", gin.H{
-		"id":         userID,
-		"email":      user.Email,
-		"username":   user.Username,
-		"fullName":   user.FullName,
-		"noOfBlogs":  user.NoOfBlogs,
-		"followers":  user.Followers,
-		"followings": user.Followings,
+	c.JSON(http.StatusOK, models.NewSuccessResponse("login successful", loginPayload(userID, user, token, refreshToken)))
+}
+
+// tooManyLoginAttempts rejects a throttled login and tells the client when to retry.
+func tooManyLoginAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, models.NewErrorResponse("too many failed login attempts, please try again later", gin.H{
+		"retry_after": seconds,
 	}))
 }
 
+// resetLoginFailures forgets an account's failed attempts once a session has been issued for it.
+func resetLoginFailures(c *gin.Context, email string) {
+	if err := loginguard.Default.Succeed(c.Request.Context(), email); err != nil {
+		log.Printf("⚠️ Failed to reset login failures: %v", err)
+	}
+}
+
+// notifyLockout tells the account owner their account was temporarily locked after repeated failures.
+func notifyLockout(email string, until time.Time) {
+	ctx := context.Background()
+	user, err := db.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	err = mailer.Default.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Insight Hub account was temporarily locked",
+		Body: "We noticed several failed attempts to sign in to your Insight Hub account, so sign-in has been " +
+			"locked until " + until.Format(time.RFC1123) + ".\n\n" +
+			"If this was you, you can try again after that time or reset your password. " +
+			"If it was not you, we recommend changing your password and enabling two-factor authentication.",
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to send lockout notification to %s: %v", user.Email, err)
+	}
+}
+
+// loginPayload is the user summary returned after a successful sign-in.
+func loginPayload(userID string, user *models.User, token, refreshToken string) gin.H {
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"id":            userID,
+		"email":         user.Email,
+		"username":      user.Username,
+		"fullName":      user.FullName,
+		"emailVerified": user.EmailVerified,
+		"noOfBlogs":     user.NoOfBlogs,
+		"followers":     user.Followers,
+		"followings":    user.Followings,
+	}
+}
+
+// rejectSuspended answers 403 with the reason and end of the suspension if the user is suspended.
+func rejectSuspended(c *gin.Context, user *models.User) bool {
+	if !user.Suspension.Active(time.Now()) {
+		return false
+	}
+
+	message := "your account has been permanently suspended"
+	details := gin.H{"reason": user.Suspension.Reason}
+	if !user.Suspension.ExpiresAt.IsZero() {
+		message = "your account has been suspended"
+		details["expires_at"] = user.Suspension.ExpiresAt
+	}
+	c.JSON(http.StatusForbidden, models.NewErrorResponse(message, details))
+	return true
+}
+
 func GetUser(c *gin.Context) {
 	username := c.Param("username")
 	if username == "" {
@@ -99,7 +227,8 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.NewSuccessResponse("user fetched successfully", user))
+	// Anyone may look users up, so only the public profile is returned; the rest is in GET /user/me
+	c.JSON(http.StatusOK, models.NewSuccessResponse("user fetched successfully", user.PublicProfile(user.ID)))
 }
 
 func GetUserByIDHandler(c *gin.Context) {
@@ -115,17 +244,45 @@ func GetUserByIDHandler(c *gin.Context) {
 		return
 	}
 
-	// Also set the ID field so frontend can use it
-	user.ID = id
-	c.JSON(http.StatusOK, models.NewSuccessResponse("user fetched successfully", user))
+	c.JSON(http.StatusOK, models.NewSuccessResponse("user fetched successfully", user.PublicProfile(id)))
+}
+
+// CheckUsername tells the signup form whether a username can be registered.
+func CheckUsername(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("username is required", nil))
+		return
+	}
+
+	available, reason, err := db.UsernameAvailable(c.Request.Context(), username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("username availability checked", gin.H{
+		"username":  username,
+		"available": available,
+		"reason":    reason,
+	}))
 }
 
 func Logout(c *gin.Context) {
-	// Clear auth cookie by setting it with immediate expiration
-	cookie := utils.NewAuthCookie("")
-	cookie.MaxAge = -1
-	cookie.Expires = time.Now().Add(-1 * time.Hour)
-	http.SetCookie(c.Writer, &cookie)
+	// Revoke the server-side session so the tokens stop working even if they were copied
+	if claims, err := utils.ParseJWT(middleware.AccessToken(c.Request)); err == nil {
+		_ = db.RevokeSession(c.Request.Context(), claims.SessionID)
+		chat_handlers.CloseSessionStreams(claims.SessionID)
+	} else if cookie, err := c.Request.Cookie("refresh_token"); err == nil {
+		if sessionID, _, ok := strings.Cut(cookie.Value, "."); ok {
+			if session, err := db.GetSession(c.Request.Context(), sessionID); err == nil && session.RefreshTokenHash == utils.HashToken(cookie.Value) {
+				_ = db.RevokeSession(c.Request.Context(), sessionID)
+				chat_handlers.CloseSessionStreams(sessionID)
+			}
+		}
+	}
+
+	clearSessionCookies(c)
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("logout successful", nil))
 }
@@ -146,6 +303,36 @@ func isStrongPassword(pw string) bool {
 	return hasUpper && hasSpecial
 }
 
+// googleProfile holds the claims we use from a verified Google ID token.
+type googleProfile struct {
+	Subject       string
+	Email         string
+	Name          string
+	EmailVerified bool
+}
+
+// verifyGoogleCredential validates a Google ID token against our client ID.
+func verifyGoogleCredential(ctx context.Context, credential string) (*googleProfile, error) {
+	clientID := os.Getenv("GOOGLE_CLIENT_ID")
+	if clientID == "" {
+		return nil, fmt.Errorf("GOOGLE_CLIENT_ID not set")
+	}
+
+	payload, err := idtoken.Validate(ctx, credential, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &googleProfile{Subject: payload.Subject}
+	profile.Email, _ = payload.Claims["email"].(string)
+	profile.Name, _ = payload.Claims["name"].(string)
+	profile.EmailVerified, _ = payload.Claims["email_verified"].(bool)
+	if profile.Subject == "" || profile.Email == "" {
+		return nil, fmt.Errorf("google token is missing subject or email")
+	}
+	return profile, nil
+}
+
 func GoogleAuth(c *gin.Context) {
 	var req struct {
 		Credential string `json:"credential"`
@@ -157,88 +344,122 @@ func GoogleAuth(c *gin.Context) {
 		return
 	}
 
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	if clientID == "" {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("GOOGLE_CLIENT_ID not set", nil))
-		return
-	}
-
-	payload, err := idtoken.Validate(context.Background(), req.Credential, clientID)
+	profile, err := verifyGoogleCredential(c.Request.Context(), req.Credential)
 	if err != nil {
 		fmt.Println("Error validating token:", err)
 		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("invalid google token", nil))
 		return
 	}
 
-	email := payload.Claims["email"].(string)
-	name := payload.Claims["name"].(string)
-	sub := payload.Claims["sub"].(string) // Google's unique user ID
+	identity := models.LinkedIdentity{
+		Provider:   models.ProviderGoogle,
+		ProviderID: profile.Subject,
+		Email:      profile.Email,
+		LinkedAt:   time.Now(),
+	}
 
-	// 1. Check if user exists
-	user, err := db.GetUserByEmail(c.Request.Context(), email)
+	// 1. Look the user up by their linked Google identity
+	user, err := db.GetUserByIdentity(c.Request.Context(), models.ProviderGoogle, profile.Subject)
 	var userID string
 
-	if err != nil {
-		// User doesn't exist
+	if err == nil {
+		if req.Type == "signup" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Account already exists. Please sign in.", nil))
+			return
+		}
+		userID = user.ID
+
+		// Rewrites accounts that still use the legacy single-provider fields
+		if user.LegacyAuthProvider != "" {
+			if err := db.LinkIdentity(c.Request.Context(), userID, identity); err != nil {
+				log.Printf("⚠️ Failed to migrate identities for %s: %v", userID, err)
+			}
+		}
+	} else if existing, err := db.GetUserByEmail(c.Request.Context(), profile.Email); err == nil {
+		// 2. An account with this email exists but Google is not linked to it
+		if req.Type == "signup" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Account already exists. Please sign in.", nil))
+			return
+		}
+
+		// Both sides have verified the same address, which proves it is the same person
+		if !existing.EmailVerified || !profile.EmailVerified {
+			c.JSON(http.StatusConflict, models.NewErrorResponse("An account with this email already exists. Sign in with your password and link Google from your account settings.", nil))
+			return
+		}
+		if err := db.LinkIdentity(c.Request.Context(), existing.ID, identity); err != nil {
+			c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+		user = existing
+		userID = existing.ID
+	} else {
+		// 3. User doesn't exist
 		if req.Type == "login" {
 			c.JSON(http.StatusNotFound, models.NewErrorResponse("Account not found. Please sign up first.", nil))
 			return
 		}
 
-		// 2. User doesn't exist, create new one (Sign-Up flow)
-		username := strings.ToLower(strings.ReplaceAll(name, " ", ""))
-		// Basic check for username collision or just use email prefix
-		if len(username) < 3 {
-			username = strings.Split(email, "@")[0]
+		// Create new one (Sign-Up flow), deriving a username from the display name or email prefix
+		base := strings.ReplaceAll(profile.Name, " ", "")
+		if len(base) < 3 {
+			base = strings.Split(profile.Email, "@")[0]
 		}
 
 		newUser := &models.User{
-			Email:        email,
-			FullName:     name,
-			Username:     username,
-			AuthProvider: "google",
-			ProviderID:   sub,
-			CreatedAt:    time.Now(),
-			LastSeen:     time.Now(),
+			Email:         profile.Email,
+			FullName:      profile.Name,
+			EmailVerified: profile.EmailVerified,
+			Identities:    []models.LinkedIdentity{identity},
+			IdentityKeys:  []string{models.IdentityKey(identity.Provider, identity.ProviderID)},
+			CreatedAt:     time.Now(),
+			LastSeen:      time.Now(),
 		}
 
-		userID, err = db.CreateUser(c.Request.Context(), newUser)
+		// Another signup can claim the generated name before we do, so retry a few times
+		for attempt := 0; attempt < 3; attempt++ {
+			newUser.Username, err = db.GenerateAvailableUsername(c.Request.Context(), base)
+			if err != nil {
+				break
+			}
+			userID, err = db.CreateUser(c.Request.Context(), newUser)
+			if !errors.Is(err, db.ErrUsernameTaken) {
+				break
+			}
+		}
+		if errors.Is(err, db.ErrIdentityInUse) {
+			// Another request signed this Google account up first
+			c.JSON(http.StatusConflict, models.NewErrorResponse("Account already exists. Please sign in.", nil))
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to create user", nil))
 			return
 		}
 		user = newUser
 		user.ID = userID
-	} else {
-		// User exists
-		if req.Type == "signup" {
-			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Account already exists. Please sign in.", nil))
-			return
-		}
+	}
 
-		// 3. User exists, allow login
-		userID = user.ID
-		if user.AuthProvider == "" {
-			// Optional: Update existing user to link google account if they used email/pass before
-			// user.AuthProvider = "google"
-			// user.ProviderID = sub
-			// db.UpdateUser(c.Request.Context(), user) 
-		}
+	if rejectSuspended(c, user) {
+		return
 	}
 
-	// 4. Generate internal JWT
-	token, err := utils.GenerateJWT(userID, email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to generate token", nil))
+	// 4. Accounts with 2FA get a short-lived challenge instead of a session
+	if user.TwoFactorEnabled {
+		issueLoginChallenge(c, userID)
 		return
 	}
 
-	// 5. Set auth cookie
-	cookie := utils.NewAuthCookie(userID)
-	http.SetCookie(c.Writer, &cookie)
+	// 5. Start a session, which issues the internal JWT and sets the auth cookies
+	token, refreshToken, err := startSession(c, userID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to start session", nil))
+		return
+	}
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("google login successful", gin.H{
-		"token": token,
+		"token":         token,
+		"refresh_token": refreshToken,
 		"user": gin.H{
 			"id":         userID,
 			"email":      user.Email,
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
 	"github.com/prachin77/insight-hub/models"
 )
 
@@ -16,146 +22,392 @@ func CreateBlog(c *gin.Context) {
 		return
 	}
 
-	// Backend Validation
-	title := strings.TrimSpace(req.Title)
-	if len(title) < 5 || len(title) > 100 {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("title must be between 5 and 100 characters", nil))
+	if !validateBlog(c, &req) {
 		return
 	}
 
-	words := strings.Fields(req.BlogContent)
-	wordCount := len(words)
-	if wordCount < 1 || wordCount > 500 {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("content must be between 1 and 500 words", nil))
+	author, err := db.GetUserByID(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return
+	}
+	if rejectSuspended(c, author) {
 		return
 	}
 
-	isValidCategory := false
-	for _, cat := range models.ValidCategories {
-		if req.Category == cat {
-			isValidCategory = true
-			break
+	// The author is always the authenticated caller, never the request body
+	req.AuthorID = middleware.CurrentUserID(c)
+	// Featured and trending are decided by staff and ranking, not by authors
+	req.Featured = false
+	req.Trending = false
+
+	// Blogs are published straight away unless saved as a draft or scheduled for later
+	switch req.Status {
+	case "", models.BlogStatusPublished:
+		req.Status = models.BlogStatusPublished
+	case models.BlogStatusDraft:
+		req.PublishAt = time.Time{}
+	case models.BlogStatusScheduled:
+		if !validPublishTime(c, req.PublishAt) {
+			return
 		}
-	}
-	if !isValidCategory {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid category", nil))
+	default:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("status must be draft, scheduled or published", nil))
 		return
 	}
 
-	// Unique Title Check
-	exists, err := db.TitleExists(c.Request.Context(), title)
+	// Titles only need to be unique among the author's own blogs, which CreateBlog enforces
+	blogID, err := db.CreateBlog(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to check title uniqueness", nil))
+		saveBlogFailed(c, err)
 		return
 	}
-	if exists {
-		c.JSON(http.StatusConflict, models.NewErrorResponse("a blog with this title already exists", nil))
+
+	if req.Published() {
+		_ = db.NotifyFollowersOfBlog(c.Request.Context(), &req, author.Username)
+	}
+
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("blog created successfully", gin.H{
+		"id":   blogID,
+		"blog": req,
+	}))
+}
+
+// GetBlogs returns one page of blogs. The query accepts limit, cursor, sort (newest, most_viewed,
+// most_liked), category, tag, author (user ID), featured, and from/to dates (RFC 3339 or YYYY-MM-DD).
+// The cursor for the next page is sent in the X-Next-Cursor header so the body stays a plain list.
+func GetBlogs(c *gin.Context) {
+	query, ok := parseBlogListQuery(c)
+	if !ok {
 		return
 	}
 
-	// Validate author ID
-	if req.AuthorID == "" || req.AuthorID == "anonymous" {
-		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("authenticated user required", nil))
+	page, err := db.ListBlogs(c.Request.Context(), query)
+	if errors.Is(err, db.ErrInvalidCursor) || errors.Is(err, db.ErrUnsupportedBlogQuery) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch blogs", nil))
 		return
 	}
 
-	userID, err := db.GetUserID(c.Request.Context(), req.AuthorID)
-	if err == nil {
-		req.AuthorID = userID
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
 	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blogs fetched successfully", page.Blogs))
+}
 
-	blogID, err := db.CreateBlog(c.Request.Context(), &req)
+// GetTrendingBlogs lists the blogs the trending job currently ranks highest, optionally within one category.
+func GetTrendingBlogs(c *gin.Context) {
+	category := c.Query("category")
+	if category != "" && !validCategory(category) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid category", nil))
+		return
+	}
+	limit := models.DefaultBlogPageSize
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxBlogPageSize {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("limit must be between 1 and "+strconv.Itoa(models.MaxBlogPageSize), nil))
+			return
+		}
+	}
+
+	blogs, err := db.GetTrendingBlogs(c.Request.Context(), category, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("trending blogs fetched successfully", blogs))
+}
 
-	// Notify followers
-	followers, _ := db.GetFollowers(c.Request.Context(), req.AuthorID)
-	author, _ := db.GetUserByID(c.Request.Context(), req.AuthorID)
-	for _, followerID := range followers {
-		db.CreateNotification(c.Request.Context(), &models.Notification{
-			Recipient: followerID,
-			Sender:    author.Username,
-			Type:      models.NotificationTypeBlog,
-			Message:   author.Username + " published a new blog \"" + req.Title + "\"",
-			BlogID:    blogID,
-		})
+func parseBlogListQuery(c *gin.Context) (models.BlogListQuery, bool) {
+	query := models.BlogListQuery{
+		Cursor:   c.Query("cursor"),
+		Sort:     c.DefaultQuery("sort", models.BlogSortNewest),
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		AuthorID: c.Query("author"),
+	}
+	fail := func(msg string) (models.BlogListQuery, bool) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(msg, nil))
+		return query, false
 	}
 
-	c.JSON(http.StatusCreated, models.NewSuccessResponse("blog created successfully", gin.H{
-		"id":   blogID,
-		"blog": req,
+	switch query.Sort {
+	case models.BlogSortNewest, models.BlogSortMostViewed, models.BlogSortMostLiked:
+	default:
+		return fail("sort must be one of newest, most_viewed, most_liked")
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxBlogPageSize {
+			return fail("limit must be between 1 and " + strconv.Itoa(models.MaxBlogPageSize))
+		}
+		query.Limit = limit
+	}
+
+	if raw := c.Query("featured"); raw != "" {
+		featured, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fail("featured must be true or false")
+		}
+		query.Featured = &featured
+	}
+
+	var err error
+	if query.From, err = parseDateParam(c.Query("from")); err != nil {
+		return fail("from must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if query.To, err = parseDateParam(c.Query("to")); err != nil {
+		return fail("to must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return fail("from must be before to")
+	}
+	return query, true
+}
+
+func parseDateParam(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// GetBlog returns a blog by ID or slug. Former slugs redirect to the blog's current one.
+func GetBlog(c *gin.Context) {
+	ref := c.Param("id")
+	blog, err := db.GetBlogByID(c.Request.Context(), ref)
+	if err != nil {
+		blogID, serr := db.ResolveBlogSlug(c.Request.Context(), ref)
+		if serr == nil {
+			blog, err = db.GetBlogByID(c.Request.Context(), blogID)
+		}
+	}
+	if err != nil || !blog.VisibleTo(middleware.CurrentUserID(c)) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("blog not found", nil))
+		return
+	}
+	if ref != blog.ID && ref != blog.Slug && blog.Slug != "" {
+		c.Redirect(http.StatusMovedPermanently, "/blogs/"+blog.Slug)
+		return
+	}
+
+	author, err := db.GetUserByID(c.Request.Context(), blog.AuthorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch blog author", nil))
+		return
+	}
+	if author.Suspension.Banned() {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("blog not found", nil))
+		return
+	}
+	if err := db.EnsureRendered(c.Request.Context(), blog); err != nil {
+		log.Printf("⚠️ Failed to save rendered content of blog %s: %v", blog.ID, err)
+	}
+
+	var viewer *blogViewerState
+	if viewerID := middleware.CurrentUserID(c); viewerID != "" {
+		viewer, err = viewerState(c, viewerID, blog)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+		// Blogs the reader has opened are left out of their feed
+		if !viewer.IsAuthor && blog.Published() {
+			if err := db.RecordRead(c.Request.Context(), viewerID, blog.ID); err != nil {
+				log.Printf("⚠️ Failed to record that %s read blog %s: %v", viewerID, blog.ID, err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blog fetched successfully", gin.H{
+		"blog":   blog,
+		"author": author.PublicProfile(blog.AuthorID),
+		"viewer": viewer,
 	}))
 }
 
-func GetBlogs(c *gin.Context) {
-	blogs, err := db.GetAllBlogs(c.Request.Context())
+// blogViewerState is how a blog relates to the signed-in reader; it is omitted for anonymous readers.
+type blogViewerState struct {
+	Liked      bool `json:"liked"`
+	Bookmarked bool `json:"bookmarked"`
+	Following  bool `json:"following"`
+	IsAuthor   bool `json:"is_author"`
+}
+
+func viewerState(c *gin.Context, viewerID string, blog *models.Blog) (*blogViewerState, error) {
+	ctx := c.Request.Context()
+	state := &blogViewerState{IsAuthor: viewerID == blog.AuthorID}
+
+	viewer, err := db.GetUserByID(ctx, viewerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch blogs", nil))
+		return nil, err
+	}
+	for _, username := range blog.LikedBy {
+		if username == viewer.Username {
+			state.Liked = true
+			break
+		}
+	}
+
+	if state.Bookmarked, err = db.IsBookmarked(ctx, viewerID, blog.ID); err != nil {
+		return nil, err
+	}
+	if !state.IsAuthor {
+		if state.Following, err = db.IsFollowing(ctx, viewerID, blog.AuthorID); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// BookmarkBlog saves the blog in the URL for the current user.
+func BookmarkBlog(c *gin.Context) {
+	blog, err := db.GetBlogByID(c.Request.Context(), c.Param("id"))
+	if err != nil || !blog.Published() {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("blog not found", nil))
 		return
 	}
 
-	c.JSON(http.StatusOK, models.NewSuccessResponse("blogs fetched successfully", blogs))
+	if err := db.AddBookmark(c.Request.Context(), middleware.CurrentUserID(c), blog.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blog bookmarked", gin.H{"bookmarked": true}))
 }
-func IncrementViews(c *gin.Context) {
-	var req struct {
-		Title string `json:"title"`
+
+// UnbookmarkBlog removes the blog in the URL from the current user's bookmarks.
+func UnbookmarkBlog(c *gin.Context) {
+	if err := db.RemoveBookmark(c.Request.Context(), middleware.CurrentUserID(c), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
 	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("bookmark removed", gin.H{"bookmarked": false}))
+}
+
+// GetBookmarks lists the current user's bookmarked blogs, most recently saved first.
+func GetBookmarks(c *gin.Context) {
+	blogs, err := db.GetBookmarkedBlogs(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("bookmarks fetched successfully", blogs))
+}
+
+// IncrementViews counts a view of the blog named by id (or, for older clients, title) in the body.
+func IncrementViews(c *gin.Context) {
+	var req blogRef
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
-	if err := db.IncrementViews(c.Request.Context(), req.Title); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+	blog, ok := findBlog(c, req, "")
+	if !ok {
+		return
+	}
+	incrementViews(c, blog.ID)
+}
+
+// IncrementBlogViews counts a view of the blog in the URL.
+func IncrementBlogViews(c *gin.Context) {
+	incrementViews(c, c.Param("id"))
+}
+
+// incrementViews counts a view. Signed-in readers are told apart by their account and visitors by
+// their address, IPv6 ones by the /64 network they are given, so reloading does not add up.
+func incrementViews(c *gin.Context, blogID string) {
+	userID := middleware.CurrentUserID(c)
+	viewer := "user:" + userID
+	if userID == "" {
+		viewer = "ip:" + clientNetwork(c.ClientIP())
+	}
+	if err := db.IncrementViews(c.Request.Context(), blogID, viewer); err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("view count incremented", nil))
 }
 
-func ToggleLike(c *gin.Context) {
-	var req struct {
-		Title    string `json:"title"`
-		Username string `json:"username"`
+// clientNetwork returns an IPv4 address as it is and the /64 network of an IPv6 address.
+func clientNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Unmap().Is4() {
+		return ip
 	}
+	prefix, err := addr.Prefix(64)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// ToggleLike likes or unlikes the blog named by id (or, for older clients, title) in the body.
+func ToggleLike(c *gin.Context) {
+	var req blogRef
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
-	isLiked, err := db.ToggleLike(c.Request.Context(), req.Username, req.Title)
+	blog, ok := findBlog(c, req, "")
+	if !ok {
+		return
+	}
+	toggleLike(c, blog)
+}
+
+// ToggleBlogLike likes or unlikes the blog in the URL.
+func ToggleBlogLike(c *gin.Context) {
+	blog, err := db.GetBlogByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	toggleLike(c, blog)
+}
+
+func toggleLike(c *gin.Context, blog *models.Blog) {
+	if !blog.Published() {
+		c.JSON(http.StatusConflict, models.NewErrorResponse("only published blogs can be liked", nil))
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return
+	}
+
+	isLiked, err := db.ToggleLike(c.Request.Context(), user.Username, blog.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
 	// Create notification if liked
-	if isLiked {
-		blogID, _ := db.GetBlogID(c.Request.Context(), req.Title)
-		blog, _ := db.GetAllBlogs(c.Request.Context()) // This is inefficient, but I'll use it for now as a quick fix or add GetBlogByTitle
-		var targetBlog models.Blog
-		for _, b := range blog {
-			if b.Title == req.Title {
-				targetBlog = b
-				break
-			}
-		}
-
-		if targetBlog.AuthorID != "" && targetBlog.AuthorUsername != req.Username {
-			db.CreateNotification(c.Request.Context(), &models.Notification{
-				Recipient: targetBlog.AuthorID,
-				Sender:    req.Username,
-				Type:      models.NotificationTypeLike,
-				Message:   req.Username + " liked your blog \"" + req.Title + "\"",
-				BlogID:    blogID,
-			})
-		}
+	if isLiked && blog.AuthorID != "" && blog.AuthorID != middleware.CurrentUserID(c) {
+		db.CreateNotification(c.Request.Context(), &models.Notification{
+			Recipient: blog.AuthorID,
+			Sender:    user.Username,
+			Type:      models.NotificationTypeLike,
+			Message:   user.Username + " liked your blog \"" + blog.Title + "\"",
+			BlogID:    blog.ID,
+		})
 	}
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("like status toggled", gin.H{"liked": isLiked}))
-}			
+}
 
 func AddComment(c *gin.Context) {
 	var req models.Comment
@@ -164,22 +416,29 @@ func AddComment(c *gin.Context) {
 		return
 	}
 
+	author, err := db.GetUserByID(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return
+	}
+	if rejectSuspended(c, author) {
+		return
+	}
+	req.AuthorID = middleware.CurrentUserID(c)
+	req.AuthorUsername = author.Username
+
+	targetBlog, err := db.GetBlogByID(c.Request.Context(), req.BlogID)
+	if err != nil || !targetBlog.Published() {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("blog not found", nil))
+		return
+	}
+
 	if err := db.AddComment(c.Request.Context(), &req); err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
 	// Create notification for the blog author
-	// Fetch blog details to get author ID
-	blogs, _ := db.GetAllBlogs(c.Request.Context())
-	var targetBlog models.Blog
-	for _, b := range blogs {
-		if b.ID == req.BlogID {
-			targetBlog = b
-			break
-		}
-	}
-
 	if targetBlog.AuthorID != "" && targetBlog.AuthorID != req.AuthorID {
 		db.CreateNotification(c.Request.Context(), &models.Notification{
 			Recipient: targetBlog.AuthorID,
@@ -193,6 +452,8 @@ func AddComment(c *gin.Context) {
 	c.JSON(http.StatusCreated, models.NewSuccessResponse("comment added successfully", req))
 }
 
+// UpdateBlog edits the blog named by id in the body. Older clients that send no id are matched on
+// the title among the caller's own blogs, in which case the title itself cannot change.
 func UpdateBlog(c *gin.Context) {
 	var req models.Blog
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -200,36 +461,79 @@ func UpdateBlog(c *gin.Context) {
 		return
 	}
 
-	isValidCategory := false
-	for _, cat := range models.ValidCategories {
-		if req.Category == cat {
-			isValidCategory = true
-			break
-		}
+	existing, ok := findBlog(c, blogRef{ID: req.ID, Title: req.Title}, middleware.CurrentUserID(c))
+	if !ok {
+		return
 	}
-	if !isValidCategory {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid category", nil))
+	updateBlog(c, existing, req)
+}
+
+// UpdateBlogByID edits the blog in the URL.
+func UpdateBlogByID(c *gin.Context) {
+	var req models.Blog
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	existing, err := db.GetBlogByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	updateBlog(c, existing, req)
+}
+
+func updateBlog(c *gin.Context, existing *models.Blog, req models.Blog) {
+	if existing.AuthorID != middleware.CurrentUserID(c) {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse("you can only update your own blogs", nil))
+		return
+	}
+	if !validateBlog(c, &req) {
 		return
 	}
 
+	req.ID = existing.ID
 	if err := db.UpdateBlog(c.Request.Context(), &req); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		saveBlogFailed(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("blog updated successfully", req))
 }
 
+// DeleteBlog deletes the blog named by id (or, for older clients, title) in the body.
 func DeleteBlog(c *gin.Context) {
-	var req struct {
-		Title string `json:"title"`
-	}
+	var req blogRef
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 
-	if err := db.DeleteBlog(c.Request.Context(), req.Title); err != nil {
+	existing, ok := findBlog(c, req, middleware.CurrentUserID(c))
+	if !ok {
+		return
+	}
+	deleteBlog(c, existing)
+}
+
+// DeleteBlogByID deletes the blog in the URL.
+func DeleteBlogByID(c *gin.Context) {
+	existing, err := db.GetBlogByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	deleteBlog(c, existing)
+}
+
+func deleteBlog(c *gin.Context, existing *models.Blog) {
+	if existing.AuthorID != middleware.CurrentUserID(c) {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse("you can only delete your own blogs", nil))
+		return
+	}
+
+	if err := db.DeleteBlog(c.Request.Context(), existing.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
@@ -244,7 +548,7 @@ func GetComments(c *gin.Context) {
 		return
 	}
 
-	comments, err := db.GetComments(c.Request.Context(), blogID)
+	comments, err := db.GetVisibleComments(c.Request.Context(), blogID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 		return
@@ -252,3 +556,88 @@ func GetComments(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.NewSuccessResponse("comments fetched successfully", comments))
 }
+
+// blogRef identifies a blog in a request body. ID is preferred; Title is accepted from older clients.
+type blogRef struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// findBlog loads the blog a request body refers to. Title lookups are limited to authorID's blogs
+// when it is set, since titles are only unique per author.
+func findBlog(c *gin.Context, ref blogRef, authorID string) (*models.Blog, bool) {
+	var blog *models.Blog
+	var err error
+	switch {
+	case ref.ID != "":
+		blog, err = db.GetBlogByID(c.Request.Context(), ref.ID)
+	case ref.Title != "":
+		blog, err = db.GetBlogByTitle(c.Request.Context(), ref.Title, authorID)
+	default:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("id is required", nil))
+		return nil, false
+	}
+	if err != nil || !blog.VisibleTo(middleware.CurrentUserID(c)) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("blog not found", nil))
+		return nil, false
+	}
+	return blog, true
+}
+
+// maxBlogContentBytes caps the size of a blog's content, which the word count alone does not:
+// a single word can be arbitrarily long.
+const maxBlogContentBytes = 64 << 10
+
+// validateBlog checks the author-editable fields of a blog, trimming the title in place.
+func validateBlog(c *gin.Context, blog *models.Blog) bool {
+	blog.Title = strings.TrimSpace(blog.Title)
+	if len(blog.Title) < 5 || len(blog.Title) > 100 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("title must be between 5 and 100 characters", nil))
+		return false
+	}
+
+	if len(blog.BlogContent) > maxBlogContentBytes {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("content must be at most 64 KB", nil))
+		return false
+	}
+
+	wordCount := len(strings.Fields(blog.BlogContent))
+	if wordCount < 1 || wordCount > 500 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("content must be between 1 and 500 words", nil))
+		return false
+	}
+
+	switch blog.ContentFormat {
+	case "":
+		blog.ContentFormat = models.ContentFormatPlain
+	case models.ContentFormatPlain, models.ContentFormatMarkdown:
+	default:
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("content_format must be plain or markdown", nil))
+		return false
+	}
+
+	if !validCategory(blog.Category) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid category", nil))
+		return false
+	}
+	return true
+}
+
+func validCategory(category string) bool {
+	for _, cat := range models.ValidCategories {
+		if category == cat {
+			return true
+		}
+	}
+	return false
+}
+
+// saveBlogFailed answers 409 if saving failed because the author already has another blog with
+// the title or an image in it is being garbage collected, and 500 otherwise.
+func saveBlogFailed(c *gin.Context, err error) {
+	if errors.Is(err, db.ErrTitleTaken) || errors.Is(err, db.ErrUploadDeleting) {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/jobs"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// RequestDataExport returns the caller's current personal data export, starting a new one when
+// there is no export in progress and no downloadable archive. Pass refresh=true to rebuild an
+// archive that is still downloadable. Building happens in the background; poll the status endpoint.
+func RequestDataExport(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := middleware.CurrentUserID(c)
+
+	exports, err := db.GetDataExports(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	now := time.Now()
+	if len(exports) > 0 {
+		latest := exports[0]
+		inProgress := latest.Status == models.DataExportPending || latest.Status == models.DataExportRunning
+		if inProgress && now.Sub(latest.UpdatedAt) < jobs.DataExportTimeout {
+			c.JSON(http.StatusAccepted, models.NewSuccessResponse("export is being prepared", exportPayload(&latest)))
+			return
+		}
+		if latest.Status == models.DataExportReady && now.Before(latest.ExpiresAt) && c.Query("refresh") != "true" {
+			c.JSON(http.StatusOK, models.NewSuccessResponse("export is ready", exportPayload(&latest)))
+			return
+		}
+	}
+
+	export, err := db.CreateDataExport(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	go jobs.BuildDataExport(export)
+
+	c.JSON(http.StatusAccepted, models.NewSuccessResponse("export started", exportPayload(export)))
+}
+
+// GetDataExportStatus reports the progress of one of the caller's exports.
+func GetDataExportStatus(c *gin.Context) {
+	export, ok := ownDataExport(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("export fetched successfully", exportPayload(export)))
+}
+
+// DownloadDataExport sends a finished export archive.
+func DownloadDataExport(c *gin.Context) {
+	export, ok := ownDataExport(c)
+	if !ok {
+		return
+	}
+	if export.Status != models.DataExportReady {
+		c.JSON(http.StatusConflict, models.NewErrorResponse("export is not ready yet", nil))
+		return
+	}
+	if export.FileName == "" || time.Now().After(export.ExpiresAt) {
+		c.JSON(http.StatusGone, models.NewErrorResponse("export has expired; request a new one", nil))
+		return
+	}
+
+	path := filepath.Join(jobs.ExportDir(), export.FileName)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusGone, models.NewErrorResponse("export archive is no longer available; request a new one", nil))
+		return
+	}
+
+	c.FileAttachment(path, "insight-hub-export-"+export.CompletedAt.Format("2006-01-02")+".zip")
+}
+
+// ownDataExport loads the export named in the URL, answering 404 if it does not belong to the caller.
+func ownDataExport(c *gin.Context) (*models.DataExport, bool) {
+	export, err := db.GetDataExport(c.Request.Context(), c.Param("id"))
+	if err != nil || export.UserID != middleware.CurrentUserID(c) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("export not found", nil))
+		return nil, false
+	}
+	return export, true
+}
+
+func exportPayload(export *models.DataExport) gin.H {
+	payload := gin.H{
+		"export":     export,
+		"status_url": "/user/me/export/" + export.ID,
+	}
+	if export.Status == models.DataExportReady {
+		payload["download_url"] = "/user/me/export/" + export.ID + "/download"
+	}
+	return payload
+}
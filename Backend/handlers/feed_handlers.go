@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const (
+	// maxFollowedTags bounds the tags a user can follow, each of which widens every feed query.
+	maxFollowedTags = 100
+	maxTagLength    = 50
+)
+
+// GetFeed returns a page of the caller's personalized feed. Like GET /blogs, the body is a list
+// and the cursor of the next page is sent in the X-Next-Cursor header.
+func GetFeed(c *gin.Context) {
+	query := models.FeedQuery{
+		UserID: middleware.CurrentUserID(c),
+		Cursor: c.Query("cursor"),
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxBlogPageSize {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("limit must be between 1 and "+strconv.Itoa(models.MaxBlogPageSize), nil))
+			return
+		}
+		query.Limit = limit
+	}
+
+	page, err := db.GetFeed(c.Request.Context(), query)
+	if errors.Is(err, db.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("feed fetched successfully", page.Items))
+}
+
+// FollowTag adds the tag in the URL to the caller's feed. Tags match blogs exactly as written.
+func FollowTag(c *gin.Context) {
+	tag := strings.TrimSpace(c.Param("tag"))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("tag must be between 1 and 50 characters", nil))
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return
+	}
+	if !containsTag(user.FollowedTags, tag) && len(user.FollowedTags) >= maxFollowedTags {
+		c.JSON(http.StatusConflict, models.NewErrorResponse("you can follow at most 100 tags", nil))
+		return
+	}
+
+	if err := db.FollowTag(c.Request.Context(), userID, tag); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("tag followed", gin.H{"tag": tag}))
+}
+
+// UnfollowTag removes the tag in the URL from the caller's feed.
+func UnfollowTag(c *gin.Context) {
+	tag := strings.TrimSpace(c.Param("tag"))
+	if err := db.UnfollowTag(c.Request.Context(), middleware.CurrentUserID(c), tag); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("tag unfollowed", gin.H{"tag": tag}))
+}
+
+// FollowCategory adds the category in the URL to the caller's feed.
+func FollowCategory(c *gin.Context) {
+	category := c.Param("category")
+	if !validCategory(category) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid category", nil))
+		return
+	}
+	if err := db.FollowCategory(c.Request.Context(), middleware.CurrentUserID(c), category); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("category followed", gin.H{"category": category}))
+}
+
+// UnfollowCategory removes the category in the URL from the caller's feed.
+func UnfollowCategory(c *gin.Context) {
+	category := c.Param("category")
+	if err := db.UnfollowCategory(c.Request.Context(), middleware.CurrentUserID(c), category); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("category unfollowed", gin.H{"category": category}))
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// ListIdentities returns the caller's sign-in methods: whether a password is set and the linked providers.
+func ListIdentities(c *gin.Context) {
+	hasPassword, identities, err := db.GetLoginMethods(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	if identities == nil {
+		identities = []models.LinkedIdentity{}
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("identities fetched successfully", gin.H{
+		"has_password": hasPassword,
+		"identities":   identities,
+	}))
+}
+
+// LinkGoogle connects a Google account to the caller. The caller must prove ownership of the
+// existing account with its password, or both the account and Google must have verified the same email.
+func LinkGoogle(c *gin.Context) {
+	var req struct {
+		Credential string `json:"credential"`
+		Password   string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	profile, err := verifyGoogleCredential(c.Request.Context(), req.Credential)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("invalid google token", nil))
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return
+	}
+
+	verifiedEmailMatch := user.EmailVerified && profile.EmailVerified && user.Email == profile.Email
+	if !verifiedEmailMatch {
+		if req.Password == "" {
+			c.JSON(http.StatusUnauthorized, models.NewErrorResponse("password is required to link this account", nil))
+			return
+		}
+		if err := db.CheckPassword(c.Request.Context(), userID, req.Password); err != nil {
+			c.JSON(http.StatusUnauthorized, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+	}
+
+	err = db.LinkIdentity(c.Request.Context(), userID, models.LinkedIdentity{
+		Provider:   models.ProviderGoogle,
+		ProviderID: profile.Subject,
+		Email:      profile.Email,
+		LinkedAt:   time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("google account linked", nil))
+}
+
+// UnlinkIdentity disconnects a provider from the caller's account.
+func UnlinkIdentity(c *gin.Context) {
+	provider := c.Param("provider")
+
+	if err := db.UnlinkIdentity(c.Request.Context(), middleware.CurrentUserID(c), provider); err != nil {
+		if errors.Is(err, db.ErrLastLoginMethod) {
+			c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse(provider+" account unlinked", nil))
+}
@@ -5,12 +5,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
 	"github.com/prachin77/insight-hub/models"
 )
 
 func ToggleFollow(c *gin.Context) {
 	var req struct {
-		FollowerID  string `json:"follower_id"`
 		FollowingID string `json:"following_id"`
 		Action      string `json:"action"` // "follow" or "unfollow"
 	}
@@ -19,7 +19,13 @@ func ToggleFollow(c *gin.Context) {
 		return
 	}
 
-	isFollowing, _ := db.IsFollowing(c.Request.Context(), req.FollowerID, req.FollowingID)
+	followerID := middleware.CurrentUserID(c)
+	if req.FollowingID == "" || req.FollowingID == followerID {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid following_id", nil))
+		return
+	}
+
+	isFollowing, _ := db.IsFollowing(c.Request.Context(), followerID, req.FollowingID)
 
 	if req.Action == "follow" {
 		if isFollowing {
@@ -27,13 +33,13 @@ func ToggleFollow(c *gin.Context) {
 			return
 		}
 
-		if err := db.FollowUser(c.Request.Context(), req.FollowerID, req.FollowingID); err != nil {
+		if err := db.FollowUser(c.Request.Context(), followerID, req.FollowingID); err != nil {
 			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 			return
 		}
 
 		// Create notification
-		follower, _ := db.GetUserByID(c.Request.Context(), req.FollowerID)
+		follower, _ := db.GetUserByID(c.Request.Context(), followerID)
 		if follower != nil {
 			db.CreateNotification(c.Request.Context(), &models.Notification{
 				Recipient: req.FollowingID,
@@ -50,7 +56,7 @@ func ToggleFollow(c *gin.Context) {
 			return
 		}
 
-		if err := db.UnfollowUser(c.Request.Context(), req.FollowerID, req.FollowingID); err != nil {
+		if err := db.UnfollowUser(c.Request.Context(), followerID, req.FollowingID); err != nil {
 			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
 			return
 		}
@@ -59,11 +65,7 @@ func ToggleFollow(c *gin.Context) {
 }
 
 func GetNotifications(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("user_id is required", nil))
-		return
-	}
+	userID := middleware.CurrentUserID(c)
 
 	notifications, err := db.GetNotifications(c.Request.Context(), userID)
 	if err != nil {
@@ -76,19 +78,15 @@ func GetNotifications(c *gin.Context) {
 
 func MarkNotificationRead(c *gin.Context) {
 	id := c.Param("id")
-	if err := db.MarkNotificationRead(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+	if err := db.MarkNotificationRead(c.Request.Context(), id, middleware.CurrentUserID(c)); err != nil {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(err.Error(), nil))
 		return
 	}
 	c.JSON(http.StatusOK, models.NewSuccessResponse("notification marked as read", nil))
 }
 
 func MarkAllNotificationsRead(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("user_id is required", nil))
-		return
-	}
+	userID := middleware.CurrentUserID(c)
 
 	if err := db.MarkAllNotificationsRead(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
@@ -98,11 +96,7 @@ func MarkAllNotificationsRead(c *gin.Context) {
 }
 
 func GetUnreadCount(c *gin.Context) {
-	userID := c.Query("user_id")
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, models.NewErrorResponse("user_id is required", nil))
-		return
-	}
+	userID := middleware.CurrentUserID(c)
 
 	count, err := db.GetUnreadCount(c.Request.Context(), userID)
 	if err != nil {
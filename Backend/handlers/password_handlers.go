@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/prachin77/insight-hub/chat/Chat_Handlers"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/mailer"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+const passwordResetTTL = time.Hour
+
+const weakPasswordMessage = "password must be at least 8 characters and include one uppercase letter and one special character"
+
+// ForgotPassword emails a password reset link. It responds the same way whether or not the
+// email belongs to an account so it cannot be used to discover registered addresses.
+func ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	const message = "if an account exists for this email, a reset link has been sent"
+
+	user, err := db.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		c.JSON(http.StatusOK, models.NewSuccessResponse(message, nil))
+		return
+	}
+
+	token, err := db.CreateOneTimeToken(c.Request.Context(), user.ID, models.TokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to create reset token", nil))
+		return
+	}
+
+	link := utils.BaseURL() + "/auth/password/reset?token=" + url.QueryEscape(token)
+	err = mailer.Default.Send(c.Request.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Insight Hub password",
+		Body: "Someone asked to reset the password for your Insight Hub account.\n\n" +
+			"Open the link below within the next hour to choose a new password, or use the token in the app:\n\n" +
+			link + "\n\n" + token + "\n\n" +
+			"If you did not request this, you can ignore this email; your password will not change.",
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to send password reset email to %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse(message, nil))
+}
+
+// ResetPassword sets a new password using a reset token and signs the account out everywhere.
+// It takes JSON from API clients and the form served by ResetPasswordForm, answering the form
+// with a page.
+func ResetPassword(c *gin.Context) {
+	var req struct {
+		Token       string `json:"token" form:"token"`
+		NewPassword string `json:"new_password" form:"new_password"`
+	}
+	fromForm := c.ContentType() == binding.MIMEPOSTForm
+	reply := func(status int, message string, retry bool) {
+		if !fromForm {
+			if status == http.StatusOK {
+				c.JSON(status, models.NewSuccessResponse(message, nil))
+			} else {
+				c.JSON(status, models.NewErrorResponse(message, nil))
+			}
+			return
+		}
+		view := resetPasswordView{Message: message, Failed: status != http.StatusOK}
+		if retry {
+			view.Token = req.Token
+		}
+		renderResetPasswordPage(c, status, view)
+	}
+
+	if err := c.ShouldBind(&req); err != nil {
+		reply(http.StatusBadRequest, err.Error(), false)
+		return
+	}
+	if req.Token == "" {
+		req.Token = c.Query("token")
+	}
+
+	if !isStrongPassword(req.NewPassword) {
+		reply(http.StatusBadRequest, weakPasswordMessage, true)
+		return
+	}
+
+	userID, err := db.ConsumeOneTimeToken(c.Request.Context(), req.Token, models.TokenPurposePasswordReset)
+	if err != nil {
+		reply(http.StatusBadRequest, db.ErrInvalidToken.Error(), false)
+		return
+	}
+
+	if err := db.UpdatePassword(c.Request.Context(), userID, req.NewPassword); err != nil {
+		reply(http.StatusInternalServerError, err.Error(), false)
+		return
+	}
+
+	// The reset link reached the inbox, which also proves ownership of the address
+	if err := db.MarkEmailVerified(c.Request.Context(), userID); err != nil {
+		log.Printf("⚠️ Failed to mark email verified for %s: %v", userID, err)
+	}
+
+	if err := db.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		reply(http.StatusInternalServerError, "password changed but failed to revoke sessions", false)
+		return
+	}
+	chat_handlers.CloseUserStreams(userID)
+
+	clearSessionCookies(c)
+	reply(http.StatusOK, "password reset successfully, please sign in again", false)
+}
+
+// ChangePassword replaces the caller's password after checking the current one. Every other
+// session is revoked and the caller gets a fresh session.
+func ChangePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+	if err := db.CheckPassword(c.Request.Context(), userID, req.CurrentPassword); err != nil {
+		if errors.Is(err, db.ErrNoPassword) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("this account has no password yet; use forgot password to set one", nil))
+			return
+		}
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	if !isStrongPassword(req.NewPassword) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(weakPasswordMessage, nil))
+		return
+	}
+
+	if err := db.UpdatePassword(c.Request.Context(), userID, req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	if err := db.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("password changed but failed to revoke sessions", nil))
+		return
+	}
+	chat_handlers.CloseUserStreams(userID)
+
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch user details", nil))
+		return
+	}
+	token, refreshToken, err := startSession(c, userID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to start session", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("password changed successfully", gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+	}))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resetPasswordPage is what the link in the password reset email opens: a form that posts the
+// token and the new password back to ResetPassword, and the outcome once it has.
+var resetPasswordPage = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Reset your Insight Hub password</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; color: #111; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; }
+input { margin: .25rem 0 1rem; padding: .5rem; }
+button { padding: .6rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<h1>Reset your password</h1>
+{{if .Message}}<p{{if .Failed}} class="error"{{end}}>{{.Message}}</p>{{end}}
+{{if .Token}}
+<form method="post" action="/auth/password/reset">
+<input type="hidden" name="token" value="{{.Token}}">
+<label for="new_password">New password</label>
+<input id="new_password" name="new_password" type="password" autocomplete="new-password" required minlength="8">
+<button type="submit">Set new password</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type resetPasswordView struct {
+	Token   string // set while the form should be shown
+	Message string
+	Failed  bool
+}
+
+// ResetPasswordForm serves the page the reset email links to.
+func ResetPasswordForm(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		renderResetPasswordPage(c, http.StatusBadRequest, resetPasswordView{Message: "This reset link is incomplete; ask for a new one.", Failed: true})
+		return
+	}
+	renderResetPasswordPage(c, http.StatusOK, resetPasswordView{Token: token})
+}
+
+// renderResetPasswordPage writes the page. The token is in its URL and form, so it is neither
+// cached nor sent on as a referrer, and the page runs no scripts.
+func renderResetPasswordPage(c *gin.Context, status int, view resetPasswordView) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := resetPasswordPage.Execute(c.Writer, view); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const (
+	maxFullNameLength = 50
+	maxBioLength      = 280
+	maxLocationLength = 100
+	maxURLLength      = 500
+)
+
+// GetMe returns the caller's own profile.
+func GetMe(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return
+	}
+
+	user.ID = userID
+	c.JSON(http.StatusOK, models.NewSuccessResponse("user fetched successfully", user))
+}
+
+// UpdateProfile edits the caller's profile. Only the fields present in the request are changed.
+func UpdateProfile(c *gin.Context) {
+	var req struct {
+		FullName    *string            `json:"fullName"`
+		Username    *string            `json:"username"`
+		Bio         *string            `json:"bio"`
+		AvatarURL   *string            `json:"avatar_url"`
+		Website     *string            `json:"website"`
+		Location    *string            `json:"location"`
+		SocialLinks *map[string]string `json:"social_links"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	var updates []firestore.Update
+	if req.FullName != nil {
+		name := strings.TrimSpace(*req.FullName)
+		if name == "" || utf8.RuneCountInString(name) > maxFullNameLength {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("full name must be between 1 and 50 characters", nil))
+			return
+		}
+		updates = append(updates, firestore.Update{Path: "FullName", Value: name})
+	}
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("bio must be at most 280 characters", nil))
+			return
+		}
+		updates = append(updates, firestore.Update{Path: "Bio", Value: bio})
+	}
+	if req.Location != nil {
+		location := strings.TrimSpace(*req.Location)
+		if utf8.RuneCountInString(location) > maxLocationLength {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("location must be at most 100 characters", nil))
+			return
+		}
+		updates = append(updates, firestore.Update{Path: "Location", Value: location})
+	}
+	if req.AvatarURL != nil {
+		avatar, err := validateProfileURL(*req.AvatarURL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("avatar_url: "+err.Error(), nil))
+			return
+		}
+		// Avatars pointing at an uploaded image keep it from being garbage collected
+		avatarUpload := ""
+		if ids := models.UploadIDs(avatar); len(ids) > 0 {
+			avatarUpload = ids[0]
+		}
+		updates = append(updates,
+			firestore.Update{Path: "AvatarURL", Value: avatar},
+			firestore.Update{Path: "AvatarUpload", Value: avatarUpload},
+		)
+	}
+	if req.Website != nil {
+		website, err := validateProfileURL(*req.Website)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("website: "+err.Error(), nil))
+			return
+		}
+		updates = append(updates, firestore.Update{Path: "Website", Value: website})
+	}
+	if req.SocialLinks != nil {
+		links := make(map[string]string)
+		for network, link := range *req.SocialLinks {
+			network = strings.ToLower(strings.TrimSpace(network))
+			if !isSocialNetwork(network) {
+				c.JSON(http.StatusBadRequest, models.NewErrorResponse("unsupported social network: "+network, nil))
+				return
+			}
+			normalized, err := validateProfileURL(link)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.NewErrorResponse(network+": "+err.Error(), nil))
+				return
+			}
+			if normalized != "" {
+				links[network] = normalized
+			}
+		}
+		updates = append(updates, firestore.Update{Path: "SocialLinks", Value: links})
+	}
+
+	var username string
+	if req.Username != nil {
+		username = strings.TrimSpace(*req.Username)
+		if err := db.ValidateUsername(username); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+	}
+
+	// Every field is valid by now. Username changes go through the username index so uniqueness
+	// holds, and the other fields are written in the same transaction
+	userID := middleware.CurrentUserID(c)
+	var err error
+	if req.Username != nil {
+		err = db.ChangeUsername(c.Request.Context(), userID, username, updates)
+	} else {
+		err = db.UpdateProfile(c.Request.Context(), userID, updates)
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrUsernameTaken) || errors.Is(err, db.ErrUploadDeleting) {
+			c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch user details", nil))
+		return
+	}
+	user.ID = userID
+	c.JSON(http.StatusOK, models.NewSuccessResponse("profile updated successfully", user))
+}
+
+// validateProfileURL trims a user-supplied link and checks it is an absolute http(s) URL.
+// An empty value is allowed and clears the field.
+func validateProfileURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > maxURLLength {
+		return "", errors.New("URL is too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("must be a valid http or https URL")
+	}
+	return u.String(), nil
+}
+
+func isSocialNetwork(network string) bool {
+	for _, n := range models.SocialNetworks {
+		if n == network {
+			return true
+		}
+	}
+	return false
+}
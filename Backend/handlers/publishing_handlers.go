@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// maxScheduleAhead is how far in the future a blog may be scheduled.
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// GetMyDrafts lists the current user's drafts and scheduled blogs.
+func GetMyDrafts(c *gin.Context) {
+	drafts, err := db.GetDraftsByAuthor(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("drafts fetched successfully", drafts))
+}
+
+// PublishBlog publishes one of the caller's drafts or scheduled blogs now and notifies their followers.
+func PublishBlog(c *gin.Context) {
+	author, ok := draftAuthor(c)
+	if !ok {
+		return
+	}
+	if rejectSuspended(c, author) {
+		return
+	}
+
+	blog, err := db.PublishBlog(c.Request.Context(), c.Param("id"))
+	if !publishingDone(c, err) {
+		return
+	}
+	_ = db.NotifyFollowersOfBlog(c.Request.Context(), blog, author.Username)
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blog published successfully", blog))
+}
+
+// ScheduleBlog sets one of the caller's drafts to be published at publish_at.
+func ScheduleBlog(c *gin.Context) {
+	var req struct {
+		PublishAt time.Time `json:"publish_at" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	if !validPublishTime(c, req.PublishAt) {
+		return
+	}
+	author, ok := draftAuthor(c)
+	if !ok {
+		return
+	}
+	if rejectSuspended(c, author) {
+		return
+	}
+
+	blog, err := db.ScheduleBlog(c.Request.Context(), c.Param("id"), req.PublishAt)
+	if !publishingDone(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blog scheduled successfully", blog))
+}
+
+// UnscheduleBlog turns one of the caller's scheduled blogs back into a draft.
+func UnscheduleBlog(c *gin.Context) {
+	if _, ok := draftAuthor(c); !ok {
+		return
+	}
+
+	blog, err := db.UnscheduleBlog(c.Request.Context(), c.Param("id"))
+	if !publishingDone(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("blog moved back to drafts", blog))
+}
+
+// draftAuthor loads the caller after checking that they wrote the blog in the URL.
+func draftAuthor(c *gin.Context) (*models.User, bool) {
+	blog, ok := findBlog(c, blogRef{ID: c.Param("id")}, "")
+	if !ok {
+		return nil, false
+	}
+	if blog.AuthorID != middleware.CurrentUserID(c) {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse("you can only publish your own blogs", nil))
+		return nil, false
+	}
+
+	author, err := db.GetUserByID(c.Request.Context(), blog.AuthorID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return nil, false
+	}
+	return author, true
+}
+
+func publishingDone(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, db.ErrAlreadyPublished):
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+	default:
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+	}
+	return false
+}
+
+// validPublishTime answers 400 unless at is in the future and within maxScheduleAhead.
+func validPublishTime(c *gin.Context, at time.Time) bool {
+	now := time.Now()
+	if !at.After(now) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("publish_at must be in the future", nil))
+		return false
+	}
+	if at.After(now.Add(maxScheduleAhead)) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("publish_at must be within a year", nil))
+		return false
+	}
+	return true
+}
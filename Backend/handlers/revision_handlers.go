@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// ListBlogRevisions lists the revisions of one of the caller's blogs, newest first.
+func ListBlogRevisions(c *gin.Context) {
+	blog, ok := ownBlog(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := db.ListRevisions(c.Request.Context(), blog.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("revisions fetched successfully", revisions))
+}
+
+// GetBlogRevision returns one revision of one of the caller's blogs.
+func GetBlogRevision(c *gin.Context) {
+	blog, ok := ownBlog(c)
+	if !ok {
+		return
+	}
+	number, ok := revisionNumber(c, "rev", c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	rev, err := db.GetRevision(c.Request.Context(), blog.ID, number)
+	if !revisionFound(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("revision fetched successfully", rev))
+}
+
+// DiffBlogRevisions compares revision from with revision to (the current one by default). The content
+// is diffed by word, or by line with mode=line; the other fields are reported when they differ.
+func DiffBlogRevisions(c *gin.Context) {
+	blog, ok := ownBlog(c)
+	if !ok {
+		return
+	}
+	from, ok := revisionNumber(c, "from", c.Query("from"))
+	if !ok {
+		return
+	}
+	to := blog.Revision
+	if raw := c.Query("to"); raw != "" || to == 0 {
+		if to, ok = revisionNumber(c, "to", raw); !ok {
+			return
+		}
+	}
+	mode := c.DefaultQuery("mode", "word")
+	if mode != "word" && mode != "line" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("mode must be word or line", nil))
+		return
+	}
+
+	older, err := db.GetRevision(c.Request.Context(), blog.ID, from)
+	if !revisionFound(c, err) {
+		return
+	}
+	newer, err := db.GetRevision(c.Request.Context(), blog.ID, to)
+	if !revisionFound(c, err) {
+		return
+	}
+
+	content := utils.DiffWords(older.BlogContent, newer.BlogContent)
+	if mode == "line" {
+		content = utils.DiffLines(older.BlogContent, newer.BlogContent)
+	}
+	changes := gin.H{}
+	if older.Title != newer.Title {
+		changes["title"] = gin.H{"from": older.Title, "to": newer.Title}
+	}
+	if older.Format() != newer.Format() {
+		changes["content_format"] = gin.H{"from": older.Format(), "to": newer.Format()}
+	}
+	if older.Category != newer.Category {
+		changes["category"] = gin.H{"from": older.Category, "to": newer.Category}
+	}
+	if strings.Join(older.Tags, "\x00") != strings.Join(newer.Tags, "\x00") {
+		changes["tags"] = gin.H{"from": older.Tags, "to": newer.Tags}
+	}
+	if older.BlogImage != newer.BlogImage {
+		changes["blog_image"] = gin.H{"from": older.BlogImage, "to": newer.BlogImage}
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("diff computed successfully", gin.H{
+		"from":    from,
+		"to":      to,
+		"mode":    mode,
+		"changes": changes,
+		"content": content,
+	}))
+}
+
+// RestoreBlogRevision makes an earlier revision of one of the caller's blogs current again,
+// saving it as a new revision.
+func RestoreBlogRevision(c *gin.Context) {
+	blog, ok := ownBlog(c)
+	if !ok {
+		return
+	}
+	number, ok := revisionNumber(c, "rev", c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	restored, err := db.RestoreRevision(c.Request.Context(), blog.ID, number)
+	if !revisionFound(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("revision restored successfully", restored))
+}
+
+// ownBlog loads the blog in the URL, answering 403 unless the caller wrote it.
+func ownBlog(c *gin.Context) (*models.Blog, bool) {
+	blog, ok := findBlog(c, blogRef{ID: c.Param("id")}, "")
+	if !ok {
+		return nil, false
+	}
+	if blog.AuthorID != middleware.CurrentUserID(c) {
+		c.JSON(http.StatusForbidden, models.NewErrorResponse("you can only view the history of your own blogs", nil))
+		return nil, false
+	}
+	return blog, true
+}
+
+func revisionNumber(c *gin.Context, name, raw string) (int, bool) {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(name+" must be a revision number", nil))
+		return 0, false
+	}
+	return n, true
+}
+
+func revisionFound(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, db.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, models.NewErrorResponse(err.Error(), nil))
+	case errors.Is(err, db.ErrTitleTaken), errors.Is(err, db.ErrUploadDeleting):
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+	default:
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/search"
+)
+
+// Limits on a search request.
+const (
+	maxSearchQueryLength = 200
+	maxSearchHits        = 50
+	maxSearchOffset      = 1000
+)
+
+// searchHit is a matching blog with the highlighted title and snippet that matched.
+type searchHit struct {
+	Blog       models.Blog `json:"blog"`
+	Score      float64     `json:"score"`
+	Highlights gin.H       `json:"highlights"`
+}
+
+// SearchBlogs ranks blogs against q across title, tags, category and content. q supports
+// "quoted phrases" and prefix* terms; category and tag narrow the results, and limit/offset page them.
+func SearchBlogs(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("q is required", nil))
+		return
+	}
+	if len(q) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("q must be at most "+strconv.Itoa(maxSearchQueryLength)+" characters", nil))
+		return
+	}
+
+	query := search.Query{
+		Text:     q,
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		Limit:    10,
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxSearchHits {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("limit must be between 1 and "+strconv.Itoa(maxSearchHits), nil))
+			return
+		}
+		query.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 || offset > maxSearchOffset {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("offset must be between 0 and "+strconv.Itoa(maxSearchOffset), nil))
+			return
+		}
+		query.Offset = offset
+	}
+
+	results, err := search.Default.Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("search failed", nil))
+		return
+	}
+
+	ids := make([]string, len(results.Hits))
+	for i, hit := range results.Hits {
+		ids[i] = hit.ID
+	}
+	blogs, err := db.GetBlogsByIDs(c.Request.Context(), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch blogs", nil))
+		return
+	}
+	byID := make(map[string]models.Blog, len(blogs))
+	for _, b := range blogs {
+		byID[b.ID] = b
+	}
+
+	hits := make([]searchHit, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		blog, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		hits = append(hits, searchHit{
+			Blog:       blog,
+			Score:      hit.Score,
+			Highlights: gin.H{"title": hit.Title, "snippet": hit.Snippet},
+		})
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("search completed", gin.H{
+		"total":  results.Total,
+		"hits":   hits,
+		"facets": results.Facets,
+	}))
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/chat/Chat_Handlers"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// startSession creates a server-side session for the user, sets the access and refresh
+// cookies and returns both tokens so they can also be handed to non-browser clients.
+func startSession(c *gin.Context, userID, email string) (token, refreshToken string, err error) {
+	session, refreshToken, err := db.CreateSession(c.Request.Context(), userID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return "", "", err
+	}
+
+	token, err = utils.GenerateJWT(userID, email, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	setSessionCookies(c, token, refreshToken)
+	return token, refreshToken, nil
+}
+
+func setSessionCookies(c *gin.Context, accessToken, refreshToken string) {
+	authCookie := utils.NewAuthCookie(accessToken)
+	http.SetCookie(c.Writer, &authCookie)
+	refreshCookie := utils.NewRefreshCookie(refreshToken)
+	http.SetCookie(c.Writer, &refreshCookie)
+}
+
+func clearSessionCookies(c *gin.Context) {
+	authCookie := utils.ExpireCookie(utils.NewAuthCookie(""))
+	http.SetCookie(c.Writer, &authCookie)
+	refreshCookie := utils.ExpireCookie(utils.NewRefreshCookie(""))
+	http.SetCookie(c.Writer, &refreshCookie)
+}
+
+// RefreshSession rotates the refresh token and issues a fresh access token for the same session.
+// The refresh token is read from the refresh cookie or, for non-browser clients, the request body.
+func RefreshSession(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = c.ShouldBindJSON(&req)
+	if req.RefreshToken == "" {
+		if cookie, err := c.Request.Cookie("refresh_token"); err == nil {
+			req.RefreshToken = cookie.Value
+		}
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("refresh token is required", nil))
+		return
+	}
+
+	session, refreshToken, err := db.RotateRefreshToken(c.Request.Context(), req.RefreshToken, c.ClientIP())
+	if err != nil {
+		clearSessionCookies(c)
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(db.ErrInvalidRefreshToken.Error(), nil))
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return
+	}
+
+	token, err := utils.GenerateJWT(session.UserID, user.Email, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to generate token", nil))
+		return
+	}
+
+	setSessionCookies(c, token, refreshToken)
+	c.JSON(http.StatusOK, models.NewSuccessResponse("session refreshed", gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_at":    session.ExpiresAt,
+	}))
+}
+
+// LogoutAll revokes every session of the caller, signing them out on all devices.
+func LogoutAll(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	if err := db.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	chat_handlers.CloseUserStreams(userID)
+
+	clearSessionCookies(c)
+	c.JSON(http.StatusOK, models.NewSuccessResponse("logged out of all devices", nil))
+}
+
+// ListSessions returns the devices the caller is signed in on, marking the one making the request.
+func ListSessions(c *gin.Context) {
+	sessions, err := db.ListSessions(c.Request.Context(), middleware.CurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	current := middleware.CurrentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("sessions fetched successfully", sessions))
+}
+
+// RevokeSession signs one of the caller's devices out and closes its chat connection.
+func RevokeSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	session, err := db.GetSession(c.Request.Context(), sessionID)
+	if err != nil || session.UserID != middleware.CurrentUserID(c) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("session not found", nil))
+		return
+	}
+
+	if err := db.RevokeSession(c.Request.Context(), sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+	chat_handlers.CloseSessionStreams(sessionID)
+
+	if sessionID == middleware.CurrentSessionID(c) {
+		clearSessionCookies(c)
+	}
+	c.JSON(http.StatusOK, models.NewSuccessResponse("session revoked", nil))
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/loginguard"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+const (
+	totpIssuer             = "Insight Hub"
+	loginChallengeTTL      = 5 * time.Minute
+	maxLoginChallengeTries = 5
+	recoveryCodeCount      = 10
+)
+
+// issueLoginChallenge answers a correct first factor for a 2FA account with a challenge token
+// that must be exchanged, together with a code, at /login/2fa.
+func issueLoginChallenge(c *gin.Context, userID string) {
+	challenge, err := db.CreateOneTimeToken(c.Request.Context(), userID, models.TokenPurposeLoginChallenge, loginChallengeTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to create login challenge", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("two-factor authentication required", gin.H{
+		"two_factor_required": true,
+		"challenge":           challenge,
+		"expires_in":          int(loginChallengeTTL.Seconds()),
+	}))
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge plus a TOTP or recovery code for a session.
+func CompleteTwoFactorLogin(c *gin.Context) {
+	var req struct {
+		Challenge    string `json:"challenge"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	// Every guess spends one of the challenge's attempts, and failures also count against the
+	// account in the login guard, so new challenges from /login do not reset the limit
+	challenge, err := db.AttemptOneTimeToken(c.Request.Context(), req.Challenge, models.TokenPurposeLoginChallenge, maxLoginChallengeTries)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("login challenge is invalid or has expired, please sign in again", nil))
+		return
+	}
+
+	user, err := db.GetUserByID(c.Request.Context(), challenge.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to fetch user details", nil))
+		return
+	}
+	if !verifySecondFactor(c, challenge.UserID, user.Email, req.Code, req.RecoveryCode) {
+		return
+	}
+
+	userID, err := db.ConsumeOneTimeToken(c.Request.Context(), req.Challenge, models.TokenPurposeLoginChallenge)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("login challenge is invalid or has expired, please sign in again", nil))
+		return
+	}
+	if rejectSuspended(c, user) {
+		return
+	}
+
+	token, refreshToken, err := startSession(c, userID, user.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to start session", nil))
+		return
+	}
+	resetLoginFailures(c, user.Email)
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("login successful", loginPayload(userID, user, token, refreshToken)))
+}
+
+// SetupTwoFactor starts TOTP enrolment by generating a secret and its provisioning URI.
+// Nothing changes for the account until the secret is confirmed with ConfirmTwoFactor.
+func SetupTwoFactor(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, models.NewErrorResponse("two-factor authentication is already enabled", nil))
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to generate secret", nil))
+		return
+	}
+	if err := db.SetPendingTOTPSecret(c.Request.Context(), userID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("scan the QR code with your authenticator app, then confirm a code", gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	}))
+}
+
+// ConfirmTwoFactor enables 2FA once the user proves their authenticator produces valid codes,
+// and returns the recovery codes. They are shown only this once.
+func ConfirmTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return
+	}
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusConflict, models.NewErrorResponse("two-factor authentication is already enabled", nil))
+		return
+	}
+	if user.PendingTOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("start two-factor setup first", nil))
+		return
+	}
+
+	step, ok := utils.VerifyTOTP(user.PendingTOTPSecret, req.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("invalid two-factor code", nil))
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to generate recovery codes", nil))
+		return
+	}
+	if err := db.EnableTwoFactor(c.Request.Context(), userID, user.PendingTOTPSecret, step, codes); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("two-factor authentication enabled", gin.H{
+		"recovery_codes": codes,
+	}))
+}
+
+// DisableTwoFactor turns 2FA off after checking a current TOTP or recovery code.
+func DisableTwoFactor(c *gin.Context) {
+	if !requireSecondFactor(c) {
+		return
+	}
+
+	if err := db.DisableTwoFactor(c.Request.Context(), middleware.CurrentUserID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("two-factor authentication disabled", nil))
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a current code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	if !requireSecondFactor(c) {
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to generate recovery codes", nil))
+		return
+	}
+	if err := db.ReplaceRecoveryCodes(c.Request.Context(), middleware.CurrentUserID(c), codes); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("recovery codes regenerated", gin.H{
+		"recovery_codes": codes,
+	}))
+}
+
+// requireSecondFactor checks the code or recovery_code in the request body for the caller,
+// writing the error response and returning false when it does not verify.
+func requireSecondFactor(c *gin.Context) bool {
+	var req struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), nil))
+		return false
+	}
+
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return false
+	}
+	return verifySecondFactor(c, userID, user.Email, req.Code, req.RecoveryCode)
+}
+
+// verifySecondFactor checks a TOTP or recovery code for the user. Like passwords, codes are
+// throttled per account and client IP by the login guard.
+func verifySecondFactor(c *gin.Context, userID, email, code, recoveryCode string) bool {
+	if code == "" && recoveryCode == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("code or recovery_code is required", nil))
+		return false
+	}
+
+	ip := c.ClientIP()
+	if wait, err := loginguard.Default.Check(c.Request.Context(), email, ip); err != nil {
+		log.Printf("⚠️ Login guard check failed: %v", err)
+	} else if wait > 0 {
+		tooManyLoginAttempts(c, wait)
+		return false
+	}
+
+	var valid bool
+	var err error
+	if code != "" {
+		valid, err = db.UseTOTPCode(c.Request.Context(), userID, code)
+	} else {
+		valid, err = db.UseRecoveryCode(c.Request.Context(), userID, recoveryCode)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return false
+	}
+	if !valid {
+		outcome, gerr := loginguard.Default.Fail(c.Request.Context(), email, ip)
+		if gerr != nil {
+			log.Printf("⚠️ Failed to record two-factor failure: %v", gerr)
+		} else if outcome.AccountLocked {
+			go notifyLockout(email, outcome.LockedUntil)
+		}
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse("invalid two-factor code", nil))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/imaging"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/storage"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// maxUploadBytes limits the size of an uploaded image file.
+const maxUploadBytes = 10 << 20
+
+// imageSlots limits how many uploads are decoded at once, since a decoded image takes far more
+// memory than its file.
+var imageSlots = make(chan struct{}, 4)
+
+// UploadImage stores an image sent as the "image" field of a multipart form, for use as a blog
+// image or inside blog content, and returns its URL and those of its resized variants.
+func UploadImage(c *gin.Context) {
+	upload, ok := receiveImage(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, models.NewSuccessResponse("image uploaded successfully", upload))
+}
+
+// UploadAvatar stores an uploaded image and makes it the caller's avatar.
+func UploadAvatar(c *gin.Context) {
+	upload, ok := receiveImage(c)
+	if !ok {
+		return
+	}
+
+	// Avatars are shown small, so point at the smallest variant when there is one
+	avatarURL := upload.URL
+	for _, v := range upload.Variants {
+		if v.Name == "w320" {
+			avatarURL = v.URL
+		}
+	}
+
+	userID := middleware.CurrentUserID(c)
+	if err := db.UpdateProfile(c.Request.Context(), userID, []firestore.Update{
+		{Path: "AvatarURL", Value: avatarURL},
+		{Path: "AvatarUpload", Value: upload.ID},
+	}); err != nil {
+		if errors.Is(err, db.ErrUploadDeleting) {
+			c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("avatar updated successfully", gin.H{
+		"avatar_url": avatarURL,
+		"upload":     upload,
+	}))
+}
+
+// ServeUpload serves a stored image or variant. Files never change once stored, since they are
+// named after their content, so they can be cached for good.
+func ServeUpload(c *gin.Context) {
+	key := c.Param("key")
+	if !storage.ValidKey(key) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("file not found", nil))
+		return
+	}
+
+	data, contentType, err := storage.Default.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("file not found", nil))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to read file", nil))
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// receiveImage reads, processes and stores the uploaded image, answering the request itself on failure.
+func receiveImage(c *gin.Context) (*models.Upload, bool) {
+	// Leave room for the rest of the multipart body around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes+1<<20)
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse("image must be at most 10 MB", nil))
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("an image file is required in the \"image\" field", nil))
+		return nil, false
+	}
+	defer file.Close()
+	if header.Size > maxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse("image must be at most 10 MB", nil))
+		return nil, false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("failed to read image", nil))
+		return nil, false
+	}
+	if len(data) > maxUploadBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse("image must be at most 10 MB", nil))
+		return nil, false
+	}
+
+	upload, err := storeImage(c.Request.Context(), middleware.CurrentUserID(c), data)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, models.NewErrorResponse(err.Error(), nil))
+		return nil, false
+	case errors.Is(err, imaging.ErrTooLarge), errors.Is(err, imaging.ErrInvalidImage):
+		c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse(err.Error(), nil))
+		return nil, false
+	case errors.Is(err, db.ErrUploadDeleting):
+		c.JSON(http.StatusConflict, models.NewErrorResponse(err.Error(), nil))
+		return nil, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to store image", nil))
+		return nil, false
+	}
+	return upload, true
+}
+
+// storeImage strips, resizes and stores an image under names derived from its content, and records it.
+func storeImage(ctx context.Context, userID string, data []byte) (*models.Upload, error) {
+	imageSlots <- struct{}{}
+	result, err := imaging.Process(data)
+	<-imageSlots
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(result.Original.Data)
+	id := hex.EncodeToString(sum[:])
+	upload := &models.Upload{
+		ID:          id,
+		UploadedBy:  userID,
+		ContentType: result.Original.ContentType,
+		Width:       result.Original.Width,
+		Height:      result.Original.Height,
+		SizeBytes:   int64(len(result.Original.Data)),
+	}
+
+	files := append([]imaging.File{result.Original}, result.Variants...)
+	keys := make([]string, len(files))
+	for i, f := range files {
+		keys[i] = id + "." + f.Ext
+		if f.Variant != "" {
+			keys[i] = id + "_" + f.Variant + "." + f.Ext
+		}
+		upload.Keys = append(upload.Keys, keys[i])
+
+		url := utils.BaseURL() + "/uploads/" + keys[i]
+		if f.Variant == "" {
+			upload.URL = url
+			continue
+		}
+		upload.Variants = append(upload.Variants, models.UploadVariant{
+			Name:        f.Variant,
+			URL:         url,
+			ContentType: f.ContentType,
+			Width:       f.Width,
+			Height:      f.Height,
+		})
+	}
+
+	// Recording the upload first keeps garbage collection from deleting the files being stored:
+	// it cannot start once the upload is marked as used, and SaveUpload refuses while it runs
+	if err := db.SaveUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		if err := storage.Default.Put(ctx, keys[i], f.ContentType, f.Data); err != nil {
+			return nil, err
+		}
+	}
+	return upload, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/mailer"
+	"github.com/prachin77/insight-hub/middleware"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// sendVerificationEmail issues a fresh verification token for the user and mails the link to them.
+func sendVerificationEmail(ctx context.Context, userID, email string) error {
+	token, err := db.CreateOneTimeToken(ctx, userID, models.TokenPurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := utils.BaseURL() + "/auth/verify?token=" + url.QueryEscape(token)
+	return mailer.Default.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your Insight Hub email address",
+		Body: "Welcome to Insight Hub!\n\n" +
+			"Please confirm your email address by opening the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in 24 hours. If you did not create an account, you can ignore this email.",
+	})
+}
+
+// VerifyEmail consumes a verification token and marks the owner's email as verified.
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("token is required", nil))
+		return
+	}
+
+	userID, err := db.ConsumeOneTimeToken(c.Request.Context(), token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(db.ErrInvalidToken.Error(), nil))
+		return
+	}
+
+	if err := db.MarkEmailVerified(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(err.Error(), nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("email verified successfully", nil))
+}
+
+// ResendVerification sends a new verification link to the caller, invalidating the previous one.
+func ResendVerification(c *gin.Context) {
+	userID := middleware.CurrentUserID(c)
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("user not found", nil))
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusOK, models.NewSuccessResponse("email already verified", nil))
+		return
+	}
+
+	if err := sendVerificationEmail(c.Request.Context(), userID, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("failed to send verification email", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSuccessResponse("verification email sent", nil))
+}
@@ -0,0 +1,68 @@
+package imaging
+
+import "encoding/binary"
+
+// gifFrames walks the blocks of a GIF without decoding any image data, returning the number of
+// frames and their combined area in pixels. ok is false when the file is not laid out as a GIF.
+func gifFrames(data []byte) (frames int, pixels int64, ok bool) {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, false
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x3B:
+			// Trailer
+			return frames, pixels, true
+		case 0x21:
+			// Extension: a label byte, then data sub-blocks
+			if i+2 > len(data) {
+				return 0, 0, false
+			}
+			if i = skipSubBlocks(data, i+2); i < 0 {
+				return 0, 0, false
+			}
+		case 0x2C:
+			// Image descriptor, optional local color table, LZW code size, then data sub-blocks
+			if i+10 > len(data) {
+				return 0, 0, false
+			}
+			w := int64(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int64(binary.LittleEndian.Uint16(data[i+7:]))
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			if i+1 > len(data) {
+				return 0, 0, false
+			}
+			if i = skipSubBlocks(data, i+1); i < 0 {
+				return 0, 0, false
+			}
+			frames++
+			pixels += w * h
+		default:
+			return 0, 0, false
+		}
+	}
+	return 0, 0, false
+}
+
+// skipSubBlocks returns the index after the sub-blocks starting at i, or -1 if they run past the end.
+func skipSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
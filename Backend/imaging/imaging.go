@@ -0,0 +1,246 @@
+// Package imaging turns uploaded images into the files that are stored and served: it checks their
+// type and dimensions, re-encodes them without metadata and makes resized variants.
+//
+// Re-encoding drops EXIF, XMP and ICC data, GPS positions included, after the EXIF orientation has
+// been applied to the pixels. WebP uploads are stored as PNG or JPEG like the rest, and every image
+// gets WebP variants alongside, encoded losslessly by EncodeWebP unless WebPEncoder is replaced.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type; use JPEG, PNG, GIF or WebP")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	ErrInvalidImage    = errors.New("image could not be decoded")
+)
+
+const (
+	// MaxSide and MaxPixels bound the decoded size of an image, which can be far larger than its file.
+	MaxSide   = 12000
+	MaxPixels = 40_000_000
+	// maxGIFFrames and maxGIFPixels bound the work and memory of re-encoding animated GIFs; a
+	// decoded frame takes a byte per pixel.
+	maxGIFFrames = 500
+	maxGIFPixels = 100_000_000
+	jpegQuality  = 88
+)
+
+// VariantWidths are the widths of the resized variants. Images are never enlarged, so a variant
+// is only made when the image is wider.
+var VariantWidths = []int{320, 960}
+
+// WebPEncoder makes the WebP copies of the image and of each resized variant. Replace it with a
+// lossy encoder, such as a libwebp binding, for smaller photos; set it to nil to make none.
+var WebPEncoder func(w io.Writer, img image.Image) error = EncodeWebP
+
+// File is an encoded image ready to be stored.
+type File struct {
+	// Variant is empty for the full-size image, otherwise a name such as "w320" or "w320-webp".
+	Variant     string
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// Result is a processed upload: the full-size image and its variants.
+type Result struct {
+	Original File
+	Variants []File
+}
+
+// Process checks an uploaded image and produces the files to store for it.
+func Process(data []byte) (*Result, error) {
+	format := sniff(data)
+	if format == "" {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decodedFormat != format {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxSide || cfg.Height > MaxSide || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var result Result
+	var still image.Image
+	switch format {
+	case "gif":
+		// Every frame is decoded at once, so they are counted and measured beforehand
+		frames, pixels, ok := gifFrames(data)
+		if !ok {
+			return nil, ErrInvalidImage
+		}
+		if frames > maxGIFFrames || pixels > maxGIFPixels {
+			return nil, ErrTooLarge
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		// Re-encoding keeps the frames and loop count and drops comments and application data
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return nil, err
+		}
+		result.Original = File{ContentType: "image/gif", Ext: "gif", Width: cfg.Width, Height: cfg.Height, Data: buf.Bytes()}
+		still = firstFrame(g)
+
+	default:
+		img, err := decode(format, data)
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		if format == "jpeg" {
+			img = orient(img, jpegOrientation(data))
+		}
+		// WebP is stored in whichever format suits its content and served as WebP from its variants
+		if format == "png" || (format == "webp" && !opaque(img)) {
+			format = "png"
+		} else {
+			format = "jpeg"
+		}
+		if result.Original, err = encode(img, format, ""); err != nil {
+			return nil, err
+		}
+		still = img
+	}
+
+	stillFormat := "jpeg"
+	if !opaque(still) {
+		stillFormat = "png"
+	}
+	for _, width := range VariantWidths {
+		if still.Bounds().Dx() <= width {
+			continue
+		}
+		resized := resize(still, width)
+		variant, err := encode(resized, stillFormat, "w"+strconv.Itoa(width))
+		if err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, variant)
+		if webp, ok, err := encodeWebP(resized, variant.Variant+"-webp"); err != nil {
+			return nil, err
+		} else if ok {
+			result.Variants = append(result.Variants, webp)
+		}
+	}
+	if webp, ok, err := encodeWebP(still, "webp"); err != nil {
+		return nil, err
+	} else if ok {
+		result.Variants = append(result.Variants, webp)
+	}
+	return &result, nil
+}
+
+// sniff returns the image format of data judging by its leading bytes, or "" if it is not one we accept.
+func sniff(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	case "image/webp":
+		return "webp"
+	}
+	return ""
+}
+
+func decode(format string, data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch format {
+	case "jpeg":
+		return jpeg.Decode(r)
+	case "png":
+		return png.Decode(r)
+	case "webp":
+		return webp.Decode(r)
+	}
+	return nil, ErrUnsupportedType
+}
+
+func encode(img image.Image, format, variant string) (File, error) {
+	var buf bytes.Buffer
+	file := File{Variant: variant, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	switch format {
+	case "png":
+		file.ContentType, file.Ext = "image/png", "png"
+		if err := png.Encode(&buf, img); err != nil {
+			return File{}, err
+		}
+	default:
+		file.ContentType, file.Ext = "image/jpeg", "jpg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return File{}, err
+		}
+	}
+	file.Data = buf.Bytes()
+	return file, nil
+}
+
+// encodeWebP encodes img with WebPEncoder, reporting false when no encoder is configured.
+func encodeWebP(img image.Image, variant string) (File, bool, error) {
+	if WebPEncoder == nil {
+		return File{}, false, nil
+	}
+	var buf bytes.Buffer
+	if err := WebPEncoder(&buf, img); err != nil {
+		return File{}, false, err
+	}
+	return File{
+		Variant:     variant,
+		ContentType: "image/webp",
+		Ext:         "webp",
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Data:        buf.Bytes(),
+	}, true, nil
+}
+
+// resize scales img down to the given width, keeping its aspect ratio.
+func resize(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// firstFrame draws the first frame of a GIF onto its full canvas.
+func firstFrame(g *gif.GIF) image.Image {
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	if len(g.Image) > 0 {
+		draw.Draw(canvas, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+	}
+	return canvas
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
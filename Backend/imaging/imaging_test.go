@@ -0,0 +1,265 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// withEXIF inserts an APP1 segment after the SOI marker of a JPEG, holding an orientation tag
+// followed by the given bytes, which stand in for other metadata such as a GPS position.
+func withEXIF(t *testing.T, jpg []byte, orientation uint16, extra string) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, binary.LittleEndian, uint32(8))
+	binary.Write(&tiff, binary.LittleEndian, uint16(1))
+	binary.Write(&tiff, binary.LittleEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	binary.Write(&tiff, binary.LittleEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString(extra)
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+// pngHeader returns the start of a PNG of the given size, enough for image.DecodeConfig.
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8-bit RGBA
+
+	var b bytes.Buffer
+	b.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&b, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	b.Write(chunk)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return b.Bytes()
+}
+
+// gifWithFrames lays out a GIF whose frames claim the given size but hold no image data; only
+// the frame layout is read before the limits are checked.
+func gifWithFrames(width, height uint16, frames int) []byte {
+	var b bytes.Buffer
+	b.WriteString("GIF89a")
+	binary.Write(&b, binary.LittleEndian, []uint16{width, height})
+	b.Write([]byte{0x80, 0, 0}) // two-color global table
+	b.Write([]byte{0, 0, 0, 255, 255, 255})
+	for i := 0; i < frames; i++ {
+		b.WriteByte(0x2C)
+		binary.Write(&b, binary.LittleEndian, []uint16{0, 0, width, height})
+		b.Write([]byte{0, 2, 1, 0, 0}) // no local table, LZW size 2, one data byte, terminator
+	}
+	b.WriteByte(0x3B)
+	return b.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestProcessStripsEXIFAndAppliesOrientation(t *testing.T) {
+	// Red on the left, blue on the right; orientation 6 means the camera was turned clockwise
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 20 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, src, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := withEXIF(t, jpg.Bytes(), 6, "GPS 51.5007N 0.1246W")
+
+	result, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	orig := result.Original
+	if orig.ContentType != "image/jpeg" || orig.Width != 20 || orig.Height != 40 {
+		t.Fatalf("got %s %dx%d, want image/jpeg 20x40", orig.ContentType, orig.Width, orig.Height)
+	}
+	if bytes.Contains(orig.Data, []byte("Exif")) || bytes.Contains(orig.Data, []byte("GPS")) {
+		t.Error("stored image still has its EXIF data")
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(orig.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Errorf("top of the upright image should be red, got r=%d b=%d", r>>8, b>>8)
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); b < r {
+		t.Errorf("bottom of the upright image should be blue, got r=%d b=%d", r>>8, b>>8)
+	}
+}
+
+func TestProcessDropsPNGMetadata(t *testing.T) {
+	data := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 8, 8)))
+	// A tEXt chunk right before IEND, which is always the last 12 bytes
+	text := append([]byte("tEXt"), "Comment\x00taken at home"...)
+	var chunk bytes.Buffer
+	binary.Write(&chunk, binary.BigEndian, uint32(len(text)-4))
+	chunk.Write(text)
+	binary.Write(&chunk, binary.BigEndian, crc32.ChecksumIEEE(text))
+	data = append(append(append([]byte{}, data[:len(data)-12]...), chunk.Bytes()...), data[len(data)-12:]...)
+
+	result, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Original.ContentType != "image/png" {
+		t.Errorf("content type = %s, want image/png", result.Original.ContentType)
+	}
+	if bytes.Contains(result.Original.Data, []byte("taken at home")) {
+		t.Error("stored image still has its text chunk")
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	frames := &gif.GIF{}
+	for i := 0; i <= maxGIFFrames; i++ {
+		frames.Image = append(frames.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White}))
+		frames.Delay = append(frames.Delay, 1)
+	}
+	var manyFrames bytes.Buffer
+	if err := gif.EncodeAll(&manyFrames, frames); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("just some text, not an image"), ErrUnsupportedType},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrUnsupportedType},
+		{"side too long", pngHeader(MaxSide+1, 10), ErrTooLarge},
+		{"too many pixels", pngHeader(7000, 7000), ErrTooLarge},
+		{"truncated", pngHeader(100, 100), ErrInvalidImage},
+		{"too many frames", manyFrames.Bytes(), ErrTooLarge},
+		{"frames too large together", gifWithFrames(6000, 6000, 3), ErrTooLarge},
+		{"gif cut short", gifWithFrames(10, 10, 2)[:40], ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Process() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessVariants(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 1200, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 1200; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x / 5), uint8(y / 3), 128, 255})
+		}
+	}
+
+	result, err := Process(encodePNG(t, src))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	want := map[string][2]int{
+		"w320":      {320, 160},
+		"w320-webp": {320, 160},
+		"w960":      {960, 480},
+		"w960-webp": {960, 480},
+		"webp":      {1200, 600},
+	}
+	if len(result.Variants) != len(want) {
+		t.Fatalf("got %d variants, want %d", len(result.Variants), len(want))
+	}
+	for _, v := range result.Variants {
+		size, ok := want[v.Variant]
+		if !ok {
+			t.Errorf("unexpected variant %q", v.Variant)
+			continue
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(v.Data))
+		if err != nil {
+			t.Errorf("variant %s does not decode: %v", v.Variant, err)
+			continue
+		}
+		if cfg.Width != size[0] || cfg.Height != size[1] || v.Width != size[0] || v.Height != size[1] {
+			t.Errorf("variant %s is %dx%d, want %dx%d", v.Variant, cfg.Width, cfg.Height, size[0], size[1])
+		}
+		if wantWebP := v.Variant == "webp" || strings.HasSuffix(v.Variant, "-webp"); wantWebP != (format == "webp") {
+			t.Errorf("variant %s is %s", v.Variant, format)
+		}
+	}
+}
+
+func TestEncodeWebPIsLossless(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		at            func(x, y int) color.NRGBA
+	}{
+		{"single pixel", 1, 1, func(x, y int) color.NRGBA { return color.NRGBA{1, 2, 3, 4} }},
+		{"flat", 64, 48, func(x, y int) color.NRGBA { return color.NRGBA{10, 20, 30, 255} }},
+		{"gradient", 100, 70, func(x, y int) color.NRGBA { return color.NRGBA{uint8(x * 2), uint8(y * 3), uint8(x + y), 255} }},
+		{"noise with alpha", 37, 29, func(x, y int) color.NRGBA {
+			n := uint32(x*7919+y*104729) * 2654435761
+			return color.NRGBA{uint8(n), uint8(n >> 8), uint8(n >> 16), uint8(n >> 24)}
+		}},
+		{"blocks", 300, 200, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x / 40 * 30), uint8(y / 25 * 20), uint8(x % 3), 200}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewNRGBA(image.Rect(0, 0, tt.width, tt.height))
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					src.SetNRGBA(x, y, tt.at(x, y))
+				}
+			}
+
+			var b bytes.Buffer
+			if err := EncodeWebP(&b, src); err != nil {
+				t.Fatalf("EncodeWebP: %v", err)
+			}
+			got, err := webp.Decode(bytes.NewReader(b.Bytes()))
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			nrgba, ok := got.(*image.NRGBA)
+			if !ok || !bytes.Equal(nrgba.Pix, src.Pix) {
+				t.Error("decoded pixels differ from the encoded ones")
+			}
+		})
+	}
+}
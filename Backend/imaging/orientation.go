@@ -0,0 +1,130 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// jpegOrientation reads the EXIF orientation of a JPEG, returning 1 (upright) when it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte before a marker
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Markers without a length
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// EXIF comes before the image data
+			return 1
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation finds the orientation tag in the first IFD of EXIF's TIFF structure.
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(t[4:]))
+	if ifd < 8 || ifd+2 > len(t) {
+		return 1
+	}
+	entries := int(order.Uint16(t[ifd:]))
+	for k := 0; k < entries; k++ {
+		entry := ifd + 2 + 12*k
+		if entry+12 > len(t) {
+			return 1
+		}
+		if order.Uint16(t[entry:]) != 0x0112 {
+			continue
+		}
+		// The orientation is a SHORT, stored at the start of the entry's value field
+		if order.Uint16(t[entry+2:]) != 3 {
+			return 1
+		}
+		if o := int(order.Uint16(t[entry+8:])); o >= 1 && o <= 8 {
+			return o
+		}
+		return 1
+	}
+	return 1
+}
+
+// orient turns img upright according to an EXIF orientation, since the tag is lost on re-encoding.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	// from maps a pixel of the upright image to the stored pixel it shows
+	var from func(x, y int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2: // flip horizontally
+		from = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // rotate 180°
+		from = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // flip vertically
+		from = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // transpose
+		dw, dh = h, w
+		from = func(x, y int) (int, int) { return y, x }
+	case 6: // rotate 90° clockwise
+		dw, dh = h, w
+		from = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // transverse
+		dw, dh = h, w
+		from = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // rotate 90° counter-clockwise
+		dw, dh = h, w
+		from = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := from(x, y)
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):])
+		}
+	}
+	return dst
+}
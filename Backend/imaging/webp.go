@@ -0,0 +1,544 @@
+package imaging
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"math/bits"
+)
+
+// This file holds a lossless WebP (VP8L) encoder. It applies the subtract-green and predictor
+// transforms, copies runs of repeated pixels with LZ77 references and codes the rest with one set
+// of Huffman codes for the whole image. The format is described at
+// https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+const (
+	vp8lMaxSide = 1 << 14
+	// vp8lPredictorBits is the log-2 side of the tiles that each get their own predictor.
+	vp8lPredictorBits = 4
+	// vp8lMinCopy is the shortest run worth an LZ77 reference instead of literals.
+	vp8lMinCopy  = 3
+	vp8lMaxCopy  = 4096
+	vp8lMaxCode  = 15
+	vp8lLiterals = 256
+)
+
+// vp8lPredictors are the predictor modes tried for each tile: left, top, the average of the two,
+// and whichever of left and top the gradient suggests. None of them look at the top-right pixel,
+// which wraps around at the right edge.
+var vp8lPredictors = []byte{1, 2, 7, 11}
+
+// Distance codes for the pixel to the left and the pixel above; see the distance map in the spec.
+const (
+	vp8lDistanceLeft = 2
+	vp8lDistanceUp   = 1
+)
+
+// EncodeWebP writes img to w as a lossless WebP. It is the default WebPEncoder.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSide || height > vp8lMaxSide {
+		return errors.New("webp: image dimensions out of range")
+	}
+
+	// VP8L stores colors without premultiplied alpha
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	pix := nrgba.Pix
+
+	var bw bitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if opaque(nrgba) {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3)
+
+	// The decoder undoes the transforms in the opposite order, predictor first
+	subtractGreen(pix)
+	bw.write(1, 1)
+	bw.write(2, 2)
+
+	modes := choosePredictors(pix, width, height)
+	residuals := predict(pix, width, height, modes)
+	bw.write(1, 1)
+	bw.write(0, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	modeImage := make([]byte, len(modes)*4)
+	for i, mode := range modes {
+		modeImage[i*4+1] = mode
+	}
+	writeEntropyImage(&bw, modeImage, tiles(width), false)
+
+	bw.write(0, 1)
+	writeEntropyImage(&bw, residuals, width, true)
+	data := bw.bytes()
+
+	var out bytes.Buffer
+	padded := len(data) + len(data)&1
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+padded))
+	out.WriteString("WEBPVP8L")
+	binary.Write(&out, binary.LittleEndian, uint32(len(data)))
+	out.Write(data)
+	if len(data)&1 == 1 {
+		out.WriteByte(0)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// subtractGreen stores red and blue as their difference from green, which they tend to follow.
+func subtractGreen(pix []byte) {
+	for p := 0; p < len(pix); p += 4 {
+		pix[p+0] -= pix[p+1]
+		pix[p+2] -= pix[p+1]
+	}
+}
+
+func tiles(size int) int {
+	return (size + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+}
+
+// choosePredictors picks, for each tile, the predictor mode leaving the smallest residuals.
+func choosePredictors(pix []byte, width, height int) []byte {
+	tilesX, tilesY := tiles(width), tiles(height)
+	modes := make([]byte, tilesX*tilesY)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			best, bestCost := vp8lPredictors[0], -1
+			for _, mode := range vp8lPredictors {
+				cost := 0
+				for y := max(ty<<vp8lPredictorBits, 1); y < min((ty+1)<<vp8lPredictorBits, height); y++ {
+					for x := max(tx<<vp8lPredictorBits, 1); x < min((tx+1)<<vp8lPredictorBits, width); x++ {
+						p := (y*width + x) * 4
+						pred := prediction(pix, p, p-width*4, mode)
+						for c := 0; c < 4; c++ {
+							cost += absResidual(pix[p+c] - pred[c])
+						}
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesX+tx] = best
+		}
+	}
+	return modes
+}
+
+// predict returns the residuals of pix against the predictions the decoder will make: opaque black
+// for the first pixel, the left pixel along the first row, the top pixel down the first column and
+// each tile's mode elsewhere.
+func predict(pix []byte, width, height int, modes []byte) []byte {
+	res := make([]byte, len(pix))
+	tilesX := tiles(width)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := (y*width + x) * 4
+			var pred [4]byte
+			switch {
+			case x == 0 && y == 0:
+				pred = [4]byte{0, 0, 0, 0xff}
+			case y == 0:
+				pred = prediction(pix, p, 0, 1)
+			case x == 0:
+				pred = prediction(pix, p, p-width*4, 2)
+			default:
+				pred = prediction(pix, p, p-width*4, modes[(y>>vp8lPredictorBits)*tilesX+x>>vp8lPredictorBits])
+			}
+			for c := 0; c < 4; c++ {
+				res[p+c] = pix[p+c] - pred[c]
+			}
+		}
+	}
+	return res
+}
+
+// prediction computes the predictor mode's guess for the pixel at p, whose top neighbour is at top.
+func prediction(pix []byte, p, top int, mode byte) [4]byte {
+	var pred [4]byte
+	switch mode {
+	case 1:
+		copy(pred[:], pix[p-4:p])
+	case 2:
+		copy(pred[:], pix[top:top+4])
+	case 7:
+		for c := 0; c < 4; c++ {
+			pred[c] = byte((int(pix[p-4+c]) + int(pix[top+c])) / 2)
+		}
+	case 11:
+		// The estimate is L + T - TL; pick the neighbour closest to it
+		l, t := 0, 0
+		for c := 0; c < 4; c++ {
+			l += abs(int(pix[top-4+c]) - int(pix[top+c]))
+			t += abs(int(pix[top-4+c]) - int(pix[p-4+c]))
+		}
+		if l < t {
+			copy(pred[:], pix[p-4:p])
+		} else {
+			copy(pred[:], pix[top:top+4])
+		}
+	}
+	return pred
+}
+
+func absResidual(r byte) int {
+	return abs(int(int8(r)))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// vp8lSymbols feeds the symbols coding an image to emit: literal pixels, given by their offset in
+// pix, and LZ77 copies of the left or upper pixel, given by their length and distance code.
+func vp8lSymbols(pix []byte, width int, emit func(p, length, distance int)) {
+	n := len(pix) / 4
+	for i := 0; i < n; {
+		left, up := 0, 0
+		if i > 0 {
+			left = runLength(pix, i, 1, n)
+		}
+		if i >= width {
+			up = runLength(pix, i, width, n)
+		}
+		switch {
+		case left >= vp8lMinCopy && left >= up:
+			emit(0, left, vp8lDistanceLeft)
+			i += left
+		case up >= vp8lMinCopy:
+			emit(0, up, vp8lDistanceUp)
+			i += up
+		default:
+			emit(i*4, 0, 0)
+			i++
+		}
+	}
+}
+
+// runLength counts how many pixels from i on repeat the pixel dist before them.
+func runLength(pix []byte, i, dist, n int) int {
+	length := 0
+	for i+length < n && length < vp8lMaxCopy {
+		p, q := (i+length)*4, (i+length-dist)*4
+		if pix[p] != pix[q] || pix[p+1] != pix[q+1] || pix[p+2] != pix[q+2] || pix[p+3] != pix[q+3] {
+			break
+		}
+		length++
+	}
+	return length
+}
+
+// prefixCode splits an LZ77 length or distance into its prefix symbol and extra bits.
+func prefixCode(value int) (symbol int, extraBits uint, extra uint32) {
+	if value <= 4 {
+		return value - 1, 0, 0
+	}
+	v := uint32(value - 1)
+	highest := uint(bits.Len32(v) - 1)
+	second := int(v>>(highest-1)) & 1
+	extraBits = highest - 1
+	return 2*int(highest) + second, extraBits, v & (1<<extraBits - 1)
+}
+
+// writeEntropyImage writes pix without a color cache, coded by a single group of Huffman codes.
+// The main image says so explicitly; images holding transform data cannot have more than one.
+func writeEntropyImage(bw *bitWriter, pix []byte, width int, main bool) {
+	bw.write(0, 1)
+	if main {
+		bw.write(0, 1)
+	}
+
+	var green [vp8lLiterals + 24]uint32
+	var red, blue, alpha [vp8lLiterals]uint32
+	var distance [40]uint32
+	vp8lSymbols(pix, width, func(p, length, dist int) {
+		if length == 0 {
+			green[pix[p+1]]++
+			red[pix[p+0]]++
+			blue[pix[p+2]]++
+			alpha[pix[p+3]]++
+			return
+		}
+		symbol, _, _ := prefixCode(length)
+		green[vp8lLiterals+symbol]++
+		symbol, _, _ = prefixCode(dist)
+		distance[symbol]++
+	})
+
+	codes := [5]huffmanCode{
+		newHuffmanCode(green[:], vp8lMaxCode),
+		newHuffmanCode(red[:], vp8lMaxCode),
+		newHuffmanCode(blue[:], vp8lMaxCode),
+		newHuffmanCode(alpha[:], vp8lMaxCode),
+		newHuffmanCode(distance[:], vp8lMaxCode),
+	}
+	for i := range codes {
+		writeHuffmanCode(bw, &codes[i])
+	}
+
+	vp8lSymbols(pix, width, func(p, length, dist int) {
+		if length == 0 {
+			codes[0].write(bw, int(pix[p+1]))
+			codes[1].write(bw, int(pix[p+0]))
+			codes[2].write(bw, int(pix[p+2]))
+			codes[3].write(bw, int(pix[p+3]))
+			return
+		}
+		symbol, n, extra := prefixCode(length)
+		codes[0].write(bw, vp8lLiterals+symbol)
+		bw.write(extra, n)
+		symbol, n, extra = prefixCode(dist)
+		codes[4].write(bw, symbol)
+		bw.write(extra, n)
+	})
+}
+
+// huffmanCode is a canonical Huffman code. A code with a single symbol takes no bits to write.
+type huffmanCode struct {
+	lengths []uint8
+	codes   []uint16
+	used    []int // symbols with a code, in order
+}
+
+func (h *huffmanCode) write(bw *bitWriter, symbol int) {
+	if len(h.used) < 2 {
+		return
+	}
+	// Codes are read a bit at a time from their most significant bit
+	length := uint(h.lengths[symbol])
+	bw.write(uint32(bits.Reverse16(h.codes[symbol])>>(16-length)), length)
+}
+
+// newHuffmanCode builds a code for symbols with the given counts, no longer than maxLength bits.
+// Counts are halved until the code fits, as libwebp does.
+func newHuffmanCode(counts []uint32, maxLength int) huffmanCode {
+	h := huffmanCode{lengths: make([]uint8, len(counts)), codes: make([]uint16, len(counts))}
+	for symbol, count := range counts {
+		if count > 0 {
+			h.used = append(h.used, symbol)
+		}
+	}
+	if len(h.used) < 2 {
+		for _, symbol := range h.used {
+			h.lengths[symbol] = 1
+		}
+		return h
+	}
+
+	weights := make([]uint32, len(counts))
+	copy(weights, counts)
+	for {
+		huffmanLengths(weights, h.used, h.lengths)
+		longest := uint8(0)
+		for _, length := range h.lengths {
+			longest = max(longest, length)
+		}
+		if int(longest) <= maxLength {
+			break
+		}
+		for _, symbol := range h.used {
+			weights[symbol] = (weights[symbol] + 1) / 2
+		}
+	}
+
+	// Canonical codes: shorter codes first, then by symbol
+	var perLength, next [vp8lMaxCode + 1]uint16
+	for _, length := range h.lengths {
+		if length > 0 {
+			perLength[length]++
+		}
+	}
+	code := uint16(0)
+	for length := 1; length < len(next); length++ {
+		code = (code + perLength[length-1]) << 1
+		next[length] = code
+	}
+	for symbol, length := range h.lengths {
+		if length > 0 {
+			h.codes[symbol] = next[length]
+			next[length]++
+		}
+	}
+	return h
+}
+
+type huffmanNode struct {
+	weight  uint64
+	symbol  int // -1 for inner nodes
+	left    *huffmanNode
+	right   *huffmanNode
+	ordinal int
+}
+
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].weight != h[j].weight {
+		return h[i].weight < h[j].weight
+	}
+	return h[i].ordinal < h[j].ordinal
+}
+func (h huffmanHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x any)   { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// huffmanLengths sets the optimal code length of each used symbol.
+func huffmanLengths(weights []uint32, used []int, lengths []uint8) {
+	nodes := make(huffmanHeap, 0, len(used))
+	for i, symbol := range used {
+		nodes = append(nodes, &huffmanNode{weight: uint64(weights[symbol]), symbol: symbol, ordinal: i})
+	}
+	heap.Init(&nodes)
+	ordinal := len(used)
+	for nodes.Len() > 1 {
+		a := heap.Pop(&nodes).(*huffmanNode)
+		b := heap.Pop(&nodes).(*huffmanNode)
+		heap.Push(&nodes, &huffmanNode{weight: a.weight + b.weight, symbol: -1, left: a, right: b, ordinal: ordinal})
+		ordinal++
+	}
+
+	var walk func(n *huffmanNode, depth uint8)
+	walk = func(n *huffmanNode, depth uint8) {
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(nodes[0], 0)
+}
+
+// codeLengthOrder is the order code length code lengths are written in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writeHuffmanCode writes the code lengths of h, using the short form for one or two symbols
+// below 256 and run-length coded lengths otherwise.
+func writeHuffmanCode(bw *bitWriter, h *huffmanCode) {
+	if len(h.used) <= 2 && (len(h.used) == 0 || h.used[len(h.used)-1] < vp8lLiterals) {
+		bw.write(1, 1)
+		symbols := append([]int{}, h.used...)
+		if len(symbols) == 0 {
+			symbols = []int{0}
+		}
+		bw.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbols[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbols[0]), 8)
+		}
+		if len(symbols) == 2 {
+			bw.write(uint32(symbols[1]), 8)
+		}
+		return
+	}
+
+	type token struct {
+		symbol    int
+		extra     uint32
+		extraBits uint
+	}
+	var tokens []token
+	lengths := h.lengths
+	for i := 0; i < len(lengths); {
+		value := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == value {
+			run++
+		}
+		i += run
+		if value == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, token{18, uint32(n - 11), 7})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, token{17, uint32(run - 3), 3})
+				run = 0
+			}
+		} else {
+			tokens = append(tokens, token{int(value), 0, 0})
+			run--
+			for run >= 3 {
+				n := min(run, 6)
+				tokens = append(tokens, token{16, uint32(n - 3), 2})
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, token{int(value), 0, 0})
+		}
+	}
+
+	var counts [19]uint32
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+	lengthCode := newHuffmanCode(counts[:], 7)
+	written := 4
+	for i, symbol := range codeLengthOrder {
+		if lengthCode.lengths[symbol] > 0 {
+			written = max(written, i+1)
+		}
+	}
+
+	bw.write(0, 1)
+	bw.write(uint32(written-4), 4)
+	for _, symbol := range codeLengthOrder[:written] {
+		bw.write(uint32(lengthCode.lengths[symbol]), 3)
+	}
+	// All lengths are written rather than stopping early
+	bw.write(0, 1)
+	for _, t := range tokens {
+		lengthCode.write(bw, t.symbol)
+		bw.write(t.extra, t.extraBits)
+	}
+}
+
+// bitWriter packs bits least significant first, as VP8L reads them.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+func (bw *bitWriter) write(value uint32, n uint) {
+	bw.acc |= uint64(value) << bw.nBits
+	bw.nBits += n
+	for bw.nBits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nBits -= 8
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.nBits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nBits = 0, 0
+	}
+	return bw.buf
+}
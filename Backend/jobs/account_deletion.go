@@ -0,0 +1,84 @@
+// Package jobs holds background workers that run alongside the HTTP and gRPC servers.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const (
+	// deletionLease is how long a worker owns a deletion before another instance may take it over.
+	deletionLease = 10 * time.Minute
+	// deletionRetryDelay is how long a failed purge waits before it is attempted again.
+	deletionRetryDelay = 5 * time.Minute
+)
+
+// RunAccountDeletions purges accounts whose deletion grace period has ended, checking every interval
+// until ctx is cancelled. Several instances may run it at once; leases keep them off the same account.
+func RunAccountDeletions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		processDueDeletions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func processDueDeletions(ctx context.Context) {
+	for ctx.Err() == nil {
+		deletion, err := db.ClaimDueAccountDeletion(ctx, deletionLease)
+		if err != nil {
+			log.Printf("❌ Failed to claim account deletion: %v", err)
+			return
+		}
+		if deletion == nil {
+			return
+		}
+
+		if err := purgeAccount(ctx, deletion); err != nil {
+			log.Printf("❌ Account deletion for %s stopped: %v", deletion.UserID, err)
+			if err := db.FailAccountDeletion(ctx, deletion.UserID, err, deletionRetryDelay); err != nil {
+				log.Printf("❌ Failed to record account deletion error: %v", err)
+			}
+			continue
+		}
+		log.Printf("🗑️ Account %s deleted", deletion.UserID)
+	}
+}
+
+// purgeAccount runs every step not yet recorded as done, so an interrupted purge resumes where it stopped.
+func purgeAccount(ctx context.Context, deletion *models.AccountDeletion) error {
+	done := make(map[string]bool, len(deletion.CompletedSteps))
+	for _, step := range deletion.CompletedSteps {
+		done[step] = true
+	}
+
+	for _, step := range db.AccountDeletionSteps {
+		if done[step] {
+			continue
+		}
+		// Export archives are on this server's disk, out of reach of the db package
+		if step == "exports" {
+			if err := removeUserArchives(ctx, deletion.UserID); err != nil {
+				return err
+			}
+		}
+		if err := db.PurgeAccountStep(ctx, deletion, step); err != nil {
+			return err
+		}
+		if err := db.CompleteAccountDeletionStep(ctx, deletion.UserID, step, deletionLease); err != nil {
+			return err
+		}
+	}
+	return db.FinishAccountDeletion(ctx, deletion.UserID)
+}
@@ -0,0 +1,361 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+const (
+	// DataExportTTL is how long a finished archive stays available for download.
+	DataExportTTL = 7 * 24 * time.Hour
+	// DataExportTimeout bounds how long building a single archive may take.
+	DataExportTimeout = 30 * time.Minute
+)
+
+// ExportDir is where export archives are written, taken from EXPORT_DIR or a directory under the
+// system temp dir. Archives are served by the instance that built them.
+func ExportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "insight-hub-exports")
+}
+
+// BuildDataExport writes the user's personal data archive for export and records the outcome.
+// It is meant to run in its own goroutine.
+func BuildDataExport(export *models.DataExport) {
+	ctx, cancel := context.WithTimeout(context.Background(), DataExportTimeout)
+	defer cancel()
+
+	if err := db.UpdateDataExport(ctx, export.ID, []firestore.Update{
+		{Path: "status", Value: models.DataExportRunning},
+	}); err != nil {
+		log.Printf("❌ Failed to start data export %s: %v", export.ID, err)
+		return
+	}
+
+	fileName := export.ID + ".zip"
+	size, err := writeArchive(ctx, export.UserID, filepath.Join(ExportDir(), fileName))
+	if err != nil {
+		log.Printf("❌ Data export %s failed: %v", export.ID, err)
+		_ = db.UpdateDataExport(ctx, export.ID, []firestore.Update{
+			{Path: "status", Value: models.DataExportFailed},
+			{Path: "last_error", Value: err.Error()},
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := db.UpdateDataExport(ctx, export.ID, []firestore.Update{
+		{Path: "status", Value: models.DataExportReady},
+		{Path: "file_name", Value: fileName},
+		{Path: "size_bytes", Value: size},
+		{Path: "completed_at", Value: now},
+		{Path: "expires_at", Value: now.Add(DataExportTTL)},
+	}); err != nil {
+		log.Printf("❌ Failed to record data export %s: %v", export.ID, err)
+		return
+	}
+
+	removeOlderArchives(ctx, export)
+}
+
+// RunDataExportCleanup deletes export archives once they have expired, checking every interval
+// until ctx is cancelled. Each instance cleans up the archives on its own disk.
+func RunDataExportCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removeExpiredArchives(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeExpiredArchives deletes every archive in ExportDir that is past its expiry or whose export
+// record is gone, along with temporary files left behind by builds that never finished.
+func removeExpiredArchives(ctx context.Context) {
+	entries, err := os.ReadDir(ExportDir())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to list data export archives: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(ExportDir(), name)
+
+		if strings.HasSuffix(name, ".tmp") {
+			if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > DataExportTimeout {
+				_ = os.Remove(path)
+			}
+			continue
+		}
+		id, ok := strings.CutSuffix(name, ".zip")
+		if !ok {
+			continue
+		}
+
+		export, err := db.GetDataExport(ctx, id)
+		if err != nil && !errors.Is(err, db.ErrDataExportNotFound) {
+			log.Printf("❌ Failed to look up data export %s: %v", id, err)
+			return
+		}
+		if export != nil {
+			// An archive is renamed into place just before its export is marked ready
+			if export.Status == models.DataExportPending || export.Status == models.DataExportRunning {
+				continue
+			}
+			if export.FileName == name && now.Before(export.ExpiresAt) {
+				continue
+			}
+		}
+
+		if err := os.Remove(path); err != nil {
+			log.Printf("⚠️ Failed to delete data export archive %s: %v", name, err)
+			continue
+		}
+		if export != nil && export.FileName != "" {
+			if err := db.UpdateDataExport(ctx, id, []firestore.Update{
+				{Path: "file_name", Value: firestore.Delete},
+			}); err != nil {
+				log.Printf("⚠️ Failed to record removal of data export %s: %v", id, err)
+			}
+		}
+		log.Printf("🧹 Deleted expired data export %s", id)
+	}
+}
+
+// removeOlderArchives deletes the archives of a user's earlier exports once a newer one is ready.
+func removeOlderArchives(ctx context.Context, latest *models.DataExport) {
+	exports, err := db.GetDataExports(ctx, latest.UserID)
+	if err != nil {
+		return
+	}
+	for _, e := range exports {
+		if e.ID == latest.ID || e.FileName == "" {
+			continue
+		}
+		_ = os.Remove(filepath.Join(ExportDir(), e.FileName))
+		_ = db.UpdateDataExport(ctx, e.ID, []firestore.Update{
+			{Path: "file_name", Value: firestore.Delete},
+			{Path: "expires_at", Value: time.Now()},
+		})
+	}
+}
+
+// removeUserArchives deletes the archives of all of a user's exports.
+func removeUserArchives(ctx context.Context, userID string) error {
+	exports, err := db.GetDataExports(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, e := range exports {
+		if e.FileName == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(ExportDir(), e.FileName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArchive builds the ZIP next to path and renames it into place, so a half-written archive
+// is never served. It returns the archive size.
+func writeArchive(ctx context.Context, userID, path string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "export-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := zip.NewWriter(tmp)
+	if err := writeUserData(ctx, zw, userID); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func writeUserData(ctx context.Context, zw *zip.Writer, userID string) error {
+	user, err := db.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	user.ID = userID
+	_, identities, err := db.GetLoginMethods(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("login methods: %w", err)
+	}
+	if err := writeJSON(zw, "profile.json", object{"profile": user, "linked_identities": identities}); err != nil {
+		return err
+	}
+
+	blogs, err := db.GetBlogsByAuthor(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("blogs: %w", err)
+	}
+	if err := writeJSON(zw, "blogs/blogs.json", blogs); err != nil {
+		return err
+	}
+	for _, b := range blogs {
+		w, err := zw.Create("blogs/markdown/" + markdownFileName(b))
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, blogMarkdown(b)); err != nil {
+			return err
+		}
+	}
+
+	comments, err := db.GetCommentsByAuthor(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("comments: %w", err)
+	}
+	if err := writeJSON(zw, "comments.json", comments); err != nil {
+		return err
+	}
+
+	liked, err := db.GetBlogsLikedBy(ctx, user.Username)
+	if err != nil {
+		return fmt.Errorf("liked blogs: %w", err)
+	}
+	likedSummaries := make([]object, 0, len(liked))
+	for _, b := range liked {
+		likedSummaries = append(likedSummaries, object{"id": b.ID, "title": b.Title, "author_id": b.AuthorID, "created_at": b.CreatedAt})
+	}
+	if err := writeJSON(zw, "liked_blogs.json", likedSummaries); err != nil {
+		return err
+	}
+
+	network, err := db.GetNetwork(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("network: %w", err)
+	}
+	if err := writeJSON(zw, "network.json", network); err != nil {
+		return err
+	}
+
+	notifications, err := db.GetNotifications(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+	if err := writeJSON(zw, "notifications.json", notifications); err != nil {
+		return err
+	}
+
+	return writeMessages(ctx, zw, userID)
+}
+
+// object is a small map alias for the ad-hoc JSON objects in the archive.
+type object = map[string]interface{}
+
+// writeMessages streams the chat history as a JSON array, one message at a time.
+func writeMessages(ctx context.Context, zw *zip.Writer, userID string) error {
+	w, err := zw.Create("chat/messages.json")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return err
+	}
+
+	first := true
+	err = db.ForEachMessage(ctx, userID, func(msg models.Message) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ",\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("messages: %w", err)
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+func writeJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func blogMarkdown(b models.Blog) string {
+	var sb strings.Builder
+	sb.WriteString("# " + b.Title + "\n\n")
+	sb.WriteString("- Published: " + b.CreatedAt.Format(time.RFC3339) + "\n")
+	if b.Category != "" {
+		sb.WriteString("- Category: " + b.Category + "\n")
+	}
+	if len(b.Tags) > 0 {
+		sb.WriteString("- Tags: " + strings.Join(b.Tags, ", ") + "\n")
+	}
+	if b.BlogImage != "" {
+		sb.WriteString("\n![cover](" + b.BlogImage + ")\n")
+	}
+	sb.WriteString("\n" + b.BlogContent + "\n")
+	return sb.String()
+}
+
+// markdownFileName builds a readable, unique file name from the blog title and ID.
+func markdownFileName(b models.Blog) string {
+	if slug := utils.Slugify(b.Title, 60); slug != "" {
+		return slug + "-" + b.ID + ".md"
+	}
+	return b.ID + ".md"
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prachin77/insight-hub/db"
+)
+
+// publishBatchSize is how many due blogs are published per query.
+const publishBatchSize = 50
+
+// RunScheduledPublishing publishes scheduled blogs once their time has come, checking every interval
+// until ctx is cancelled, and notifies the authors' followers only after a blog is published.
+func RunScheduledPublishing(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		publishDueBlogs(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func publishDueBlogs(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := db.PublishDueBlogs(ctx, time.Now(), publishBatchSize)
+		for i := range published {
+			blog := &published[i]
+			log.Printf("📰 Scheduled blog %s published", blog.ID)
+
+			author, aerr := db.GetUserByID(ctx, blog.AuthorID)
+			if aerr != nil {
+				log.Printf("❌ Failed to load author of blog %s: %v", blog.ID, aerr)
+				continue
+			}
+			if nerr := db.NotifyFollowersOfBlog(ctx, blog, author.Username); nerr != nil {
+				log.Printf("❌ Failed to notify followers of blog %s: %v", blog.ID, nerr)
+			}
+		}
+		if err != nil {
+			log.Printf("❌ Failed to publish scheduled blogs: %v", err)
+			return
+		}
+		if len(published) < publishBatchSize {
+			return
+		}
+	}
+}
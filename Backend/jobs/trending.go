@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+)
+
+const (
+	// TrendingWindow is how far back engagement counts towards a blog's trending score. Older
+	// hourly buckets are deleted.
+	TrendingWindow = 72 * time.Hour
+	// TrendingPerCategory is how many blogs of each category are marked as trending.
+	TrendingPerCategory = 5
+
+	// Weights of each kind of engagement: a comment says more about a blog than a view.
+	viewWeight    = 1.0
+	likeWeight    = 4.0
+	commentWeight = 6.0
+
+	// trendingGravity is how quickly engagement loses weight as it ages, as in Hacker News'
+	// points / (age + 2)^gravity.
+	trendingGravity = 1.5
+	// minTrendingScore keeps quiet categories from showing blogs that barely had any attention:
+	// one fresh view scores 1/2.5^1.5, about 0.25, so it takes one fresh like or four fresh views.
+	minTrendingScore = 1.0
+
+	// trendingFetchSize is how many blogs are read per batched lookup.
+	trendingFetchSize = 300
+)
+
+// RunTrending recomputes which blogs are trending every interval until ctx is cancelled.
+func RunTrending(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		updateTrending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func updateTrending(ctx context.Context) {
+	now := time.Now()
+	since := now.Add(-TrendingWindow)
+
+	buckets, err := db.GetEngagementSince(ctx, since)
+	if err != nil {
+		log.Printf("❌ Failed to load blog engagement: %v", err)
+		return
+	}
+	scores := trendingScores(buckets, now)
+
+	ids := make([]string, 0, len(scores))
+	for id, score := range scores {
+		if score >= minTrendingScore {
+			ids = append(ids, id)
+		}
+	}
+
+	// Rank the candidates within their category; blogs that were deleted, are not published or
+	// whose authors are banned are left out by the lookup
+	byCategory := make(map[string][]string)
+	for start := 0; start < len(ids); start += trendingFetchSize {
+		end := start + trendingFetchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		blogs, err := db.GetBlogsByIDs(ctx, ids[start:end])
+		if err != nil {
+			log.Printf("❌ Failed to load trending candidates: %v", err)
+			return
+		}
+		for _, b := range blogs {
+			byCategory[b.Category] = append(byCategory[b.Category], b.ID)
+		}
+	}
+
+	trending := make(map[string]float64)
+	for _, blogIDs := range byCategory {
+		sort.Slice(blogIDs, func(i, j int) bool {
+			if scores[blogIDs[i]] != scores[blogIDs[j]] {
+				return scores[blogIDs[i]] > scores[blogIDs[j]]
+			}
+			return blogIDs[i] < blogIDs[j]
+		})
+		if len(blogIDs) > TrendingPerCategory {
+			blogIDs = blogIDs[:TrendingPerCategory]
+		}
+		for _, id := range blogIDs {
+			trending[id] = scores[id]
+		}
+	}
+
+	if err := db.SetTrending(ctx, trending); err != nil {
+		log.Printf("❌ Failed to update trending blogs: %v", err)
+		return
+	}
+	log.Printf("📈 %d blogs marked as trending", len(trending))
+
+	if err := db.DeleteEngagementBefore(ctx, since.Add(-time.Hour)); err != nil {
+		log.Printf("⚠️ Failed to delete old blog engagement: %v", err)
+	}
+}
+
+// trendingScores sums each blog's hourly engagement, every hour weighted down by its age so that
+// a burst of attention today outranks a larger one three days ago.
+func trendingScores(buckets []models.EngagementBucket, now time.Time) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, b := range buckets {
+		points := viewWeight*float64(b.Views) + likeWeight*float64(b.Likes) + commentWeight*float64(b.Comments)
+		// Age is measured from the middle of the hour the bucket covers
+		age := now.Sub(b.Hour.Add(30 * time.Minute)).Hours()
+		if age < 0 {
+			age = 0
+		}
+		scores[b.BlogID] += points / math.Pow(age+2, trendingGravity)
+	}
+	return scores
+}
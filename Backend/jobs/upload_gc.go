@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/storage"
+)
+
+const (
+	// UploadGracePeriod is how long an upload may go unreferenced before it is deleted, leaving
+	// authors time to save the blog they uploaded it for.
+	UploadGracePeriod = 24 * time.Hour
+	// uploadGCBatchSize is how many stale uploads are checked per query.
+	uploadGCBatchSize = 100
+)
+
+// RunUploadGC deletes uploaded images that no blog, blog revision or avatar refers to, checking
+// every interval until ctx is cancelled.
+func RunUploadGC(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		collectUploads(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func collectUploads(ctx context.Context) {
+	for ctx.Err() == nil {
+		uploads, err := db.GetStaleUploads(ctx, time.Now().Add(-UploadGracePeriod), uploadGCBatchSize)
+		if err != nil {
+			log.Printf("❌ Failed to list stale uploads: %v", err)
+			return
+		}
+
+		for _, upload := range uploads {
+			referenced, err := db.UploadReferenced(ctx, upload.ID)
+			if err != nil {
+				log.Printf("❌ Failed to check references to upload %s: %v", upload.ID, err)
+				return
+			}
+			// Uploads in use are checked again after another grace period; touching them also
+			// moves them out of the way of the next query
+			if referenced {
+				if err := db.TouchUpload(ctx, upload.ID); err != nil {
+					log.Printf("❌ Failed to mark upload %s as used: %v", upload.ID, err)
+					return
+				}
+				continue
+			}
+
+			// The files go first and the record last, so a record never outlives its files
+			// unnoticed; while the claim lasts the upload cannot be used or uploaded again
+			deadline, claimed, err := db.MarkUploadDeleting(ctx, upload.ID, upload.LastUsedAt)
+			if err != nil {
+				log.Printf("❌ Failed to claim upload %s for deletion: %v", upload.ID, err)
+				return
+			}
+			if !claimed {
+				continue
+			}
+			if !deleteUploadFiles(ctx, upload, deadline) {
+				continue
+			}
+			if err := db.DeleteUploadRecord(ctx, upload.ID, deadline); err != nil {
+				log.Printf("❌ Failed to delete upload %s: %v", upload.ID, err)
+				return
+			}
+			log.Printf("🧹 Deleted unreferenced upload %s", upload.ID)
+		}
+
+		if len(uploads) < uploadGCBatchSize {
+			return
+		}
+	}
+}
+
+// deleteUploadFiles deletes the files of an upload claimed until deadline, reporting whether all
+// are gone. Uploads left with files are tried again once the claim has lapsed.
+func deleteUploadFiles(ctx context.Context, upload models.Upload, deadline time.Time) bool {
+	for _, key := range upload.Keys {
+		if !time.Now().Before(deadline) {
+			log.Printf("⚠️ Ran out of time deleting the files of upload %s", upload.ID)
+			return false
+		}
+		if err := storage.Default.Delete(ctx, key); err != nil {
+			log.Printf("⚠️ Failed to delete file %s of upload %s: %v", key, upload.ID, err)
+			return false
+		}
+	}
+	return true
+}
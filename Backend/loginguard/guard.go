@@ -0,0 +1,178 @@
+package loginguard
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Record is the failure history tracked for one key (an account or a client IP).
+type Record struct {
+	Failures     int       `firestore:"failures"`
+	LastFailure  time.Time `firestore:"last_failure"`
+	BlockedUntil time.Time `firestore:"blocked_until"`
+	LockedUntil  time.Time `firestore:"locked_until"`
+}
+
+// Store persists failure records. Update must apply fn atomically with respect to other
+// updates of the same key, so concurrent failed logins are all counted.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	Update(ctx context.Context, key string, fn func(*Record)) (Record, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Policy describes how failures for one kind of key are punished.
+type Policy struct {
+	FreeAttempts     int           // failures allowed before any delay kicks in
+	BaseDelay        time.Duration // delay after the first counted failure, doubled for each further one
+	MaxDelay         time.Duration
+	LockoutThreshold int // failures that trigger a temporary lockout
+	LockoutDuration  time.Duration
+	Window           time.Duration // failures older than this are forgotten
+}
+
+// Guard throttles login attempts per account and per client IP.
+type Guard struct {
+	Store         Store
+	AccountPolicy Policy
+	IPPolicy      Policy
+}
+
+// Default is the guard used by the handlers; main replaces its store when configured for Firestore.
+var Default = New(NewMemoryStore())
+
+// New returns a guard with the default policies: exponential backoff from the 3rd failure and a
+// 15 minute lockout after 10 failures for an account, and looser limits for a shared IP.
+func New(store Store) *Guard {
+	return &Guard{
+		Store: store,
+		AccountPolicy: Policy{
+			FreeAttempts:     3,
+			BaseDelay:        time.Second,
+			MaxDelay:         5 * time.Minute,
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+			Window:           time.Hour,
+		},
+		IPPolicy: Policy{
+			FreeAttempts:     10,
+			BaseDelay:        time.Second,
+			MaxDelay:         5 * time.Minute,
+			LockoutThreshold: 50,
+			LockoutDuration:  30 * time.Minute,
+			Window:           time.Hour,
+		},
+	}
+}
+
+// Outcome of recording a failed attempt.
+type Outcome struct {
+	RetryAfter    time.Duration
+	AccountLocked bool      // the account crossed the lockout threshold with this failure
+	LockedUntil   time.Time // when the account lockout ends, if AccountLocked
+}
+
+// Check returns how long the caller must wait before trying again, or zero if the attempt may proceed.
+func (g *Guard) Check(ctx context.Context, account, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range g.keys(account, ip) {
+		rec, err := g.Store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if d := rec.waitAt(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt for both the account and the IP.
+func (g *Guard) Fail(ctx context.Context, account, ip string) (Outcome, error) {
+	now := time.Now()
+	var out Outcome
+	for _, key := range g.keys(account, ip) {
+		policy := g.policyFor(key)
+		wasLocked := false
+		rec, err := g.Store.Update(ctx, key, func(r *Record) {
+			if now.Sub(r.LastFailure) > policy.Window {
+				*r = Record{}
+			}
+			wasLocked = now.Before(r.LockedUntil)
+			policy.apply(r, now)
+		})
+		if err != nil {
+			return out, err
+		}
+		if d := rec.waitAt(now); d > out.RetryAfter {
+			out.RetryAfter = d
+		}
+		if strings.HasPrefix(key, accountPrefix) && !wasLocked && now.Before(rec.LockedUntil) {
+			out.AccountLocked = true
+			out.LockedUntil = rec.LockedUntil
+		}
+	}
+	return out, nil
+}
+
+// Succeed clears the account's failure counter once a session has been issued for it. The IP
+// counter is left alone: signing in to one account must not wipe the failures a client has
+// racked up against others.
+func (g *Guard) Succeed(ctx context.Context, account string) error {
+	for _, key := range g.keys(account, "") {
+		if err := g.Store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	accountPrefix = "account:"
+	ipPrefix      = "ip:"
+)
+
+func (g *Guard) keys(account, ip string) []string {
+	var keys []string
+	if account != "" {
+		keys = append(keys, accountPrefix+strings.ToLower(strings.TrimSpace(account)))
+	}
+	if ip != "" {
+		keys = append(keys, ipPrefix+ip)
+	}
+	return keys
+}
+
+func (g *Guard) policyFor(key string) Policy {
+	if strings.HasPrefix(key, ipPrefix) {
+		return g.IPPolicy
+	}
+	return g.AccountPolicy
+}
+
+func (p Policy) apply(r *Record, now time.Time) {
+	r.Failures++
+	r.LastFailure = now
+
+	if r.Failures >= p.LockoutThreshold {
+		r.LockedUntil = now.Add(p.LockoutDuration)
+		r.BlockedUntil = r.LockedUntil
+		return
+	}
+	if r.Failures > p.FreeAttempts {
+		delay := p.BaseDelay << (r.Failures - p.FreeAttempts - 1)
+		if delay <= 0 || delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+		r.BlockedUntil = now.Add(delay)
+	}
+}
+
+func (r Record) waitAt(now time.Time) time.Duration {
+	if now.Before(r.BlockedUntil) {
+		return r.BlockedUntil.Sub(now)
+	}
+	return 0
+}
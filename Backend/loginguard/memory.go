@@ -0,0 +1,73 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often MemoryStore looks for records it can forget.
+const memorySweepInterval = time.Minute
+
+// MemoryStore keeps failure records in process memory. It is the default and suits a single
+// instance; records are lost on restart.
+type MemoryStore struct {
+	// Retention is how long after its last failure a record is kept once it no longer blocks
+	// anyone. It should be at least the longest policy Window, after which Fail starts over anyway.
+	Retention time.Duration
+
+	mu        sync.Mutex
+	records   map[string]Record
+	nextSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{Retention: time.Hour, records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[key]
+	if ok && s.expired(rec, time.Now()) {
+		delete(s.records, key)
+		return Record{}, nil
+	}
+	return rec, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(*Record)) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(time.Now())
+	rec := s.records[key]
+	fn(&rec)
+	s.records[key] = rec
+	return rec, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweep drops expired records, at most once per memorySweepInterval, so failures for sprayed
+// emails and IPs do not pile up. The caller holds s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(memorySweepInterval)
+	for key, rec := range s.records {
+		if s.expired(rec, now) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// expired reports whether rec's failures are past retention and it no longer blocks anyone.
+func (s *MemoryStore) expired(rec Record, now time.Time) bool {
+	return now.Sub(rec.LastFailure) > s.Retention && !now.Before(rec.BlockedUntil) && !now.Before(rec.LockedUntil)
+}
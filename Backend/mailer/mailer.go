@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer used by the handlers; main replaces it with the one configured in the environment.
+var Default Mailer = &LogMailer{}
+
+// FromEnv builds the mailer selected by MAILER, "smtp" or "log". There is no default: the log
+// mailer writes out password reset and verification links, so it must be chosen deliberately.
+func FromEnv() (Mailer, error) {
+	switch os.Getenv("MAILER") {
+	case "":
+		return nil, fmt.Errorf(`MAILER must be set to "smtp", or to "log" for local development`)
+	case "log":
+		return &LogMailer{Dir: os.Getenv("MAIL_DIR")}, nil
+	case "smtp":
+		m := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if m.Host == "" || m.From == "" {
+			return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM must be set when MAILER=smtp")
+		}
+		if m.Port == "" {
+			m.Port = "587"
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", os.Getenv("MAILER"))
+	}
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prachin77/insight-hub/chat/Chat_Backend"
 	"github.com/prachin77/insight-hub/chat/Chat_Handlers"
 	"github.com/prachin77/insight-hub/db"
 	"github.com/prachin77/insight-hub/handlers"
+	"github.com/prachin77/insight-hub/jobs"
+	"github.com/prachin77/insight-hub/loginguard"
+	"github.com/prachin77/insight-hub/mailer"
 	"github.com/prachin77/insight-hub/middleware"
 	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/storage"
 	"github.com/prachin77/insight-hub/utils"
 )
 
@@ -21,6 +28,21 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ Failed to load configuration: %v", err)
 	}
+	utils.Session = config.Session
+
+	// Configure outgoing mail (MAILER=smtp, or MAILER=log to write it to stdout or MAIL_DIR)
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to configure mailer: %v", err)
+	}
+	mailer.Default = mail
+
+	// Configure where uploaded images are kept (a local directory unless BLOB_STORE=s3)
+	blobs, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to configure blob storage: %v", err)
+	}
+	storage.Default = blobs
 
 	// Initialize Firestore database
 	if err := db.Init(); err != nil {
@@ -28,6 +50,42 @@ func main() {
 	}
 	defer db.Close()
 
+	// Login throttling keeps its counters in memory unless they must be shared between instances
+	if os.Getenv("LOGIN_GUARD_STORE") == "firestore" {
+		loginguard.Default = loginguard.New(db.LoginAttemptStore{})
+	}
+
+	// Mark blogs from before drafts existed as published, then load them into the in-process search index
+	go func() {
+		if count, err := db.BackfillBlogStatus(context.Background()); err != nil {
+			log.Printf("⚠️ Failed to backfill blog status: %v", err)
+		} else if count > 0 {
+			log.Printf("📰 Marked %d existing blogs as published", count)
+		}
+
+		count, err := db.RebuildSearchIndex(context.Background())
+		if err != nil {
+			log.Printf("⚠️ Failed to build the search index: %v", err)
+			return
+		}
+		log.Printf("🔎 Search index built with %d blogs", count)
+	}()
+
+	// Publish scheduled blogs when their time comes
+	go jobs.RunScheduledPublishing(context.Background(), time.Minute)
+
+	// Purge accounts whose deletion grace period has ended
+	go jobs.RunAccountDeletions(context.Background(), time.Minute)
+
+	// Mark the blogs with the most recent engagement in each category as trending
+	go jobs.RunTrending(context.Background(), 15*time.Minute)
+
+	// Delete uploaded images nothing refers to any more
+	go jobs.RunUploadGC(context.Background(), time.Hour)
+
+	// Delete data export archives once they can no longer be downloaded
+	go jobs.RunDataExportCleanup(context.Background(), time.Hour)
+
 	// Start gRPC Messaging Server in background
 	grpcPort := 50051
 	go chat_backend.StartServer(grpcPort)
@@ -54,38 +112,117 @@ func main() {
 	// Auth routes
 	r.POST("/register", handlers.Register)
 	r.POST("/login", handlers.Login)
+	r.POST("/login/2fa", handlers.CompleteTwoFactorLogin)
 	r.POST("/auth/google", handlers.GoogleAuth)
+	r.GET("/auth/username-available", handlers.CheckUsername)
 	r.POST("/logout", handlers.Logout)
+	r.POST("/auth/refresh", handlers.RefreshSession)
+	r.GET("/auth/verify", handlers.VerifyEmail)
+	r.POST("/auth/password/forgot", handlers.ForgotPassword)
+	r.GET("/auth/password/reset", handlers.ResetPasswordForm)
+	r.POST("/auth/password/reset", handlers.ResetPassword)
 	r.GET("/user/:username", handlers.GetUser)
 	r.GET("/user/id/:id", handlers.GetUserByIDHandler)
-	r.POST("/blogs", handlers.CreateBlog)
-	r.PUT("/blogs/update", handlers.UpdateBlog)
-	r.DELETE("/blogs/delete", handlers.DeleteBlog)
 	r.GET("/blogs", handlers.GetBlogs)
-	r.POST("/blogs/increment-views", handlers.IncrementViews)
-	r.POST("/blogs/toggle-like", handlers.ToggleLike)
-	r.POST("/comments", handlers.AddComment)
+	r.GET("/blogs/trending", handlers.GetTrendingBlogs)
+	r.GET("/blogs/:id", middleware.OptionalAuth(), handlers.GetBlog)
+	r.POST("/blogs/increment-views", middleware.OptionalAuth(), handlers.IncrementViews)
+	r.GET("/search/blogs", handlers.SearchBlogs)
+	r.POST("/blogs/:id/views", middleware.OptionalAuth(), handlers.IncrementBlogViews)
 	r.GET("/comments", handlers.GetComments)
-
-	// Follow and Notification routes
-	r.POST("/follow/toggle", handlers.ToggleFollow)
+	r.GET("/uploads/:key", handlers.ServeUpload)
 	r.GET("/follow/check", handlers.CheckFollow)
 	r.GET("/follow/network", handlers.GetUserNetwork)
-	r.GET("/notifications", handlers.GetNotifications)
-	r.POST("/notifications/:id/read", handlers.MarkNotificationRead)
-	r.POST("/notifications/read-all", handlers.MarkAllNotificationsRead)
-	r.GET("/notifications/unread-count", handlers.GetUnreadCount)
+
+	// Routes below act on behalf of the caller, whose identity comes from the verified token.
+	// Account management is limited to browser/app sessions.
+	authed := r.Group("/")
+	authed.Use(middleware.RequireAuth())
+	{
+		authed.POST("/logout-all", handlers.LogoutAll)
+		authed.POST("/auth/verify/resend", handlers.ResendVerification)
+		authed.PUT("/user/me", handlers.UpdateProfile)
+		authed.POST("/user/me/avatar", handlers.UploadAvatar)
+		authed.DELETE("/user/me", handlers.DeleteAccount)
+		authed.PUT("/user/me/password", handlers.ChangePassword)
+		authed.GET("/user/me/deletion", handlers.GetAccountDeletion)
+		authed.POST("/user/me/deletion/cancel", handlers.CancelAccountDeletion)
+		authed.GET("/user/me/export", handlers.RequestDataExport)
+		authed.GET("/user/me/export/:id", handlers.GetDataExportStatus)
+		authed.GET("/user/me/export/:id/download", handlers.DownloadDataExport)
+		authed.GET("/user/me/identities", handlers.ListIdentities)
+		authed.POST("/user/me/identities/google", handlers.LinkGoogle)
+		authed.DELETE("/user/me/identities/:provider", handlers.UnlinkIdentity)
+		authed.GET("/user/me/sessions", handlers.ListSessions)
+		authed.DELETE("/user/me/sessions/:id", handlers.RevokeSession)
+		authed.POST("/user/me/2fa/setup", handlers.SetupTwoFactor)
+		authed.POST("/user/me/2fa/confirm", handlers.ConfirmTwoFactor)
+		authed.POST("/user/me/2fa/disable", handlers.DisableTwoFactor)
+		authed.POST("/user/me/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		authed.GET("/user/me/tokens", handlers.ListAccessTokens)
+		authed.POST("/user/me/tokens", handlers.CreateAccessToken)
+		authed.DELETE("/user/me/tokens/:id", handlers.RevokeAccessToken)
+	}
+
+	// Routes that personal access tokens may also call, given the listed scope
+	r.GET("/user/me", middleware.RequireAuth(models.ScopeProfileRead), handlers.GetMe)
+	r.POST("/blogs", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.CreateBlog)
+	r.PUT("/blogs/update", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.UpdateBlog)
+	r.DELETE("/blogs/delete", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.DeleteBlog)
+	r.POST("/blogs/toggle-like", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.ToggleLike)
+	r.PUT("/blogs/:id", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.UpdateBlogByID)
+	r.DELETE("/blogs/:id", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.DeleteBlogByID)
+	r.POST("/blogs/:id/like", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.ToggleBlogLike)
+	r.PUT("/blogs/:id/bookmark", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.BookmarkBlog)
+	r.DELETE("/blogs/:id/bookmark", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.UnbookmarkBlog)
+	r.GET("/user/me/bookmarks", middleware.RequireAuth(models.ScopeProfileRead), handlers.GetBookmarks)
+	r.GET("/user/me/drafts", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.GetMyDrafts)
+	r.POST("/blogs/:id/publish", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.PublishBlog)
+	r.PUT("/blogs/:id/schedule", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.ScheduleBlog)
+	r.DELETE("/blogs/:id/schedule", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.UnscheduleBlog)
+	r.GET("/blogs/:id/revisions", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.ListBlogRevisions)
+	r.GET("/blogs/:id/revisions/diff", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.DiffBlogRevisions)
+	r.GET("/blogs/:id/revisions/:rev", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.GetBlogRevision)
+	r.POST("/blogs/:id/revisions/:rev/restore", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.RestoreBlogRevision)
+	r.POST("/uploads/images", middleware.RequireAuth(models.ScopeBlogsWrite), handlers.UploadImage)
+	r.POST("/comments", middleware.RequireAuth(models.ScopeCommentsWrite), handlers.AddComment)
+
+	// Follow and Notification routes
+	r.POST("/follow/toggle", middleware.RequireAuth(models.ScopeFollowsWrite), handlers.ToggleFollow)
+	r.PUT("/follow/tags/:tag", middleware.RequireAuth(models.ScopeFollowsWrite), handlers.FollowTag)
+	r.DELETE("/follow/tags/:tag", middleware.RequireAuth(models.ScopeFollowsWrite), handlers.UnfollowTag)
+	r.PUT("/follow/categories/:category", middleware.RequireAuth(models.ScopeFollowsWrite), handlers.FollowCategory)
+	r.DELETE("/follow/categories/:category", middleware.RequireAuth(models.ScopeFollowsWrite), handlers.UnfollowCategory)
+	r.GET("/feed", middleware.RequireAuth(models.ScopeProfileRead), handlers.GetFeed)
+	r.GET("/notifications", middleware.RequireAuth(models.ScopeNotificationsRead), handlers.GetNotifications)
+	r.POST("/notifications/:id/read", middleware.RequireAuth(models.ScopeNotificationsWrite), handlers.MarkNotificationRead)
+	r.POST("/notifications/read-all", middleware.RequireAuth(models.ScopeNotificationsWrite), handlers.MarkAllNotificationsRead)
+	r.GET("/notifications/unread-count", middleware.RequireAuth(models.ScopeNotificationsRead), handlers.GetUnreadCount)
 
 	// Chat routes (via gRPC Handlers)
+	chatRead := middleware.RequireAuth(models.ScopeChatRead)
+	chatWrite := middleware.RequireAuth(models.ScopeChatWrite)
 	chatGroup := r.Group("/chat")
 	{
-		chatGroup.GET("/sidebar", chat_handlers.GetChatSidebar)
-		chatGroup.GET("/messages", chat_handlers.GetMessages)
-		chatGroup.POST("/send", chat_handlers.SendMessage)
-		chatGroup.POST("/read", chat_handlers.ReadMessages)
-		chatGroup.DELETE("/message", chat_handlers.DeleteMessage)
-		chatGroup.PUT("/message", chat_handlers.EditMessage)
-		chatGroup.GET("/stream", chat_handlers.StreamMessagesWS)
+		chatGroup.GET("/sidebar", chatRead, chat_handlers.GetChatSidebar)
+		chatGroup.GET("/messages", chatRead, chat_handlers.GetMessages)
+		chatGroup.POST("/send", chatWrite, chat_handlers.SendMessage)
+		chatGroup.POST("/read", chatWrite, chat_handlers.ReadMessages)
+		chatGroup.DELETE("/message", chatWrite, chat_handlers.DeleteMessage)
+		chatGroup.PUT("/message", chatWrite, chat_handlers.EditMessage)
+		chatGroup.GET("/stream", chatRead, chat_handlers.StreamMessagesWS)
+	}
+
+	// Staff routes; each one checks the caller's role for the permission it needs
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(middleware.RequireAuth())
+	{
+		adminGroup.POST("/blogs/:id/feature", middleware.RequirePermission(models.PermFeatureBlogs), handlers.FeatureBlog)
+		adminGroup.DELETE("/blogs/:id/feature", middleware.RequirePermission(models.PermFeatureBlogs), handlers.UnfeatureBlog)
+		adminGroup.DELETE("/comments/:id", middleware.RequirePermission(models.PermRemoveComments), handlers.RemoveComment)
+		adminGroup.POST("/users/:id/suspension", middleware.RequirePermission(models.PermSuspendUsers), handlers.SuspendUser)
+		adminGroup.DELETE("/users/:id/suspension", middleware.RequirePermission(models.PermSuspendUsers), handlers.LiftSuspension)
+		adminGroup.PUT("/users/:id/role", middleware.RequirePermission(models.PermManageRoles), handlers.SetUserRole)
 	}
 
 	addr := fmt.Sprintf(":%d", config.ServerPort)
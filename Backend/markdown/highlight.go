@@ -0,0 +1,214 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// language describes just enough of a programming language to colour its keywords, strings,
+// comments and numbers.
+type language struct {
+	keywords      map[string]bool
+	caseFold      bool // keywords match regardless of case
+	lineComments  []string
+	blockComments [][2]string
+	quotes        string
+	multiline     string // quotes whose strings may span lines
+}
+
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	cLike = [][2]string{{"/*", "*/"}}
+
+	goLang = &language{
+		keywords:      words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+		lineComments:  []string{"//"},
+		blockComments: cLike,
+		quotes:        "\"'`",
+		multiline:     "`",
+	}
+	jsLang = &language{
+		keywords:      words("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield null undefined true false interface type enum implements private public protected readonly"),
+		lineComments:  []string{"//"},
+		blockComments: cLike,
+		quotes:        "\"'`",
+		multiline:     "`",
+	}
+	pythonLang = &language{
+		keywords:     words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	javaLang = &language{
+		keywords:      words("abstract boolean break byte case catch char class const continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return short static super switch synchronized this throw throws try void volatile while null true false var val fun when object override"),
+		lineComments:  []string{"//"},
+		blockComments: cLike,
+		quotes:        "\"'",
+	}
+	cLang = &language{
+		keywords:      words("auto bool break case char class const continue default delete do double else enum extern float for goto if include define inline int long namespace new nullptr private protected public return short signed sizeof static struct switch template this typedef union unsigned using virtual void volatile while true false NULL"),
+		lineComments:  []string{"//"},
+		blockComments: cLike,
+		quotes:        "\"'",
+	}
+	rustLang = &language{
+		keywords:      words("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+		lineComments:  []string{"//"},
+		blockComments: cLike,
+		quotes:        "\"",
+	}
+	shellLang = &language{
+		keywords:     words("if then else elif fi for while until do done case esac in function return local export echo exit"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	sqlLang = &language{
+		keywords:      words("select from where and or not insert into values update set delete create table drop alter index join inner left right outer on as group by order having limit offset distinct null is in like between union all primary key foreign references default"),
+		caseFold:      true,
+		lineComments:  []string{"--"},
+		blockComments: cLike,
+		quotes:        "'\"",
+	}
+	jsonLang = &language{
+		keywords: words("true false null"),
+		quotes:   "\"",
+	}
+)
+
+// languages maps the names used after a code fence to their descriptions.
+var languages = map[string]*language{
+	"go": goLang, "golang": goLang,
+	"js": jsLang, "javascript": jsLang, "ts": jsLang, "typescript": jsLang, "jsx": jsLang, "tsx": jsLang,
+	"py": pythonLang, "python": pythonLang,
+	"java": javaLang, "kotlin": javaLang, "kt": javaLang,
+	"c": cLang, "cpp": cLang, "c++": cLang, "h": cLang,
+	"rust": rustLang, "rs": rustLang,
+	"sh": shellLang, "bash": shellLang, "shell": shellLang, "zsh": shellLang,
+	"sql":  sqlLang,
+	"json": jsonLang,
+}
+
+// highlight escapes code and, for known languages, wraps its tokens in <span class="tok-…">.
+func highlight(lang, code string) string {
+	l, ok := languages[lang]
+	if !ok {
+		return html.EscapeString(code)
+	}
+
+	var out strings.Builder
+	span := func(class, text string) {
+		out.WriteString(`<span class="tok-` + class + `">` + html.EscapeString(text) + `</span>`)
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+
+		if hasAnyPrefix(rest, l.lineComments) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			span("comment", rest[:end])
+			i += end
+			continue
+		}
+		if pair, ok := blockCommentAt(rest, l.blockComments); ok {
+			end := strings.Index(rest[len(pair[0]):], pair[1])
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += len(pair[0]) + len(pair[1])
+			}
+			span("comment", rest[:end])
+			i += end
+			continue
+		}
+
+		c := rest[0]
+		switch {
+		case strings.IndexByte(l.quotes, c) >= 0:
+			end := stringEnd(rest, c, strings.IndexByte(l.multiline, c) >= 0)
+			span("string", rest[:end])
+			i += end
+		case c >= '0' && c <= '9':
+			end := 1
+			for end < len(rest) && (isIdentByte(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span("number", rest[:end])
+			i += end
+		case isIdentByte(c):
+			end := 1
+			for end < len(rest) && isIdentByte(rest[end]) {
+				end++
+			}
+			word := rest[:end]
+			key := word
+			if l.caseFold {
+				key = strings.ToLower(word)
+			}
+			if l.keywords[key] {
+				span("keyword", word)
+			} else {
+				out.WriteString(html.EscapeString(word))
+			}
+			i += end
+		default:
+			// Copy a whole rune so multi-byte characters are not split
+			_, n := utf8.DecodeRuneInString(rest)
+			out.WriteString(html.EscapeString(rest[:n]))
+			i += n
+		}
+	}
+	return out.String()
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockCommentAt(s string, pairs [][2]string) ([2]string, bool) {
+	for _, pair := range pairs {
+		if strings.HasPrefix(s, pair[0]) {
+			return pair, true
+		}
+	}
+	return [2]string{}, false
+}
+
+// stringEnd returns the length of the string literal at the start of s, stopping at the end of
+// the line for quotes that cannot span lines.
+func stringEnd(s string, quote byte, multiline bool) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case '\n':
+			if !multiline {
+				return i
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
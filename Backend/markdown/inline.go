@@ -0,0 +1,253 @@
+package markdown
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+// maxInlineNesting bounds how deeply links, images and emphasis nest. The content of each is
+// rendered again, so every level costs another pass over the text.
+const maxInlineNesting = 8
+
+// inline renders emphasis, strikethrough, code spans, links, images and autolinks, escaping all
+// other text. Raw HTML is escaped too, so it shows up as written.
+func inline(text string) string {
+	return renderInline(text, 0)
+}
+
+// inlineParser renders one run of inline text. Brackets, parentheses and backtick runs are matched
+// in one pass up front and emphasis delimiters that found no closer are remembered, so no position
+// is scanned more than a few times however the delimiters are arranged.
+type inlineParser struct {
+	text     string
+	depth    int
+	brackets map[int]int     // position of a '[' -> its matching ']'
+	parens   map[int]int     // position of a '(' -> its matching ')'
+	ticks    map[int][]int   // length of a backtick run -> where the runs of that length start
+	unclosed map[string]bool // emphasis delimiters with no closer after the last one tried
+}
+
+func renderInline(text string, depth int) string {
+	p := &inlineParser{
+		text:     text,
+		depth:    depth,
+		brackets: make(map[int]int),
+		parens:   make(map[int]int),
+		ticks:    make(map[int][]int),
+		unclosed: make(map[string]bool),
+	}
+	p.match()
+	return p.render()
+}
+
+// match pairs up brackets, skipping escaped ones, and parentheses, and records every backtick run.
+func (p *inlineParser) match() {
+	var brackets, parens []int
+	escaped := false
+	for i := 0; i < len(p.text); i++ {
+		c := p.text[i]
+		switch {
+		case c == '\\' && !escaped:
+			escaped = true
+			continue
+		case c == '[' && !escaped:
+			brackets = append(brackets, i)
+		case c == ']' && !escaped && len(brackets) > 0:
+			p.brackets[brackets[len(brackets)-1]] = i
+			brackets = brackets[:len(brackets)-1]
+		case c == '(':
+			parens = append(parens, i)
+		case c == ')' && len(parens) > 0:
+			p.parens[parens[len(parens)-1]] = i
+			parens = parens[:len(parens)-1]
+		case c == '`' && (i == 0 || p.text[i-1] != '`'):
+			n := backtickRun(p.text[i:])
+			p.ticks[n] = append(p.ticks[n], i)
+		}
+		escaped = false
+	}
+}
+
+func (p *inlineParser) render() string {
+	text := p.text
+	nested := p.depth < maxInlineNesting
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if code, n, ok := p.codeSpan(i); ok {
+				out.WriteString(code)
+				i += n
+				continue
+			}
+			// Without a closing run the whole run is literal
+			n := backtickRun(rest)
+			out.WriteString(rest[:n])
+			i += n
+			continue
+
+		case c == '!' && nested && strings.HasPrefix(rest, "!["):
+			if label, url, title, end, ok := p.linkAt(i + 1); ok && safeURL(url) {
+				alt := plainTextOf(renderInline(label, p.depth+1))
+				out.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(alt) + `"`)
+				if title != "" {
+					out.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				out.WriteString(">")
+				i = end
+				continue
+			}
+
+		case c == '[' && nested:
+			if label, url, title, end, ok := p.linkAt(i); ok && safeURL(url) {
+				out.WriteString(`<a href="` + html.EscapeString(url) + `"`)
+				if title != "" {
+					out.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				out.WriteString(` rel="` + linkRel + `">` + renderInline(label, p.depth+1) + "</a>")
+				i = end
+				continue
+			}
+
+		case c == '<':
+			// An autolink holds no spaces or angle brackets, which also keeps the search short
+			if end := strings.IndexAny(rest[1:], "<> \t\n"); end >= 0 && rest[1+end] == '>' {
+				url := rest[1 : 1+end]
+				if (strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")) && safeURL(url) {
+					escaped := html.EscapeString(url)
+					out.WriteString(`<a href="` + escaped + `" rel="` + linkRel + `">` + escaped + "</a>")
+					i += end + 2
+					continue
+				}
+			}
+
+		case (c == '*' || c == '_' || c == '~') && nested:
+			if rendered, n, ok := p.emphasis(i); ok {
+				out.WriteString(rendered)
+				i += n
+				continue
+			}
+		}
+
+		out.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// backtickRun returns how many backticks s starts with.
+func backtickRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] == '`' {
+		n++
+	}
+	return n
+}
+
+// codeSpan renders a span opened by the run of backticks at i and closed by the next run of the
+// same length; longer or shorter runs do not close it.
+func (p *inlineParser) codeSpan(i int) (string, int, bool) {
+	ticks := backtickRun(p.text[i:])
+	runs := p.ticks[ticks]
+	next := sort.SearchInts(runs, i+ticks)
+	if next == len(runs) {
+		return "", 0, false
+	}
+	end := runs[next]
+	code := p.text[i+ticks : end]
+	if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+		code = code[1 : len(code)-1]
+	}
+	return "<code>" + html.EscapeString(code) + "</code>", end + ticks - i, true
+}
+
+// linkAt parses [label](url "title") starting at the '[' at i, returning its parts and the
+// position after it.
+func (p *inlineParser) linkAt(i int) (label, url, title string, end int, ok bool) {
+	closeBracket, found := p.brackets[i]
+	if !found {
+		return "", "", "", 0, false
+	}
+	closeParen, found := p.parens[closeBracket+1]
+	if !found {
+		return "", "", "", 0, false
+	}
+
+	target := strings.TrimSpace(p.text[closeBracket+2 : closeParen])
+	url = target
+	if sp := strings.IndexAny(target, " \t"); sp >= 0 {
+		url = target[:sp]
+		title = strings.TrimSpace(target[sp:])
+		if len(title) < 2 || title[0] != '"' || title[len(title)-1] != '"' {
+			return "", "", "", 0, false
+		}
+		title = title[1 : len(title)-1]
+	}
+	url = strings.TrimSuffix(strings.TrimPrefix(url, "<"), ">")
+	return p.text[i+1 : closeBracket], url, title, closeParen + 1, true
+}
+
+// emphasis renders **strong**, __strong__, *em*, _em_ or ~~del~~ starting at text[i]. Delimiters
+// must hug their content, and underscores inside words are left alone.
+func (p *inlineParser) emphasis(i int) (string, int, bool) {
+	text := p.text
+	c := text[i]
+	delim := text[i : i+1]
+	tag := "em"
+	if strings.HasPrefix(text[i:], strings.Repeat(delim, 2)) {
+		delim += delim[:1]
+		tag = "strong"
+	}
+	if c == '~' {
+		if len(delim) != 2 {
+			return "", 0, false
+		}
+		tag = "del"
+	}
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return "", 0, false
+	}
+
+	start := i + len(delim)
+	if start >= len(text) || text[start] == ' ' || p.unclosed[delim] {
+		return "", 0, false
+	}
+	for q := start + 1; q < len(text); {
+		end := strings.Index(text[q:], delim)
+		if end < 0 {
+			break
+		}
+		end += q
+		after := end + len(delim)
+		if text[end-1] == ' ' || (c == '_' && after < len(text) && isWordByte(text[after])) {
+			q = end + 1
+			continue
+		}
+		// A single delimiter must not be half of a double one
+		if len(delim) == 1 && after < len(text) && text[after] == c {
+			q = after + 1
+			continue
+		}
+		return "<" + tag + ">" + renderInline(text[start:end], p.depth+1) + "</" + tag + ">", after - i, true
+	}
+	// Whether a closer fits does not depend on the opener, so later openers would not find one either
+	p.unclosed[delim] = true
+	return "", 0, false
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return isIdentByte(c) && c != '_'
+}
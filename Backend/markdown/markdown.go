@@ -0,0 +1,340 @@
+// Package markdown renders blog content to HTML that is safe to put into a page as is: text is
+// always escaped, raw HTML in the source is shown rather than interpreted, and the output is passed
+// through an allowlist sanitizer as a second line of defence.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// Version identifies the renderer's output. Bump it whenever the output changes so that HTML
+// cached by an older version is rendered again.
+const Version = 2
+
+// headingIDPrefix keeps heading anchors from clashing with the IDs the frontend uses itself.
+const headingIDPrefix = "toc-"
+
+// linkRel is set on every link, since links in blogs are written by users.
+const linkRel = "nofollow ugc noopener noreferrer"
+
+// Heading is an entry of the table of contents.
+type Heading struct {
+	Level int
+	Text  string
+	ID    string
+}
+
+// Result is rendered content and the headings found in it.
+type Result struct {
+	HTML     string
+	Headings []Heading
+}
+
+// RenderPlain renders plain text: blank lines separate paragraphs and single newlines become line breaks.
+func RenderPlain(src string) Result {
+	var out strings.Builder
+	for _, para := range blankLinePattern.Split(normalizeNewlines(src), -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		lines := strings.Split(para, "\n")
+		for i := range lines {
+			lines[i] = html.EscapeString(strings.TrimSpace(lines[i]))
+		}
+		out.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>\n")
+	}
+	return Result{HTML: Sanitize(out.String()), Headings: []Heading{}}
+}
+
+// Render renders Markdown: ATX headings, paragraphs, emphasis, strikethrough, inline code, links,
+// images, block quotes, nested lists, horizontal rules and fenced code blocks, which are
+// syntax-highlighted when their language is known.
+func Render(src string) Result {
+	r := &renderer{ids: make(map[string]int), headings: []Heading{}}
+	r.blocks(strings.Split(normalizeNewlines(src), "\n"))
+	return Result{HTML: Sanitize(r.out.String()), Headings: r.headings}
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+type renderer struct {
+	out      strings.Builder
+	headings []Heading
+	ids      map[string]int
+}
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	rulePattern      = regexp.MustCompile(`^(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fencePattern     = regexp.MustCompile("^(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	listItemPattern  = regexp.MustCompile(`^([ \t]*)([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+	langPattern      = regexp.MustCompile(`^[a-z0-9+#-]+$`)
+	blankLinePattern = regexp.MustCompile(`\n\s*\n`)
+	tagPattern       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// blocks renders a sequence of block-level elements.
+func (r *renderer) blocks(lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+		case fencePattern.MatchString(trimmed):
+			i = r.fencedCode(lines, i)
+		case headingPattern.MatchString(trimmed):
+			m := headingPattern.FindStringSubmatch(trimmed)
+			r.heading(len(m[1]), m[2])
+			i++
+		case rulePattern.MatchString(trimmed):
+			r.out.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			i = r.blockquote(lines, i)
+		case listItemPattern.MatchString(line):
+			i = r.list(lines, i)
+		default:
+			i = r.paragraph(lines, i)
+		}
+	}
+}
+
+// startsBlock reports whether a line interrupts a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" ||
+		fencePattern.MatchString(trimmed) ||
+		headingPattern.MatchString(trimmed) ||
+		rulePattern.MatchString(trimmed) ||
+		strings.HasPrefix(trimmed, ">") ||
+		listItemPattern.MatchString(line)
+}
+
+func (r *renderer) paragraph(lines []string, i int) int {
+	start := i
+	i++
+	for i < len(lines) && !startsBlock(lines[i]) {
+		i++
+	}
+	r.out.WriteString("<p>" + r.inlineLines(lines[start:i]) + "</p>\n")
+	return i
+}
+
+// inlineLines renders the lines of a paragraph, turning a trailing double space or backslash into a line break.
+func (r *renderer) inlineLines(lines []string) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " \t")
+		brk := i < len(lines)-1 && (strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\"))
+		line = strings.TrimRight(line, " \t")
+		if brk {
+			line = strings.TrimSuffix(line, "\\")
+		}
+		parts[i] = inline(line)
+		if brk {
+			parts[i] += "<br>"
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (r *renderer) heading(level int, text string) {
+	id := utils.Slugify(plainText(text), 60)
+	if id == "" {
+		id = "section"
+	}
+	if n := r.ids[id]; n > 0 {
+		r.ids[id] = n + 1
+		id += "-" + strconv.Itoa(n+1)
+	} else {
+		r.ids[id] = 1
+	}
+	id = headingIDPrefix + id
+
+	r.headings = append(r.headings, Heading{Level: level, Text: plainText(text), ID: id})
+	tag := "h" + strconv.Itoa(level)
+	r.out.WriteString("<" + tag + ` id="` + id + `">` + inline(text) + "</" + tag + ">\n")
+}
+
+func (r *renderer) fencedCode(lines []string, i int) int {
+	m := fencePattern.FindStringSubmatch(strings.TrimSpace(lines[i]))
+	fence := m[1]
+	lang := strings.ToLower(m[2])
+	if !langPattern.MatchString(lang) {
+		lang = ""
+	}
+
+	i++
+	var code []string
+	for ; i < len(lines); i++ {
+		closing := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	source := strings.Join(code, "\n")
+	if len(code) > 0 {
+		source += "\n"
+	}
+	if lang == "" {
+		r.out.WriteString("<pre><code>" + html.EscapeString(source) + "</code></pre>\n")
+	} else {
+		r.out.WriteString(`<pre><code class="language-` + lang + `">` + highlight(lang, source) + "</code></pre>\n")
+	}
+	return i
+}
+
+func (r *renderer) blockquote(lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(trimmed, ">") {
+			break
+		}
+		trimmed = strings.TrimPrefix(trimmed, ">")
+		inner = append(inner, strings.TrimPrefix(trimmed, " "))
+	}
+	r.out.WriteString("<blockquote>\n")
+	r.blocks(inner)
+	r.out.WriteString("</blockquote>\n")
+	return i
+}
+
+// list renders consecutive items of the same kind. An item continues on following lines that are
+// indented past its marker, and on unindented lines that do not start a new block.
+func (r *renderer) list(lines []string, i int) int {
+	first := listItemPattern.FindStringSubmatch(lines[i])
+	indent := len(first[1])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+
+	if ordered {
+		start, _ := strconv.Atoi(strings.TrimRight(first[2], ".)"))
+		if start != 1 {
+			r.out.WriteString(`<ol start="` + strconv.Itoa(start) + `">` + "\n")
+		} else {
+			r.out.WriteString("<ol>\n")
+		}
+	} else {
+		r.out.WriteString("<ul>\n")
+	}
+
+	for i < len(lines) {
+		m := listItemPattern.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || (m[2][0] >= '0' && m[2][0] <= '9') != ordered {
+			break
+		}
+
+		body := []string{m[3]}
+		loose := false
+		i++
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line continues the item only if indented content follows
+				if i+1 < len(lines) && leadingSpace(lines[i+1]) > indent && strings.TrimSpace(lines[i+1]) != "" {
+					body = append(body, "")
+					loose = true
+					i++
+					continue
+				}
+				break
+			}
+			if leadingSpace(line) > indent {
+				body = append(body, dedent(line, indent+2))
+				i++
+				continue
+			}
+			if startsBlock(line) {
+				break
+			}
+			body = append(body, line)
+			i++
+		}
+
+		r.out.WriteString("<li>")
+		r.listItem(body, loose)
+		r.out.WriteString("</li>\n")
+
+		// Blank lines between items of the same list do not end it
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) {
+			if next := listItemPattern.FindStringSubmatch(lines[i+1]); next != nil && len(next[1]) == indent {
+				i++
+				continue
+			}
+			break
+		}
+	}
+
+	if ordered {
+		r.out.WriteString("</ol>\n")
+	} else {
+		r.out.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// listItem renders the body of a list item. In a tight item the leading text is inline rather
+// than wrapped in a paragraph.
+func (r *renderer) listItem(body []string, loose bool) {
+	if loose {
+		r.out.WriteString("\n")
+		r.blocks(body)
+		return
+	}
+	text := 0
+	for text < len(body) && (text == 0 || !startsBlock(body[text])) {
+		text++
+	}
+	r.out.WriteString(r.inlineLines(body[:text]))
+	if text < len(body) {
+		r.out.WriteString("\n")
+		r.blocks(body[text:])
+	}
+}
+
+func leadingSpace(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func dedent(line string, n int) string {
+	for n > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+		line = line[1:]
+		n--
+	}
+	return line
+}
+
+// plainText is the text of inline Markdown without its markup, as used in the table of contents.
+func plainText(text string) string {
+	return plainTextOf(inline(text))
+}
+
+// plainTextOf strips the markup from rendered inline HTML.
+func plainTextOf(rendered string) string {
+	stripped := tagPattern.ReplaceAllString(rendered, "")
+	return strings.TrimSpace(html.UnescapeString(stripped))
+}
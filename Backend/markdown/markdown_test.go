@@ -0,0 +1,115 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	const rel = ` rel="nofollow ugc noopener noreferrer"`
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"emphasis", "**bold** *em* _u_ ~~del~~ snake_case_name", "<p><strong>bold</strong> <em>em</em> <em>u</em> <del>del</del> snake_case_name</p>\n"},
+		{"unmatched delimiters", "~single~ __a__ ___ 2 * 3", "<p>~single~ <strong>a</strong> ___ 2 * 3</p>\n"},
+		{"escapes", `\*not em\* \[x\]`, "<p>*not em* [x]</p>\n"},
+		{"code spans", "`<b>` ``a`b`` ```x``", "<p><code>&lt;b&gt;</code> <code>a`b</code> ```x``</p>\n"},
+		{"link", `[a [b] *c*](https://x.com "T")`, `<p><a href="https://x.com" title="T"` + rel + `>a [b] <em>c</em></a></p>` + "\n"},
+		{"image", "![*alt*](/a.png)", `<p><img src="/a.png" alt="alt"></p>` + "\n"},
+		{"autolink", "<https://a.b/c> <http://a<b>", `<p><a href="https://a.b/c"` + rel + `>https://a.b/c</a> &lt;http://a&lt;b&gt;</p>` + "\n"},
+		{"headings", "## Same\n## Same", `<h2 id="toc-same">Same</h2>` + "\n" + `<h2 id="toc-same-2">Same</h2>` + "\n"},
+		{"lists", "1. one\n2. two\n\n3) x", "<ol>\n<li>one</li>\n<li>two</li>\n<li>x</li>\n</ol>\n"},
+		{"line breaks", "para  \nline\\\nnext", "<p>para<br>\nline<br>\nnext</p>\n"},
+		{"quote and rule", "> *q*\n\n***", "<blockquote>\n<p><em>q</em></p>\n</blockquote>\n<hr>\n"},
+
+		// Anything that could run script must come out inert
+		{"javascript link", "[x](javascript:alert(1))", "<p>[x](javascript:alert(1))</p>\n"},
+		{"mixed case scheme", "[x](JaVaScRiPt:alert(1))", "<p>[x](JaVaScRiPt:alert(1))</p>\n"},
+		{"javascript image", "![x](javascript:alert(1))", "<p>![x](javascript:alert(1))</p>\n"},
+		{"vbscript link", "[x](vbscript:msgbox(1))", "<p>[x](vbscript:msgbox(1))</p>\n"},
+		{"data link", "[x](data:text/html,<script>alert(1)</script>)", "<p>[x](data:text/html,&lt;script&gt;alert(1)&lt;/script&gt;)</p>\n"},
+		{"javascript autolink", "<javascript:alert(1)>", "<p>&lt;javascript:alert(1)&gt;</p>\n"},
+		{"quote in url", `[x](https://a.com" onmouseover="alert(1))`, "<p>[x](https://a.com&#34; onmouseover=&#34;alert(1))</p>\n"},
+		{"quote in title", `[x](https://a.com "t\" onclick=\"alert(1)")`, `<p><a href="https://a.com" title="t\&#34; onclick=\&#34;alert(1)"` + rel + `>x</a></p>` + "\n"},
+		{"quote in alt", `![a" onerror="alert(1)](/i.png)`, `<p><img src="/i.png" alt="a&#34; onerror=&#34;alert(1)"></p>` + "\n"},
+		{"raw img", "<img src=x onerror=alert(1)>", "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n"},
+		{"raw script", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"raw html in heading", "# <b>x</b>", `<h1 id="toc-b-x-b">&lt;b&gt;x&lt;/b&gt;</h1>` + "\n"},
+		{"html in code block", "```html\n<script>alert(1)</script>\n```", `<pre><code class="language-html">&lt;script&gt;alert(1)&lt;/script&gt;` + "\n</code></pre>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.src).HTML; got != tt.want {
+				t.Errorf("Render(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"event handler", `<p onclick="x">a</p>`, "<p>a</p>"},
+		{"javascript href", `<a href="javascript:alert(1)" title="t">x</a>`, `<a title="t">x</a>`},
+		{"leading space", `<a href=" javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"entity inside scheme", `<a href="java&#x09;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"namespaced attribute", `<a href="x" xlink:href="javascript:alert(1)">y</a>`, `<a href="x">y</a>`},
+		{"rel and target", `<a href="https://a.com" rel="opener" target="_blank">x</a>`, `<a href="https://a.com" rel="nofollow ugc noopener noreferrer">x</a>`},
+		{"data image", `<img src="data:image/svg+xml,x" alt="a">`, `<img alt="a">`},
+		{"script", "<script>alert(1)</script>after", "after"},
+		{"script in svg", "<svg><script>alert(1)</script></svg>ok", "ok"},
+		{"script in textarea", "<textarea><script>alert(1)</script></textarea>", ""},
+		{"iframe", `<iframe src="https://a.com"></iframe>x`, "x"},
+		{"comment", "<!-- <script>alert(1)</script> -->c", "c"},
+		{"unknown element", "<div><p>x</p></div>", "<p>x</p>"},
+		{"foreign heading id", `<h2 id="evil">x</h2>`, "<h2>x</h2>"},
+		{"heading id", `<h2 id="toc-ok">x</h2>`, `<h2 id="toc-ok">x</h2>`},
+		{"code class", `<code class="language-go x">a</code>`, "<code>a</code>"},
+		{"token class", `<span class="tok-keyword">a</span>`, `<span class="tok-keyword">a</span>`},
+		{"negative start", `<ol start="-1"><li>x</li></ol>`, "<ol><li>x</li></ol>"},
+		{"unclosed", "<strong><em>unclosed", "<strong><em>unclosed</em></strong>"},
+		{"stray end tag", "</p>stray<p>", "stray<p></p>"},
+		{"text", `a < b & "c"`, "a &lt; b &amp; &#34;c&#34;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.html); got != tt.want {
+				t.Errorf("Sanitize(%q)\n got %q\nwant %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderTimeIsLinear renders inputs that made bracket and delimiter matching quadratic, each
+// well past the content size limit; they used to take several seconds.
+func TestRenderTimeIsLinear(t *testing.T) {
+	var ticks strings.Builder
+	for n := 1; ticks.Len() < 160_000; n++ {
+		ticks.WriteString(strings.Repeat("`", n) + " ")
+	}
+	inputs := map[string]string{
+		"unclosed link targets": strings.Repeat("[a](", 40000),
+		"unclosed images":       strings.Repeat("![", 40000),
+		"unclosed emphasis":     strings.Repeat("*a ", 40000),
+		"unclosed strong":       strings.Repeat("**a ", 40000),
+		"unclosed underscores":  strings.Repeat("_a ", 40000),
+		"unclosed autolinks":    strings.Repeat("<<", 40000),
+		"backtick runs":         ticks.String(),
+		"nested links":          strings.Repeat("[", 20000) + strings.Repeat("x](u)", 20000),
+	}
+	for name, src := range inputs {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			Render(src)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("rendering %d bytes took %v", len(src), elapsed)
+			}
+		})
+	}
+}
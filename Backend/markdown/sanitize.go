@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedAttrs lists the only elements that survive sanitizing and the attributes each may keep.
+var allowedAttrs = map[string][]string{
+	"p": nil, "br": nil, "hr": nil,
+	"h1": {"id"}, "h2": {"id"}, "h3": {"id"}, "h4": {"id"}, "h5": {"id"}, "h6": {"id"},
+	"strong": nil, "em": nil, "del": nil,
+	"blockquote": nil, "pre": nil, "code": {"class"}, "span": {"class"},
+	"ul": nil, "ol": {"start"}, "li": nil,
+	"a":   {"href", "title", "rel"},
+	"img": {"src", "alt", "title"},
+}
+
+// voidElements never have an end tag.
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+// droppedWithContent are removed together with everything inside them.
+var droppedWithContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "svg": true, "math": true, "textarea": true, "select": true,
+}
+
+var (
+	headingIDPattern = regexp.MustCompile(`^` + headingIDPrefix + `[a-z0-9-]+$`)
+	codeClassPattern = regexp.MustCompile(`^language-[a-z0-9+#-]+$`)
+	spanClassPattern = regexp.MustCompile(`^tok-(keyword|string|comment|number)$`)
+)
+
+// Sanitize keeps only allowlisted elements and attributes of an HTML fragment. Links and images must
+// point to http(s), mailto or relative URLs; all other markup is removed and its text escaped.
+func Sanitize(fragment string) string {
+	var out strings.Builder
+	var open []string
+	dropDepth := 0
+	z := html.NewTokenizer(strings.NewReader(fragment))
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		name := tok.Data
+
+		switch tt {
+		case html.TextToken:
+			if dropDepth == 0 {
+				out.WriteString(html.EscapeString(tok.Data))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedWithContent[name] {
+				if tt == html.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			attrNames, ok := allowedAttrs[name]
+			if !ok || dropDepth > 0 {
+				continue
+			}
+			out.WriteString("<" + name)
+			for _, attr := range tok.Attr {
+				if value, ok := cleanAttr(name, attr, attrNames); ok {
+					out.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+				}
+			}
+			out.WriteString(">")
+			if !voidElements[name] {
+				open = append(open, name)
+			}
+
+		case html.EndTagToken:
+			if droppedWithContent[name] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			// Close up to the matching open element so the output stays well formed
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					for j := len(open) - 1; j >= i; j-- {
+						out.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	return out.String()
+}
+
+func cleanAttr(element string, attr html.Attribute, allowed []string) (string, bool) {
+	if attr.Namespace != "" || !contains(allowed, attr.Key) {
+		return "", false
+	}
+	value := strings.TrimSpace(attr.Val)
+	switch attr.Key {
+	case "href", "src":
+		return value, safeURL(value)
+	case "id":
+		return value, headingIDPattern.MatchString(value)
+	case "class":
+		if element == "code" {
+			return value, codeClassPattern.MatchString(value)
+		}
+		return value, spanClassPattern.MatchString(value)
+	case "start":
+		n, err := strconv.Atoi(value)
+		return value, err == nil && n >= 0
+	case "rel":
+		return linkRel, true
+	}
+	return value, true
+}
+
+// safeURL accepts relative URLs and absolute ones using http, https or mailto.
+func safeURL(u string) bool {
+	u = strings.TrimSpace(u)
+	if u == "" {
+		return false
+	}
+	// Control characters and whitespace can hide a scheme from browsers' lenient parsers
+	for _, r := range u {
+		if r < 0x20 || r == 0x7f || r == ' ' {
+			return false
+		}
+	}
+	colon := strings.IndexByte(u, ':')
+	if colon < 0 || strings.ContainsAny(u[:colon], "/?#") {
+		return true
+	}
+	switch strings.ToLower(u[:colon]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
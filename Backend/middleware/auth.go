@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+	"github.com/prachin77/insight-hub/utils"
+)
+
+// Keys under which RequireAuth stores the caller's identity in the gin.Context.
+const (
+	UserIDKey      = "user_id"
+	SessionIDKey   = "session_id"
+	AccessTokenKey = "access_token_id"
+)
+
+// RequireAuth rejects requests that are not authenticated and stores the caller's user ID in the
+// context, along with the session or personal access token they authenticated with.
+// The token is read from the Authorization header ("Bearer <token>") or, failing that, the auth cookie.
+//
+// Session JWTs may call any route. Personal access tokens are only accepted on routes that list the
+// scopes they need, and only if the token holds all of them; routes that list none are session-only.
+// Either way, requests from suspended accounts are refused.
+func RequireAuth(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := AccessToken(c.Request)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse("authentication required", nil))
+			return
+		}
+
+		if strings.HasPrefix(token, db.AccessTokenPrefix) {
+			requireAccessToken(c, token, scopes)
+			return
+		}
+
+		claims, err := utils.ParseJWT(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse("invalid or expired token", nil))
+			return
+		}
+
+		session, err := db.GetSession(c.Request.Context(), claims.SessionID)
+		if err != nil || session.UserID != claims.UserID || !session.Active(time.Now()) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse("session has been revoked or has expired", nil))
+			return
+		}
+		_ = db.TouchSession(c.Request.Context(), session, c.ClientIP())
+		if !requireActiveAccount(c, claims.UserID) {
+			return
+		}
+
+		c.Set(UserIDKey, claims.UserID)
+		c.Set(SessionIDKey, claims.SessionID)
+		c.Next()
+	}
+}
+
+func requireAccessToken(c *gin.Context, token string, scopes []string) {
+	if len(scopes) == 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, models.NewErrorResponse("personal access tokens cannot be used for this endpoint", nil))
+		return
+	}
+
+	pat, err := db.AuthenticateAccessToken(c.Request.Context(), token, c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse(db.ErrInvalidAccessToken.Error(), nil))
+		return
+	}
+	if !pat.HasScopes(scopes...) {
+		c.AbortWithStatusJSON(http.StatusForbidden, models.NewErrorResponse("token is missing the "+strings.Join(scopes, ", ")+" scope", nil))
+		return
+	}
+	if !requireActiveAccount(c, pat.UserID) {
+		return
+	}
+
+	c.Set(UserIDKey, pat.UserID)
+	c.Set(AccessTokenKey, pat.ID)
+	c.Next()
+}
+
+// requireActiveAccount loads the caller's account, aborting if it is gone or suspended, and caches
+// its role for CurrentRole so the account is only read once per request.
+func requireActiveAccount(c *gin.Context, userID string) bool {
+	user, err := db.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewErrorResponse("user not found", nil))
+		return false
+	}
+	if user.Suspension.Active(time.Now()) {
+		c.AbortWithStatusJSON(http.StatusForbidden, models.NewErrorResponse("your account has been suspended", gin.H{
+			"reason":     user.Suspension.Reason,
+			"expires_at": user.Suspension.ExpiresAt,
+		}))
+		return false
+	}
+	c.Set(RoleKey, user.Role.OrDefault())
+	return true
+}
+
+// OptionalAuth identifies the caller when the request carries a valid session, or a personal access
+// token holding the profile:read scope, and otherwise lets the request through anonymously.
+// Handlers behind it must treat an empty CurrentUserID as a signed-out visitor.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := AccessToken(c.Request)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		if strings.HasPrefix(token, db.AccessTokenPrefix) {
+			pat, err := db.AuthenticateAccessToken(c.Request.Context(), token, c.ClientIP())
+			if err == nil && pat.HasScopes(models.ScopeProfileRead) {
+				c.Set(UserIDKey, pat.UserID)
+				c.Set(AccessTokenKey, pat.ID)
+			}
+			c.Next()
+			return
+		}
+
+		claims, err := utils.ParseJWT(token)
+		if err != nil {
+			c.Next()
+			return
+		}
+		session, err := db.GetSession(c.Request.Context(), claims.SessionID)
+		if err == nil && session.UserID == claims.UserID && session.Active(time.Now()) {
+			_ = db.TouchSession(c.Request.Context(), session, c.ClientIP())
+			c.Set(UserIDKey, claims.UserID)
+			c.Set(SessionIDKey, claims.SessionID)
+		}
+		c.Next()
+	}
+}
+
+// CurrentUserID returns the authenticated user's ID set by RequireAuth.
+func CurrentUserID(c *gin.Context) string {
+	return c.GetString(UserIDKey)
+}
+
+// CurrentSessionID returns the ID of the session the caller authenticated with.
+func CurrentSessionID(c *gin.Context) string {
+	return c.GetString(SessionIDKey)
+}
+
+// CurrentAccessTokenID returns the ID of the personal access token the caller authenticated with,
+// or "" for sessions.
+func CurrentAccessTokenID(c *gin.Context) string {
+	return c.GetString(AccessTokenKey)
+}
+
+// AccessToken extracts the access token from the Authorization header or, failing that, the auth cookie.
+func AccessToken(r *http.Request) string {
+	if token := bearerToken(r); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
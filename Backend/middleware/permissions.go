@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prachin77/insight-hub/db"
+	"github.com/prachin77/insight-hub/models"
+)
+
+// RoleKey is where CurrentRole caches the caller's role for the rest of the request.
+const RoleKey = "role"
+
+// CurrentRole returns the authenticated caller's role, loading it once per request.
+// Callers that are not signed in, or whose account cannot be loaded, are treated as RoleUser.
+func CurrentRole(c *gin.Context) models.Role {
+	if role, ok := c.Get(RoleKey); ok {
+		return role.(models.Role)
+	}
+
+	role := models.RoleUser
+	if userID := CurrentUserID(c); userID != "" {
+		if r, err := db.GetUserRole(c.Request.Context(), userID); err == nil {
+			role = r
+		}
+	}
+	c.Set(RoleKey, role)
+	return role
+}
+
+// Can reports whether the caller's role grants a permission. Handlers use it for checks that
+// depend on the request, such as letting staff act on content they do not own.
+func Can(c *gin.Context, p models.Permission) bool {
+	return CurrentRole(c).Can(p)
+}
+
+// RequirePermission rejects callers whose role lacks the permission. It must run after RequireAuth.
+func RequirePermission(p models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Can(c, p) {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.NewErrorResponse("you do not have permission to do this", nil))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Scopes a personal access token can be granted. Each one unlocks a fixed set of routes;
+// account management (passwords, 2FA, tokens themselves) is never reachable with a token.
+const (
+	ScopeProfileRead        = "profile:read"
+	ScopeBlogsWrite         = "blogs:write"
+	ScopeCommentsWrite      = "comments:write"
+	ScopeFollowsWrite       = "follows:write"
+	ScopeNotificationsRead  = "notifications:read"
+	ScopeNotificationsWrite = "notifications:write"
+	ScopeChatRead           = "chat:read"
+	ScopeChatWrite          = "chat:write"
+)
+
+// TokenScopes lists every scope a token can be created with.
+var TokenScopes = []string{
+	ScopeProfileRead,
+	ScopeBlogsWrite,
+	ScopeCommentsWrite,
+	ScopeFollowsWrite,
+	ScopeNotificationsRead,
+	ScopeNotificationsWrite,
+	ScopeChatRead,
+	ScopeChatWrite,
+}
+
+// PersonalAccessToken is a long-lived, scoped API token for scripts. The document ID is the token ID
+// and only the hash of the token is stored; Hint keeps the last characters so users can tell tokens apart.
+type PersonalAccessToken struct {
+	ID         string    `firestore:"id" json:"id"`
+	UserID     string    `firestore:"user_id" json:"-"`
+	Name       string    `firestore:"name" json:"name"`
+	Scopes     []string  `firestore:"scopes" json:"scopes"`
+	TokenHash  string    `firestore:"token_hash" json:"-"`
+	Hint       string    `firestore:"hint" json:"hint"`
+	CreatedAt  time.Time `firestore:"created_at" json:"created_at"`
+	ExpiresAt  time.Time `firestore:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt time.Time `firestore:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string    `firestore:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+	Revoked    bool      `firestore:"revoked" json:"revoked"`
+	RevokedAt  time.Time `firestore:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the token can still be used at the given time. Tokens without an expiry never expire.
+func (t *PersonalAccessToken) Active(now time.Time) bool {
+	return !t.Revoked && (t.ExpiresAt.IsZero() || now.Before(t.ExpiresAt))
+}
+
+// HasScopes reports whether the token was granted every one of the given scopes.
+func (t *PersonalAccessToken) HasScopes(scopes ...string) bool {
+	for _, want := range scopes {
+		found := false
+		for _, have := range t.Scopes {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
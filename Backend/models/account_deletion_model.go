@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type AccountDeletionStatus string
+
+const (
+	AccountDeletionPending   AccountDeletionStatus = "pending"   // waiting out the grace period
+	AccountDeletionRunning   AccountDeletionStatus = "running"   // purge in progress
+	AccountDeletionCompleted AccountDeletionStatus = "completed" // all data removed
+	AccountDeletionCancelled AccountDeletionStatus = "cancelled" // user changed their mind during the grace period
+)
+
+// AccountDeletion tracks a requested account deletion. The document ID is the user ID.
+// CompletedSteps lets a worker that crashed mid-purge resume where it stopped.
+type AccountDeletion struct {
+	UserID         string                `firestore:"user_id" json:"user_id"`
+	Username       string                `firestore:"username" json:"username"`
+	Status         AccountDeletionStatus `firestore:"status" json:"status"`
+	RequestedAt    time.Time             `firestore:"requested_at" json:"requested_at"`
+	ScheduledFor   time.Time             `firestore:"scheduled_for" json:"scheduled_for"`
+	CompletedSteps []string              `firestore:"completed_steps" json:"completed_steps"`
+	LeaseExpiresAt time.Time             `firestore:"lease_expires_at" json:"-"`
+	UpdatedAt      time.Time             `firestore:"updated_at" json:"updated_at"`
+	LastError      string                `firestore:"last_error,omitempty" json:"last_error,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Sort orders accepted by the blog list.
+const (
+	BlogSortNewest     = "newest"
+	BlogSortMostViewed = "most_viewed"
+	BlogSortMostLiked  = "most_liked"
+)
+
+// Page sizes for the blog list.
+const (
+	DefaultBlogPageSize = 20
+	MaxBlogPageSize     = 100
+)
+
+// BlogListQuery selects one page of blogs. Zero-valued filters are not applied.
+type BlogListQuery struct {
+	Limit    int
+	Cursor   string // opaque; the NextCursor of the previous page
+	Sort     string
+	Category string
+	Tag      string
+	AuthorID string
+	Featured *bool
+	From     time.Time // published at or after
+	To       time.Time // published before
+}
+
+// BlogPage is one page of the blog list. NextCursor is empty on the last page.
+type BlogPage struct {
+	Blogs      []Blog
+	NextCursor string
+}
@@ -3,23 +3,68 @@ package models
 import "time"
 
 type Blog struct {
-	ID             string    `firestore:"id" json:"id"`
-	Title          string    `firestore:"title" json:"title"`
-	BlogContent    string    `firestore:"blog_content" json:"blog_content"`
-	AuthorID       string    `firestore:"author_id" json:"author_id"`
-	CreatedAt      time.Time `firestore:"created_at" json:"created_at"`
-	UpdatedAt      time.Time `firestore:"updated_at" json:"updated_at"`
-	Tags           []string  `firestore:"tags" json:"tags"`
-	BlogImage      string    `firestore:"blog_image" json:"blog_image"`
-	Category       string    `firestore:"category" json:"category"`
-	AuthorName     string    `firestore:"-" json:"author_name"`
-	AuthorUsername string    `firestore:"-" json:"author_username"`
-	Views          int       `firestore:"views" json:"views"`
-	Likes          int       `firestore:"likes" json:"likes"`
-	LikedBy        []string  `firestore:"liked_by" json:"liked_by"`
-	Comments       int       `firestore:"comments" json:"comments"`
-	Featured       bool      `firestore:"featured" json:"featured"`
-	Trending       bool      `firestore:"trending" json:"trending"`
+	ID             string     `firestore:"id" json:"id"`
+	Title          string     `firestore:"title" json:"title"`
+	Slug           string     `firestore:"slug" json:"slug"`
+	BlogContent    string     `firestore:"blog_content" json:"blog_content"`
+	AuthorID       string     `firestore:"author_id" json:"author_id"`
+	CreatedAt      time.Time  `firestore:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `firestore:"updated_at" json:"updated_at"`
+	Tags           []string   `firestore:"tags" json:"tags"`
+	BlogImage      string     `firestore:"blog_image" json:"blog_image"`
+	Category       string     `firestore:"category" json:"category"`
+	AuthorName     string     `firestore:"-" json:"author_name"`
+	AuthorUsername string     `firestore:"-" json:"author_username"`
+	AuthorAvatar   string     `firestore:"-" json:"author_avatar"`
+	Views          int        `firestore:"views" json:"views"`
+	Likes          int        `firestore:"likes" json:"likes"`
+	LikedBy        []string   `firestore:"liked_by" json:"liked_by"`
+	Comments       int        `firestore:"comments" json:"comments"`
+	Featured       bool       `firestore:"featured" json:"featured"`
+	Trending       bool       `firestore:"trending" json:"trending"`
+	TrendingScore  float64    `firestore:"trending_score,omitempty" json:"trending_score,omitempty"`
+	Status         string     `firestore:"status" json:"status"`
+	PublishAt      time.Time  `firestore:"publish_at,omitempty" json:"publish_at,omitempty"`
+	PublishedAt    time.Time  `firestore:"published_at,omitempty" json:"published_at,omitempty"`
+	Revision       int        `firestore:"revision" json:"revision"`
+	ContentFormat  string     `firestore:"content_format" json:"content_format"`
+	ContentHTML    string     `firestore:"content_html" json:"content_html"`
+	TOC            []TOCEntry `firestore:"toc" json:"toc"`
+	RenderVersion  int        `firestore:"render_version" json:"-"`
+	Uploads        []string   `firestore:"uploads,omitempty" json:"-"` // IDs of uploaded images used by the image and content
+}
+
+// Formats of BlogContent. ContentHTML is always rendered from it on the server and sanitized, so
+// clients can display it without escaping; blogs written before formats existed are plain text.
+const (
+	ContentFormatPlain    = "plain"
+	ContentFormatMarkdown = "markdown"
+)
+
+// TOCEntry is a heading of a Markdown blog, linked by its element ID in ContentHTML.
+type TOCEntry struct {
+	Level int    `firestore:"level" json:"level"`
+	Text  string `firestore:"text" json:"text"`
+	ID    string `firestore:"id" json:"id"`
+}
+
+// Publication states of a blog. Only published blogs are listed, searchable and open to readers;
+// drafts and scheduled blogs are visible to their author alone.
+const (
+	BlogStatusDraft     = "draft"
+	BlogStatusScheduled = "scheduled"
+	BlogStatusPublished = "published"
+)
+
+// Published reports whether readers other than the author can see the blog.
+func (b *Blog) Published() bool {
+	return b.Status == BlogStatusPublished
+}
+
+// VisibleTo reports whether the user may read the blog: anyone once it is published, and
+// before that only its author.
+func (b *Blog) VisibleTo(userID string) bool {
+	return b.Published() || (userID != "" && userID == b.AuthorID)
 }
 
 var ValidCategories = []string{
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Bookmark is a blog a user saved for later. The document ID is "<user id>_<blog id>".
+type Bookmark struct {
+	UserID    string    `firestore:"user_id" json:"user_id"`
+	BlogID    string    `firestore:"blog_id" json:"blog_id"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// EngagementBucket counts what happened to a blog during one hour, so ranking can weigh recent
+// activity instead of the lifetime counters on the blog. The document ID is "<blog id>_<YYYYMMDDHH>".
+type EngagementBucket struct {
+	BlogID   string    `firestore:"blog_id" json:"blog_id"`
+	Hour     time.Time `firestore:"hour" json:"hour"` // start of the hour, in UTC
+	Views    int       `firestore:"views" json:"views"`
+	Likes    int       `firestore:"likes" json:"likes"` // net of likes taken back
+	Comments int       `firestore:"comments" json:"comments"`
+}
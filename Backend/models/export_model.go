@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending" // queued, archive not started yet
+	DataExportRunning DataExportStatus = "running" // archive being built
+	DataExportReady   DataExportStatus = "ready"   // archive can be downloaded until ExpiresAt
+	DataExportFailed  DataExportStatus = "failed"
+)
+
+// DataExport tracks a personal data export requested by a user.
+// The archive itself lives on the server's disk under FileName.
+type DataExport struct {
+	ID          string           `firestore:"id" json:"id"`
+	UserID      string           `firestore:"user_id" json:"user_id"`
+	Status      DataExportStatus `firestore:"status" json:"status"`
+	RequestedAt time.Time        `firestore:"requested_at" json:"requested_at"`
+	UpdatedAt   time.Time        `firestore:"updated_at" json:"updated_at"`
+	CompletedAt time.Time        `firestore:"completed_at,omitempty" json:"completed_at,omitempty"`
+	ExpiresAt   time.Time        `firestore:"expires_at,omitempty" json:"expires_at,omitempty"`
+	SizeBytes   int64            `firestore:"size_bytes" json:"size_bytes"`
+	FileName    string           `firestore:"file_name,omitempty" json:"-"`
+	LastError   string           `firestore:"last_error,omitempty" json:"last_error,omitempty"`
+}
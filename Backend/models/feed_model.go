@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Why a blog was picked for a user's feed.
+const (
+	FeedReasonFollowing = "following" // written by an author the user follows
+	FeedReasonTag       = "tag"       // tagged with a tag the user follows
+	FeedReasonCategory  = "category"  // in a category the user follows
+	FeedReasonTrending  = "trending"  // trending right now
+)
+
+// FeedQuery selects one page of a user's personalized feed.
+type FeedQuery struct {
+	UserID string
+	Limit  int
+	Cursor string // opaque; the NextCursor of the previous page
+}
+
+// FeedItem is a blog in the feed and the reason it was picked.
+type FeedItem struct {
+	Blog   Blog   `json:"blog"`
+	Reason string `json:"reason"`
+}
+
+// FeedPage is one page of the feed. NextCursor is empty on the last page.
+type FeedPage struct {
+	Items      []FeedItem
+	NextCursor string
+}
+
+// BlogRead records that a user opened a blog, so the feed can leave it out.
+// The document ID is "<user id>_<blog id>".
+type BlogRead struct {
+	UserID string    `firestore:"user_id" json:"user_id"`
+	BlogID string    `firestore:"blog_id" json:"blog_id"`
+	ReadAt time.Time `firestore:"read_at" json:"read_at"`
+}
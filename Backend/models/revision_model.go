@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// BlogRevision is an immutable snapshot of the author-editable fields of a blog, stored each time
+// the blog is created, updated or restored. Numbers start at 1 and increase by one per revision.
+type BlogRevision struct {
+	BlogID        string    `firestore:"blog_id" json:"blog_id"`
+	Number        int       `firestore:"number" json:"number"`
+	AuthorID      string    `firestore:"author_id" json:"author_id"`
+	CreatedAt     time.Time `firestore:"created_at" json:"created_at"`
+	Title         string    `firestore:"title" json:"title"`
+	BlogContent   string    `firestore:"blog_content" json:"blog_content"`
+	ContentFormat string    `firestore:"content_format" json:"content_format"`
+	Tags          []string  `firestore:"tags" json:"tags"`
+	Category      string    `firestore:"category" json:"category"`
+	BlogImage     string    `firestore:"blog_image" json:"blog_image"`
+	RestoredFrom  int       `firestore:"restored_from,omitempty" json:"restored_from,omitempty"`
+	Uploads       []string  `firestore:"uploads,omitempty" json:"-"`
+}
+
+// SameContent reports whether the revision holds the same snapshot as the blog.
+func (r *BlogRevision) SameContent(b *Blog) bool {
+	if r.Title != b.Title || r.BlogContent != b.BlogContent || r.ContentFormat != b.ContentFormat ||
+		r.Category != b.Category || r.BlogImage != b.BlogImage {
+		return false
+	}
+	if len(r.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range r.Tags {
+		if r.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Format is the revision's content format; revisions saved before formats existed are plain text.
+func (r *BlogRevision) Format() string {
+	if r.ContentFormat == "" {
+		return ContentFormatPlain
+	}
+	return r.ContentFormat
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Role decides what staff actions a user may take. Users without a stored role are RoleUser.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Permission names a staff action checked by middleware.Can and middleware.RequirePermission.
+type Permission string
+
+const (
+	PermFeatureBlogs   Permission = "blogs:feature"
+	PermRemoveComments Permission = "comments:remove"
+	PermSuspendUsers   Permission = "users:suspend"
+	PermManageRoles    Permission = "users:manage_roles"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleModerator: {PermRemoveComments, PermSuspendUsers},
+	RoleAdmin:     {PermFeatureBlogs, PermRemoveComments, PermSuspendUsers, PermManageRoles},
+}
+
+var roleRanks = map[Role]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
+// ValidRole reports whether r is a known role.
+func ValidRole(r Role) bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Can reports whether the role grants a permission.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether r is strictly more privileged than other, which staff need
+// before acting on another account.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other.OrDefault()]
+}
+
+// OrDefault maps the empty role stored for regular users to RoleUser.
+func (r Role) OrDefault() Role {
+	if r == "" {
+		return RoleUser
+	}
+	return r
+}
+
+// Suspension records why and until when a user is barred from signing in and posting.
+type Suspension struct {
+	Reason      string    `firestore:"reason" json:"reason"`
+	SuspendedBy string    `firestore:"suspended_by" json:"-"`
+	SuspendedAt time.Time `firestore:"suspended_at" json:"suspended_at"`
+	ExpiresAt   time.Time `firestore:"expires_at,omitempty" json:"expires_at,omitempty"` // zero for a permanent ban
+}
+
+// Active reports whether the suspension is still in force at the given time.
+func (s *Suspension) Active(now time.Time) bool {
+	return s != nil && (s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt))
+}
+
+// Banned reports whether the suspension is permanent. Banned users' content is hidden from listings.
+func (s *Suspension) Banned() bool {
+	return s != nil && s.ExpiresAt.IsZero()
+}
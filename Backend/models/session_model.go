@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Session is a server-side login session. Access tokens carry its ID and stop working once it is revoked.
+type Session struct {
+	ID               string    `firestore:"id" json:"id"`
+	UserID           string    `firestore:"user_id" json:"user_id"`
+	RefreshTokenHash string    `firestore:"refresh_token_hash" json:"-"`
+	CreatedAt        time.Time `firestore:"created_at" json:"created_at"`
+	RefreshedAt      time.Time `firestore:"refreshed_at" json:"refreshed_at"`
+	ExpiresAt        time.Time `firestore:"expires_at" json:"expires_at"`
+	Revoked          bool      `firestore:"revoked" json:"revoked"`
+	RevokedAt        time.Time `firestore:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+
+	// Where the session is used from, shown in the device list.
+	DeviceLabel  string    `firestore:"device_label" json:"device_label"`
+	UserAgent    string    `firestore:"user_agent" json:"user_agent"`
+	IP           string    `firestore:"ip" json:"ip"`
+	LastActiveAt time.Time `firestore:"last_active_at" json:"last_active_at"`
+	Current      bool      `firestore:"-" json:"current"` // set when listing, for the caller's own session
+}
+
+// Active reports whether the session can still be used at the given time.
+func (s *Session) Active(now time.Time) bool {
+	return !s.Revoked && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// TokenPurpose scopes a one-time token to the flow that issued it.
+type TokenPurpose string
+
+const (
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	TokenPurposeLoginChallenge    TokenPurpose = "login_challenge"
+)
+
+// OneTimeToken is a single-use, expiring token sent to a user out of band (e.g. by email).
+// The document ID is the hash of the token, so the token itself is never stored.
+type OneTimeToken struct {
+	UserID    string       `firestore:"user_id" json:"user_id"`
+	Purpose   TokenPurpose `firestore:"purpose" json:"purpose"`
+	CreatedAt time.Time    `firestore:"created_at" json:"created_at"`
+	ExpiresAt time.Time    `firestore:"expires_at" json:"expires_at"`
+	Attempts  int          `firestore:"attempts" json:"attempts"`
+	Used      bool         `firestore:"used" json:"used"`
+	UsedAt    time.Time    `firestore:"used_at,omitempty" json:"used_at,omitempty"`
+}
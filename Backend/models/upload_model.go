@@ -0,0 +1,60 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// Upload is an uploaded image. Its ID is the SHA-256 of the stored full-size file, so an image
+// uploaded twice is stored once, and its files are named after the ID. The files are kept while a
+// blog, revision or avatar refers to them and garbage collected some time after that stops.
+type Upload struct {
+	ID          string          `firestore:"id" json:"id"`
+	UploadedBy  string          `firestore:"uploaded_by" json:"-"`
+	ContentType string          `firestore:"content_type" json:"content_type"`
+	Width       int             `firestore:"width" json:"width"`
+	Height      int             `firestore:"height" json:"height"`
+	SizeBytes   int64           `firestore:"size_bytes" json:"size_bytes"`
+	URL         string          `firestore:"url" json:"url"`
+	Variants    []UploadVariant `firestore:"variants" json:"variants"`
+	Keys        []string        `firestore:"keys" json:"-"` // blob keys of the image and its variants
+	CreatedAt   time.Time       `firestore:"created_at" json:"created_at"`
+	// LastUsedAt is when the image was last uploaded or found to be referenced; collection
+	// only considers uploads that have not been used for a while.
+	LastUsedAt time.Time `firestore:"last_used_at" json:"-"`
+	// DeletingUntil is set while garbage collection deletes the files. Until then the upload
+	// cannot be used again; once it has passed, a collection that did not finish is ignored.
+	DeletingUntil time.Time `firestore:"deleting_until" json:"-"`
+}
+
+// BeingDeleted reports whether garbage collection is deleting the upload's files at now.
+func (u *Upload) BeingDeleted(now time.Time) bool {
+	return now.Before(u.DeletingUntil)
+}
+
+// UploadVariant is a resized or re-encoded copy of an uploaded image.
+type UploadVariant struct {
+	Name        string `firestore:"name" json:"name"` // e.g. "w320", "w960" or "webp"
+	URL         string `firestore:"url" json:"url"`
+	ContentType string `firestore:"content_type" json:"content_type"`
+	Width       int    `firestore:"width" json:"width"`
+	Height      int    `firestore:"height" json:"height"`
+}
+
+var uploadURLPattern = regexp.MustCompile(`/uploads/([0-9a-f]{64})[._]`)
+
+// UploadIDs returns the IDs of the uploaded images linked from texts such as image URLs or blog
+// content, in order of first appearance.
+func UploadIDs(texts ...string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, m := range uploadURLPattern.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				ids = append(ids, m[1])
+			}
+		}
+	}
+	return ids
+}
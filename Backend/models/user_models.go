@@ -3,24 +3,111 @@ package models
 import "time"
 
 type User struct {
-	ID         string    `firestore:"id,omitempty" json:"id"`
-	FullName   string    `firestore:"FullName" json:"fullName"`
-	Username   string    `firestore:"Username" json:"username"`
-	Email        string    `firestore:"Email" json:"email" binding:"required,email"`
-	Password     string    `firestore:"Password" json:"password,omitempty"`
-	AuthProvider string    `firestore:"AuthProvider" json:"auth_provider"`
-	ProviderID   string    `firestore:"ProviderID" json:"provider_id"`
-	CreatedAt    time.Time `firestore:"CreatedAt" json:"created_at"`
-	NoOfBlogs    int       `firestore:"NoOfBlogs" json:"no_of_blogs"`
-	Followers    int       `firestore:"Followers" json:"followers"`
-	Followings   int       `firestore:"Followings" json:"followings"`
-	LastSeen     time.Time `firestore:"LastSeen" json:"last_seen"`
+	ID            string           `firestore:"id,omitempty" json:"id"`
+	FullName      string           `firestore:"FullName" json:"fullName"`
+	Username      string           `firestore:"Username" json:"username"`
+	Email         string           `firestore:"Email" json:"email" binding:"required,email"`
+	EmailVerified bool             `firestore:"EmailVerified" json:"email_verified"`
+	Password      string           `firestore:"Password" json:"password,omitempty"`
+	Identities    []LinkedIdentity `firestore:"Identities" json:"-"`
+	IdentityKeys  []string         `firestore:"IdentityKeys" json:"-"` // "provider:providerID", for lookups
+	CreatedAt     time.Time        `firestore:"CreatedAt" json:"created_at"`
+	NoOfBlogs     int              `firestore:"NoOfBlogs" json:"no_of_blogs"`
+	Followers     int              `firestore:"Followers" json:"followers"`
+	Followings    int              `firestore:"Followings" json:"followings"`
+	LastSeen      time.Time        `firestore:"LastSeen" json:"last_seen"`
+
+	// Public profile details editable through PUT /user/me.
+	Bio         string            `firestore:"Bio" json:"bio"`
+	AvatarURL   string            `firestore:"AvatarURL" json:"avatar_url"`
+	Website     string            `firestore:"Website" json:"website"`
+	Location    string            `firestore:"Location" json:"location"`
+	SocialLinks map[string]string `firestore:"SocialLinks" json:"social_links"` // network -> profile URL
+	// ID of the uploaded image AvatarURL points to, if any, which keeps it from being garbage collected.
+	AvatarUpload string `firestore:"AvatarUpload,omitempty" json:"-"`
+
+	// Two-factor authentication. Secrets and recovery code hashes never leave the server.
+	TwoFactorEnabled  bool     `firestore:"TwoFactorEnabled" json:"two_factor_enabled"`
+	TOTPSecret        string   `firestore:"TOTPSecret,omitempty" json:"-"`
+	PendingTOTPSecret string   `firestore:"PendingTOTPSecret,omitempty" json:"-"`
+	TOTPLastStep      int64    `firestore:"TOTPLastStep,omitempty" json:"-"`
+	RecoveryCodes     []string `firestore:"RecoveryCodes,omitempty" json:"-"`
+
+	// Tags and categories whose blogs appear in the user's feed, besides those of followed authors.
+	FollowedTags       []string `firestore:"FollowedTags" json:"followed_tags"`
+	FollowedCategories []string `firestore:"FollowedCategories" json:"followed_categories"`
+
+	// Staff role and moderation state. Role is left empty for regular users.
+	Role       Role        `firestore:"Role,omitempty" json:"role,omitempty"`
+	Suspension *Suspension `firestore:"Suspension,omitempty" json:"suspension,omitempty"`
+
+	// Deprecated: single-provider fields written before Identities existed; migrated on next sign-in.
+	LegacyAuthProvider string `firestore:"AuthProvider,omitempty" json:"-"`
+	LegacyProviderID   string `firestore:"ProviderID,omitempty" json:"-"`
+}
+
+// Supported external identity providers.
+const (
+	ProviderGoogle = "google"
+)
+
+// LinkedIdentity is an external sign-in provider connected to an account.
+type LinkedIdentity struct {
+	Provider   string    `firestore:"provider" json:"provider"`
+	ProviderID string    `firestore:"provider_id" json:"provider_id"`
+	Email      string    `firestore:"email" json:"email"`
+	LinkedAt   time.Time `firestore:"linked_at" json:"linked_at"`
+}
+
+// IdentityKey is the value stored in User.IdentityKeys for a provider account.
+func IdentityKey(provider, providerID string) string {
+	return provider + ":" + providerID
+}
+
+// SocialNetworks are the keys accepted in User.SocialLinks.
+var SocialNetworks = []string{"twitter", "github", "linkedin", "instagram", "facebook", "youtube", "mastodon"}
+
+// PublicProfile is what anyone may see about a user, e.g. as the author of a blog.
+type PublicProfile struct {
+	ID          string            `json:"id"`
+	FullName    string            `json:"fullName"`
+	Username    string            `json:"username"`
+	AvatarURL   string            `json:"avatar_url"`
+	Bio         string            `json:"bio"`
+	Website     string            `json:"website"`
+	Location    string            `json:"location"`
+	SocialLinks map[string]string `json:"social_links"`
+	NoOfBlogs   int               `json:"no_of_blogs"`
+	Followers   int               `json:"followers"`
+	Followings  int               `json:"followings"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// PublicProfile returns the public part of the user's profile.
+func (u *User) PublicProfile(id string) PublicProfile {
+	return PublicProfile{
+		ID:          id,
+		FullName:    u.FullName,
+		Username:    u.Username,
+		AvatarURL:   u.AvatarURL,
+		Bio:         u.Bio,
+		Website:     u.Website,
+		Location:    u.Location,
+		SocialLinks: u.SocialLinks,
+		NoOfBlogs:   u.NoOfBlogs,
+		Followers:   u.Followers,
+		Followings:  u.Followings,
+		CreatedAt:   u.CreatedAt,
+	}
 }
 
 type FollowUser struct {
-	FullName string `json:"fullName"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	ID        string `json:"id"`
+	FullName  string `json:"fullName"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+	Bio       string `json:"bio"`
 }
 
 type Followers struct {
@@ -0,0 +1,383 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// field is a searchable part of a document. Matches in the title count for more than in the body.
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldTags
+	fieldCategory
+	fieldContent
+	numFields
+)
+
+var fieldWeights = [numFields]float64{3, 2, 1.5, 1}
+
+// BM25 parameters, and how far prefixes and tag facets may grow.
+const (
+	bm25K1              = 1.2
+	bm25B               = 0.75
+	maxPrefixExpansions = 50
+	maxTagFacets        = 20
+	defaultHits         = 10
+)
+
+// positions lists where a term occurs in each field of one document.
+type positions [numFields][]int
+
+type entry struct {
+	doc     Document
+	lengths [numFields]int
+	terms   []string // distinct terms, so the entry can be removed again
+}
+
+// MemoryIndex is an in-process inverted index scored with BM25F. It holds every document in
+// memory and is rebuilt from Firestore when the server starts.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*entry
+	postings map[string]map[string]*positions // term -> doc ID -> positions
+	totalLen [numFields]int
+}
+
+// NewMemoryIndex returns an empty index.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[string]*entry),
+		postings: make(map[string]map[string]*positions),
+	}
+}
+
+func (ix *MemoryIndex) Upsert(doc Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(doc.ID)
+
+	e := &entry{doc: doc}
+	seen := make(map[string]bool)
+	for f, terms := range documentFields(doc) {
+		for pos, term := range terms {
+			if term == "" {
+				continue
+			}
+			e.lengths[f]++
+			docs := ix.postings[term]
+			if docs == nil {
+				docs = make(map[string]*positions)
+				ix.postings[term] = docs
+			}
+			p := docs[doc.ID]
+			if p == nil {
+				p = new(positions)
+				docs[doc.ID] = p
+			}
+			p[f] = append(p[f], pos)
+			if !seen[term] {
+				seen[term] = true
+				e.terms = append(e.terms, term)
+			}
+		}
+		ix.totalLen[f] += e.lengths[f]
+	}
+	ix.docs[doc.ID] = e
+	return nil
+}
+
+func (ix *MemoryIndex) Remove(id string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+	return nil
+}
+
+func (ix *MemoryIndex) remove(id string) {
+	e, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, term := range e.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	for f := range e.lengths {
+		ix.totalLen[f] -= e.lengths[f]
+	}
+	delete(ix.docs, id)
+}
+
+// documentFields returns the terms of each field by position. Tags are separated by an empty
+// term so that a phrase cannot run from one tag into the next.
+func documentFields(doc Document) [numFields][]string {
+	var fields [numFields][]string
+	fields[fieldTitle] = termsOf(doc.Title)
+	for i, tag := range doc.Tags {
+		if i > 0 {
+			fields[fieldTags] = append(fields[fieldTags], "")
+		}
+		fields[fieldTags] = append(fields[fieldTags], termsOf(tag)...)
+	}
+	fields[fieldCategory] = termsOf(doc.Category)
+	fields[fieldContent] = termsOf(doc.Content)
+	return fields
+}
+
+func (ix *MemoryIndex) Search(q Query) (*Results, error) {
+	results := &Results{Hits: []Hit{}, Facets: Facets{Categories: []FacetCount{}, Tags: []FacetCount{}}}
+	clauses := parseQuery(q.Text)
+	if len(clauses) == 0 {
+		return results, nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Every clause must match; a document's score is the sum of its clause scores.
+	hl := &highlighter{terms: make(map[string]bool)}
+	var scores map[string]float64
+	for i, c := range clauses {
+		matches := ix.matchClause(c, hl)
+		if i == 0 {
+			scores = matches
+		} else {
+			for id := range scores {
+				if s, ok := matches[id]; ok {
+					scores[id] += s
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+		if len(scores) == 0 {
+			return results, nil
+		}
+	}
+
+	type scored struct {
+		entry *entry
+		score float64
+	}
+	var hits []scored
+	categories := make(map[string]int)
+	tags := make(map[string]int)
+	for id, score := range scores {
+		e := ix.docs[id]
+		if q.Category != "" && !strings.EqualFold(e.doc.Category, q.Category) {
+			continue
+		}
+		if q.Tag != "" && !hasTag(e.doc.Tags, q.Tag) {
+			continue
+		}
+		hits = append(hits, scored{entry: e, score: score})
+		if e.doc.Category != "" {
+			categories[e.doc.Category]++
+		}
+		counted := make(map[string]bool, len(e.doc.Tags))
+		for _, tag := range e.doc.Tags {
+			if tag != "" && !counted[tag] {
+				counted[tag] = true
+				tags[tag]++
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].entry.doc.CreatedAt.After(hits[j].entry.doc.CreatedAt)
+	})
+
+	results.Total = len(hits)
+	results.Facets.Categories = facetCounts(categories, 0)
+	results.Facets.Tags = facetCounts(tags, maxTagFacets)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultHits
+	}
+	if q.Offset >= len(hits) {
+		return results, nil
+	}
+	hits = hits[q.Offset:]
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for _, h := range hits {
+		results.Hits = append(results.Hits, Hit{
+			ID:      h.entry.doc.ID,
+			Score:   math.Round(h.score*1000) / 1000,
+			Title:   highlight(h.entry.doc.Title, hl),
+			Snippet: snippet(h.entry.doc.Content, hl),
+		})
+	}
+	return results, nil
+}
+
+// matchClause scores the documents matching one clause and records what to highlight.
+func (ix *MemoryIndex) matchClause(c clause, hl *highlighter) map[string]float64 {
+	scores := make(map[string]float64)
+	switch {
+	case c.prefix:
+		hl.prefixes = append(hl.prefixes, c.terms[0])
+		// A document matching several expansions scores as its best one.
+		for _, term := range ix.expand(c.terms[0]) {
+			for id, s := range ix.termScores(term) {
+				if s > scores[id] {
+					scores[id] = s
+				}
+			}
+		}
+	case c.phrase():
+		for _, term := range c.terms {
+			hl.terms[term] = true
+		}
+		ix.phraseScores(c.terms, scores)
+	default:
+		hl.terms[c.terms[0]] = true
+		scores = ix.termScores(c.terms[0])
+	}
+	return scores
+}
+
+func (ix *MemoryIndex) termScores(term string) map[string]float64 {
+	docs := ix.postings[term]
+	idf := ix.idf(len(docs))
+	scores := make(map[string]float64, len(docs))
+	for id, p := range docs {
+		var tf [numFields]int
+		for f := range p {
+			tf[f] = len(p[f])
+		}
+		scores[id] = ix.bm25(idf, ix.docs[id], tf)
+	}
+	return scores
+}
+
+// phraseScores scores documents in which the terms occur next to each other in one field,
+// treating the phrase as a single term whose rarity is that of its terms combined.
+func (ix *MemoryIndex) phraseScores(terms []string, scores map[string]float64) {
+	var idf float64
+	for _, term := range terms {
+		idf += ix.idf(len(ix.postings[term]))
+	}
+
+	for id, first := range ix.postings[terms[0]] {
+		rest := make([]*positions, 0, len(terms)-1)
+		for _, term := range terms[1:] {
+			p, ok := ix.postings[term][id]
+			if !ok {
+				break
+			}
+			rest = append(rest, p)
+		}
+		if len(rest) != len(terms)-1 {
+			continue
+		}
+
+		var tf [numFields]int
+		found := false
+		for f := range first {
+			for _, start := range first[f] {
+				if followedBy(rest, field(f), start) {
+					tf[f]++
+					found = true
+				}
+			}
+		}
+		if found {
+			scores[id] = ix.bm25(idf, ix.docs[id], tf)
+		}
+	}
+}
+
+func followedBy(rest []*positions, f field, start int) bool {
+	for k, p := range rest {
+		want := start + k + 1
+		i := sort.SearchInts(p[f], want)
+		if i == len(p[f]) || p[f][i] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// expand returns the indexed terms starting with prefix, the most common first.
+func (ix *MemoryIndex) expand(prefix string) []string {
+	var terms []string
+	for term := range ix.postings {
+		if strings.HasPrefix(term, prefix) {
+			terms = append(terms, term)
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		di, dj := len(ix.postings[terms[i]]), len(ix.postings[terms[j]])
+		if di != dj {
+			return di > dj
+		}
+		return terms[i] < terms[j]
+	})
+	if len(terms) > maxPrefixExpansions {
+		terms = terms[:maxPrefixExpansions]
+	}
+	return terms
+}
+
+func (ix *MemoryIndex) idf(docFreq int) float64 {
+	n := float64(len(ix.docs))
+	df := float64(docFreq)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// bm25 combines the per-field term frequencies, each weighted and normalised by the field's
+// length relative to its average, and saturates the result as BM25 does.
+func (ix *MemoryIndex) bm25(idf float64, e *entry, tf [numFields]int) float64 {
+	n := float64(len(ix.docs))
+	var weighted float64
+	for f := range tf {
+		if tf[f] == 0 {
+			continue
+		}
+		norm := 1.0
+		if avg := float64(ix.totalLen[f]) / n; avg > 0 {
+			norm = 1 - bm25B + bm25B*float64(e.lengths[f])/avg
+		}
+		weighted += fieldWeights[f] * float64(tf[f]) / norm
+	}
+	return idf * weighted * (bm25K1 + 1) / (weighted + bm25K1)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// facetCounts sorts counts by frequency, keeping at most max of them (all when max is 0).
+func facetCounts(counts map[string]int, max int) []FacetCount {
+	facets := make([]FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	if max > 0 && len(facets) > max {
+		facets = facets[:max]
+	}
+	return facets
+}
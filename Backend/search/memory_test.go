@@ -0,0 +1,238 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newTestIndex(t *testing.T, docs ...Document) *MemoryIndex {
+	t.Helper()
+	ix := NewMemoryIndex()
+	for _, doc := range docs {
+		if err := ix.Upsert(doc); err != nil {
+			t.Fatalf("Upsert(%s): %v", doc.ID, err)
+		}
+	}
+	return ix
+}
+
+func hitIDs(t *testing.T, ix *MemoryIndex, text string) []string {
+	t.Helper()
+	res, err := ix.Search(Query{Text: text})
+	if err != nil {
+		t.Fatalf("Search(%q): %v", text, err)
+	}
+	ids := []string{}
+	for _, h := range res.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want []clause
+	}{
+		{"", nil},
+		{"Go", []clause{{terms: []string{"go"}}}},
+		{`"Quick Brown" fox`, []clause{{terms: []string{"quick", "brown"}}, {terms: []string{"fox"}}}},
+		{`"unterminated phrase`, []clause{{terms: []string{"unterminated", "phrase"}}}},
+		{"prog*", []clause{{terms: []string{"prog"}, prefix: true}}},
+		{"p*", []clause{{terms: []string{"p"}}}},
+		{"e-mail", []clause{{terms: []string{"e", "mail"}}}},
+		{`"" *`, nil},
+	}
+	for _, tt := range tests {
+		if got := parseQuery(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQuery(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	now := time.Now()
+	ix := newTestIndex(t,
+		Document{ID: "body", Title: "Weekend notes", Content: "Some words about kubernetes.", Tags: []string{"notes"}, CreatedAt: now},
+		Document{ID: "title", Title: "Kubernetes in practice", Content: "Running clusters for teams.", Tags: []string{"ops"}, CreatedAt: now},
+		Document{ID: "tag", Title: "Cluster upgrades", Content: "Notes from an upgrade.", Tags: []string{"kubernetes"}, CreatedAt: now},
+		Document{ID: "none", Title: "Baking bread", Content: "Flour, water and salt.", Tags: []string{"food"}, CreatedAt: now},
+	)
+
+	if got, want := hitIDs(t, ix, "kubernetes"), []string{"title", "tag", "body"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hits = %v, want %v (title before tags before content)", got, want)
+	}
+	// Every term must match
+	if got := hitIDs(t, ix, "kubernetes bread"); len(got) != 0 {
+		t.Errorf("hits = %v, want none", got)
+	}
+}
+
+func TestSearchRankingTies(t *testing.T) {
+	now := time.Now()
+	ix := newTestIndex(t,
+		Document{ID: "older", Title: "Rust", CreatedAt: now.Add(-time.Hour)},
+		Document{ID: "newer", Title: "Rust", CreatedAt: now},
+	)
+	if got, want := hitIDs(t, ix, "rust"), []string{"newer", "older"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hits = %v, want %v (newest first on equal scores)", got, want)
+	}
+}
+
+func TestSearchPhrase(t *testing.T) {
+	ix := newTestIndex(t,
+		Document{ID: "adjacent", Content: "the quick brown fox jumps"},
+		Document{ID: "apart", Content: "brown dogs are quick to follow"},
+		Document{ID: "reversed", Content: "a brown quick fox"},
+		Document{ID: "tags", Tags: []string{"quick", "brown"}},
+	)
+
+	if got, want := hitIDs(t, ix, `"quick brown"`), []string{"adjacent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("phrase hits = %v, want %v", got, want)
+	}
+	if got := hitIDs(t, ix, "quick brown"); len(got) != 4 {
+		t.Errorf("term hits = %v, want all four documents", got)
+	}
+
+	res, err := ix.Search(Query{Text: `"quick brown"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "the <mark>quick</mark> <mark>brown</mark> fox jumps"; res.Hits[0].Snippet != want {
+		t.Errorf("snippet = %q, want %q", res.Hits[0].Snippet, want)
+	}
+}
+
+func TestSearchPrefix(t *testing.T) {
+	ix := newTestIndex(t,
+		Document{ID: "programming", Title: "Programming languages"},
+		Document{ID: "progress", Title: "Progress report"},
+		Document{ID: "program", Title: "A program"},
+		Document{ID: "other", Title: "Project plans"},
+	)
+
+	got := hitIDs(t, ix, "prog*")
+	if len(got) != 3 || strings.Contains(strings.Join(got, ","), "other") {
+		t.Errorf("prefix hits = %v, want programming, progress and program", got)
+	}
+	// A one-letter prefix is searched as a plain term rather than expanded
+	if got := hitIDs(t, ix, "p*"); len(got) != 0 {
+		t.Errorf("hits for p* = %v, want none", got)
+	}
+
+	res, err := ix.Search(Query{Text: "prog*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range res.Hits {
+		if !strings.Contains(h.Title, "<mark>Prog") && !strings.Contains(h.Title, "<mark>prog") {
+			t.Errorf("title %q does not mark the prefix match", h.Title)
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	ix := newTestIndex(t,
+		Document{ID: "a", Title: "Go tips", Category: "Programming", Tags: []string{"go", "tips"}},
+		Document{ID: "b", Title: "Go travel", Category: "Travel", Tags: []string{"trips"}},
+	)
+
+	res, err := ix.Search(Query{Text: "go", Category: "programming"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Hits[0].ID != "a" {
+		t.Errorf("category filter returned %+v", res.Hits)
+	}
+	res, err = ix.Search(Query{Text: "go", Tag: "TRIPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Hits[0].ID != "b" {
+		t.Errorf("tag filter returned %+v", res.Hits)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	ix := newTestIndex(t,
+		Document{ID: "a", Title: "Distributed systems", Content: "consensus and replication"},
+		Document{ID: "b", Title: "Systems thinking", Content: "feedback loops"},
+	)
+
+	if err := ix.Remove("a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if got := hitIDs(t, ix, "consensus"); len(got) != 0 {
+		t.Errorf("removed document still found: %v", got)
+	}
+	if got, want := hitIDs(t, ix, "systems"), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hits = %v, want %v", got, want)
+	}
+	if _, ok := ix.postings["consensus"]; ok {
+		t.Error("terms only the removed document had are still indexed")
+	}
+	// Removing an unknown document is not an error
+	if err := ix.Remove("missing"); err != nil {
+		t.Errorf("Remove(missing): %v", err)
+	}
+
+	// Replacing a document drops its old terms, and the field lengths match a fresh index
+	if err := ix.Upsert(Document{ID: "b", Title: "Gardening"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hitIDs(t, ix, "feedback"); len(got) != 0 {
+		t.Errorf("replaced document found by its old content: %v", got)
+	}
+	fresh := newTestIndex(t, Document{ID: "b", Title: "Gardening"})
+	if ix.totalLen != fresh.totalLen || len(ix.postings) != len(fresh.postings) {
+		t.Errorf("index after remove and replace = %v / %d terms, want %v / %d", ix.totalLen, len(ix.postings), fresh.totalLen, len(fresh.postings))
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	words := func(word string, n int) string {
+		return strings.TrimSpace(strings.Repeat(word+" ", n))
+	}
+	tests := []struct {
+		name        string
+		content     string
+		query       string
+		want        string // a substring the snippet must contain
+		lead, trail bool   // whether it should start or end with an ellipsis
+	}{
+		{"short text", "Über naïve café", "café", "Über naïve <mark>café</mark>", false, false},
+		{"match at the end", words("日本語", 50) + " ümlaut!", "ümlaut", "<mark>ümlaut</mark>!", true, false},
+		{"match at the start", "Ελληνικά " + words("κείμενο", 50), "ελληνικά", "<mark>Ελληνικά</mark>", false, true},
+		{"match in the middle", words("añejo", 40) + " emoji🙂target " + words("ñandú", 40), "target", "<mark>target</mark>", true, true},
+		{"escaped", `<b>"x"</b> & ` + words("ß", 40), "x", "&lt;b&gt;&#34;<mark>x</mark>&#34;&lt;/b&gt; &amp;", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := newTestIndex(t, Document{ID: "doc", Content: tt.content})
+			res, err := ix.Search(Query{Text: tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Hits) != 1 {
+				t.Fatalf("got %d hits, want 1", len(res.Hits))
+			}
+			got := res.Hits[0].Snippet
+			if !utf8.ValidString(got) {
+				t.Fatalf("snippet %q is not valid UTF-8", got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("snippet %q does not contain %q", got, tt.want)
+			}
+			if strings.HasPrefix(got, "… ") != tt.lead || strings.HasSuffix(got, " …") != tt.trail {
+				t.Errorf("snippet %q: leading ellipsis %v, trailing %v", got, tt.lead, tt.trail)
+			}
+			words := strings.Fields(strings.Trim(got, "… "))
+			if len(words) > snippetWords+1 {
+				t.Errorf("snippet has %d words, want at most %d", len(words), snippetWords)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package search provides full-text search over blogs. The handlers talk to an Index, so the
+// in-process MemoryIndex can later be swapped for an external engine.
+package search
+
+import "time"
+
+// Document is the searchable part of a blog.
+type Document struct {
+	ID        string
+	Title     string
+	Content   string
+	Tags      []string
+	Category  string
+	CreatedAt time.Time
+}
+
+// Query is a search request. Text supports bare terms, "quoted phrases" and prefix* terms;
+// a document must match every one of them. Category and Tag narrow the results to exact values.
+type Query struct {
+	Text     string
+	Category string
+	Tag      string
+	Limit    int
+	Offset   int
+}
+
+// Hit is one matching document. Title and Snippet are HTML-escaped, with matches wrapped in <mark>.
+type Hit struct {
+	ID      string  `json:"id"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+}
+
+// FacetCount is how many matching documents share a category or tag.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets summarise all matching documents, not only the returned page.
+type Facets struct {
+	Categories []FacetCount `json:"categories"`
+	Tags       []FacetCount `json:"tags"`
+}
+
+// Results is one page of hits, best first.
+type Results struct {
+	Total  int    `json:"total"`
+	Hits   []Hit  `json:"hits"`
+	Facets Facets `json:"facets"`
+}
+
+// Index stores documents and answers queries. Implementations must be safe for concurrent use.
+type Index interface {
+	// Upsert adds the document, replacing any earlier version with the same ID.
+	Upsert(doc Document) error
+	Remove(id string) error
+	Search(q Query) (*Results, error)
+}
+
+// Default is the index used by the handlers and kept up to date by the db package.
+var Default Index = NewMemoryIndex()
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// snippetWords is how many words of the content a snippet shows.
+const snippetWords = 30
+
+// highlighter decides which words of a hit to mark: the query's terms and anything
+// starting with one of its prefixes.
+type highlighter struct {
+	terms    map[string]bool
+	prefixes []string
+}
+
+func (h *highlighter) matches(term string) bool {
+	if h.terms[term] {
+		return true
+	}
+	for _, p := range h.prefixes {
+		if strings.HasPrefix(term, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight escapes text and marks every matching word in it.
+func highlight(text string, h *highlighter) string {
+	return mark(text, tokenize(text), 0, len(text), h)
+}
+
+// snippet picks the window of snippetWords words holding the most matches, falling back to
+// the start of the text, and marks the matches in it.
+func snippet(text string, h *highlighter) string {
+	tokens := tokenize(text)
+	if len(tokens) <= snippetWords {
+		return collapseSpace(mark(text, tokens, 0, len(text), h))
+	}
+
+	best, bestCount, count := 0, 0, 0
+	for i, t := range tokens {
+		if h.matches(t.term) {
+			count++
+		}
+		if i >= snippetWords && h.matches(tokens[i-snippetWords].term) {
+			count--
+		}
+		if start := i - snippetWords + 1; start >= 0 && count > bestCount {
+			best, bestCount = start, count
+		}
+	}
+
+	// A window at either end of the text keeps what comes before its first or after its last word
+	window := tokens[best : best+snippetWords]
+	from, to := window[0].start, window[len(window)-1].end
+	if best == 0 {
+		from = 0
+	}
+	if best+snippetWords == len(tokens) {
+		to = len(text)
+	}
+	out := mark(text, window, from, to, h)
+	if from > 0 {
+		out = "… " + out
+	}
+	if to < len(text) {
+		out += " …"
+	}
+	return collapseSpace(out)
+}
+
+// mark escapes text[from:to], wrapping the matching tokens in <mark>.
+func mark(text string, tokens []token, from, to int, h *highlighter) string {
+	var b strings.Builder
+	pos := from
+	for _, t := range tokens {
+		if !h.matches(t.term) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.start:t.end]))
+		b.WriteString("</mark>")
+		pos = t.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a lowercased word and its byte range in the original text.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into runs of letters and digits.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// clause is one condition of a parsed query: a single term, a prefix, or a phrase of several terms.
+type clause struct {
+	terms  []string
+	prefix bool
+}
+
+func (c clause) phrase() bool {
+	return len(c.terms) > 1
+}
+
+// parseQuery turns query text into clauses. Quoted text is a phrase, a word ending in * is a prefix,
+// and a word that tokenizes into several terms (such as "e-mail") is treated as a phrase.
+func parseQuery(text string) []clause {
+	var clauses []clause
+	for len(text) > 0 {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			break
+		}
+
+		if text[0] == '"' {
+			end := strings.IndexByte(text[1:], '"')
+			var quoted string
+			if end < 0 {
+				quoted, text = text[1:], ""
+			} else {
+				quoted, text = text[1:end+1], text[end+2:]
+			}
+			if terms := termsOf(quoted); len(terms) > 0 {
+				clauses = append(clauses, clause{terms: terms})
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			end = len(text)
+		}
+		word := text[:end]
+		text = text[end:]
+
+		prefix := strings.HasSuffix(word, "*")
+		terms := termsOf(strings.TrimRight(word, "*"))
+		switch {
+		case len(terms) == 0:
+		case prefix && len(terms) == 1 && utf8.RuneCountInString(terms[0]) >= minPrefixLength:
+			clauses = append(clauses, clause{terms: terms, prefix: true})
+		default:
+			clauses = append(clauses, clause{terms: terms})
+		}
+	}
+	return clauses
+}
+
+// minPrefixLength keeps very short prefixes from expanding to most of the vocabulary.
+const minPrefixLength = 2
+
+func termsOf(text string) []string {
+	tokens := tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.term
+	}
+	return terms
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-west-1"
+	testBucket    = "uploads"
+)
+
+// fakeS3 stands in for an S3-compatible store. It checks each request's Signature Version 4
+// independently of S3Store and keeps objects in memory.
+type fakeS3 struct {
+	t         *testing.T
+	pathStyle bool
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+var authPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([^,]+), Signature=([0-9a-f]{64})$`)
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if msg := f.checkSignature(r, body); msg != "" {
+		f.t.Logf("rejected %s %s: %s", r.Method, r.URL.Path, msg)
+		http.Error(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if f.pathStyle {
+		bucket, rest, _ := strings.Cut(key, "/")
+		if bucket != testBucket {
+			http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+			return
+		}
+		key = rest
+	} else if !strings.HasPrefix(r.Host, testBucket+".") {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) stored() map[string]fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	objects := make(map[string]fakeObject, len(f.objects))
+	for key, obj := range f.objects {
+		objects[key] = obj
+	}
+	return objects
+}
+
+// checkSignature returns why the request's signature is not valid, or "" if it is.
+func (f *fakeS3) checkSignature(r *http.Request, body []byte) string {
+	m := authPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return "malformed Authorization header: " + r.Header.Get("Authorization")
+	}
+	accessKey, date, region, signedHeaders, signature := m[1], m[2], m[3], m[4], m[5]
+	if accessKey != testAccessKey || region != testRegion {
+		return "wrong credential scope"
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, date) {
+		return "bad X-Amz-Date"
+	}
+	if d := time.Since(signedAt); d > 15*time.Minute || d < -15*time.Minute {
+		return "request too old"
+	}
+	payloadHash := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return "payload hash does not match the body"
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	if !strings.Contains(";"+signedHeaders+";", ";host;") {
+		return "host is not signed"
+	}
+	canonicalRequest := r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" +
+		canonicalHeaders.String() + "\n" + signedHeaders + "\n" + r.Header.Get("X-Amz-Content-Sha256")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + date + "/" + region + "/s3/aws4_request\n" +
+		hex.EncodeToString(requestHash[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	signingKey := mac(mac(mac(mac([]byte("AWS4"+testSecretKey), date), region), "s3"), "aws4_request")
+	if want := hex.EncodeToString(mac(signingKey, stringToSign)); !hmac.Equal([]byte(want), []byte(signature)) {
+		return "signature does not match"
+	}
+	return ""
+}
+
+// newTestS3 starts a fake store and returns an S3Store pointing at it. Virtual-hosted requests
+// go to bucket.<endpoint host>, so the client dials the fake server whatever the host name.
+func newTestS3(t *testing.T, pathStyle bool) (*S3Store, *fakeS3) {
+	fake := &fakeS3{t: t, pathStyle: pathStyle, objects: make(map[string]fakeObject)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	addr := srv.Listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	return &S3Store{
+		Endpoint:  srv.URL,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: pathStyle,
+		Client:    client,
+	}, fake
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	for _, pathStyle := range []bool{true, false} {
+		name := "virtual-hosted"
+		if pathStyle {
+			name = "path-style"
+		}
+		t.Run(name, func(t *testing.T) {
+			store, fake := newTestS3(t, pathStyle)
+			ctx := context.Background()
+			key := "0123abcd_w320.webp"
+
+			if err := store.Put(ctx, key, "image/webp", []byte("RIFF data")); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if obj := fake.stored()[key]; string(obj.data) != "RIFF data" || obj.contentType != "image/webp" {
+				t.Fatalf("stored %q as %q", obj.data, obj.contentType)
+			}
+
+			data, contentType, err := store.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if string(data) != "RIFF data" || contentType != "image/webp" {
+				t.Errorf("Get = %q, %q", data, contentType)
+			}
+
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get after Delete: error = %v, want ErrNotFound", err)
+			}
+			// Deleting what is already gone succeeds
+			if err := store.Delete(ctx, key); err != nil {
+				t.Errorf("second Delete: %v", err)
+			}
+		})
+	}
+}
+
+func TestS3StoreRejectsBadSignature(t *testing.T) {
+	store, fake := newTestS3(t, true)
+	store.SecretKey = "not the secret"
+
+	err := store.Put(context.Background(), "abc.png", "image/png", []byte("png"))
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put with a wrong secret: error = %v, want a 403", err)
+	}
+	if len(fake.stored()) != 0 {
+		t.Error("the object was stored anyway")
+	}
+	if _, _, err := store.Get(context.Background(), "abc.png"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get with a wrong secret: error = %v, want a signature failure", err)
+	}
+}
+
+func TestS3StoreInvalidKeys(t *testing.T) {
+	store, fake := newTestS3(t, true)
+	ctx := context.Background()
+	for _, key := range []string{"../etc/passwd", "a/b.png", "", "UPPER.png"} {
+		if err := store.Put(ctx, key, "image/png", []byte("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) error = %v, want ErrNotFound", key, err)
+		}
+	}
+	if len(fake.stored()) != 0 {
+		t.Error("an object was stored under an invalid key")
+	}
+}
@@ -0,0 +1,78 @@
+// Package storage keeps uploaded files in a BlobStore: a directory on local disk or a bucket of an
+// S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound is returned when no blob is stored under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are not safe to use as file or object names.
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore stores immutable blobs under flat keys. Implementations must be safe for concurrent use.
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get returns the blob's content and content type, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	// Delete removes a blob; deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Default is the store used by the handlers; main replaces it with the one configured in the environment.
+var Default BlobStore = &LocalStore{Dir: filepath.Join(os.TempDir(), "insight-hub-uploads")}
+
+// FromEnv builds the store selected by BLOB_STORE ("local" or "s3", default "local").
+func FromEnv() (BlobStore, error) {
+	switch os.Getenv("BLOB_STORE") {
+	case "", "local":
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "insight-hub-uploads")
+		}
+		return &LocalStore{Dir: dir}, nil
+	case "s3":
+		s := &S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+		}
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required when BLOB_STORE=s3")
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", os.Getenv("BLOB_STORE"))
+	}
+}
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*(\.[a-z0-9]+)?$`)
+
+// ValidKey reports whether key can name a blob: lower-case letters, digits, '-' and '_' with an
+// optional extension, so it can never point outside the store.
+func ValidKey(key string) bool {
+	return len(key) <= 200 && keyPattern.MatchString(key)
+}
+
+// ContentType guesses a blob's content type from the extension of its key.
+func ContentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
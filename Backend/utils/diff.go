@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kinds of DiffOp.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffOp is one run of a diff: text present in both versions, only in the new one, or only in the old one.
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the work of a diff. Larger inputs are reported as a whole replacement.
+const maxDiffCells = 4_000_000
+
+// DiffLines compares a and b line by line.
+func DiffLines(a, b string) []DiffOp {
+	return diffTokens(strings.SplitAfter(a, "\n"), strings.SplitAfter(b, "\n"))
+}
+
+// DiffWords compares a and b word by word, treating each run of whitespace as a token of its own
+// so that the ops concatenate back to the original texts.
+func DiffWords(a, b string) []DiffOp {
+	return diffTokens(splitWords(a), splitWords(b))
+}
+
+func splitWords(s string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != isSpaceAt(s, start) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+func isSpaceAt(s string, i int) bool {
+	for _, r := range s[i:] {
+		return unicode.IsSpace(r)
+	}
+	return false
+}
+
+// diffTokens finds a longest common subsequence of the tokens and reports the rest as
+// deletions and insertions, merging neighbouring tokens with the same op.
+func diffTokens(a, b []string) []DiffOp {
+	// Leave out the common prefix and suffix, which are usually most of an edit.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	add := func(op string, tokens ...string) {
+		text := strings.Join(tokens, "")
+		if text == "" {
+			return
+		}
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, DiffOp{Op: op, Text: text})
+	}
+
+	add(DiffEqual, a[:prefix]...)
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		add(DiffDelete, midA...)
+		add(DiffInsert, midB...)
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of midA[i:] and midB[j:].
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(midA) && j < len(midB) {
+			switch {
+			case midA[i] == midB[j]:
+				add(DiffEqual, midA[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				add(DiffDelete, midA[i])
+				i++
+			default:
+				add(DiffInsert, midB[j])
+				j++
+			}
+		}
+		add(DiffDelete, midA[i:]...)
+		add(DiffInsert, midB[j:]...)
+	}
+	add(DiffEqual, a[len(a)-suffix:]...)
+
+	if ops == nil {
+		ops = []DiffOp{}
+	}
+	return ops
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SessionPolicy controls how long access and refresh tokens live and how their cookies are issued.
+type SessionPolicy struct {
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+	SecureCookies bool
+}
+
+// Session is the policy in effect; main replaces it with the one loaded from the environment.
+var Session = SessionPolicy{
+	AccessTTL:  15 * time.Minute,
+	RefreshTTL: 30 * 24 * time.Hour,
+}
+
+// LoadSessionPolicy reads SESSION_ACCESS_TTL, SESSION_REFRESH_TTL (Go durations, e.g. "15m", "720h")
+// and COOKIE_SECURE from the environment, falling back to the defaults in Session.
+func LoadSessionPolicy() (SessionPolicy, error) {
+	policy := Session
+
+	if v := os.Getenv("SESSION_ACCESS_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid SESSION_ACCESS_TTL %q", v)
+		}
+		policy.AccessTTL = d
+	}
+	if v := os.Getenv("SESSION_REFRESH_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid SESSION_REFRESH_TTL %q", v)
+		}
+		policy.RefreshTTL = d
+	}
+	if policy.RefreshTTL < policy.AccessTTL {
+		return policy, fmt.Errorf("SESSION_REFRESH_TTL must not be shorter than SESSION_ACCESS_TTL")
+	}
+	if v := os.Getenv("COOKIE_SECURE"); v != "" {
+		secure, err := strconv.ParseBool(v)
+		if err != nil {
+			return policy, fmt.Errorf("invalid COOKIE_SECURE %q", v)
+		}
+		policy.SecureCookies = secure
+	}
+
+	return policy, nil
+}
+
+// RandomToken returns a URL-safe random string built from n bytes of entropy.
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 of a token, which is what gets stored instead of the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
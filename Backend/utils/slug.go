@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify lowercases s and joins its letters and digits with single dashes, keeping at most
+// maxLen bytes. It returns "" when s has no letters or digits.
+func Slugify(s string, maxLen int) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if sb.Len()+len(string(r)) > maxLen {
+				break
+			}
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			if sb.Len()+1 > maxLen {
+				break
+			}
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(sb.String(), "-")
+}
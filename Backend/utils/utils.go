@@ -5,14 +5,23 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 )
 
-// GenerateJWT creates a new JSON Web Token for an authenticated user.
-func GenerateJWT(userID, email string) (string, error) {
+// TokenClaims are the identity claims carried by an access token.
+type TokenClaims struct {
+	UserID    string
+	Email     string
+	SessionID string
+}
+
+// GenerateJWT creates a new JSON Web Token for an authenticated user bound to a session.
+// The token lives for the access TTL of the current session policy.
+func GenerateJWT(userID, email, sessionID string) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		return "", fmt.Errorf("JWT_SECRET not set in environment")
@@ -21,15 +30,45 @@ func GenerateJWT(userID, email string) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"email":   email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 24 hour expiry
+		"sid":     sessionID,
+		"exp":     time.Now().Add(Session.AccessTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
 
+// ParseJWT verifies a token produced by GenerateJWT and returns its claims.
+func ParseJWT(tokenString string) (*TokenClaims, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET not set in environment")
+	}
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	userID, _ := claims["user_id"].(string)
+	sessionID, _ := claims["sid"].(string)
+	if userID == "" || sessionID == "" {
+		return nil, fmt.Errorf("token is missing user_id or sid claim")
+	}
+	email, _ := claims["email"].(string)
+
+	return &TokenClaims{UserID: userID, Email: email, SessionID: sessionID}, nil
+}
+
 type AppConfig struct {
 	ServerPort int
+	Session    SessionPolicy
 }
 
 func LoadConfig() (*AppConfig, error) {
@@ -38,25 +77,62 @@ func LoadConfig() (*AppConfig, error) {
 	}
 
 	ServerPortStr := os.Getenv("SERVER_PORT")
-	ServerPort , err := strconv.Atoi(ServerPortStr)
+	ServerPort, err := strconv.Atoi(ServerPortStr)
 	if err != nil || ServerPort <= 0 {
-		return nil , fmt.Errorf("invalid or missing SERVER_PORT in environment")
+		return nil, fmt.Errorf("invalid or missing SERVER_PORT in environment")
+	}
+
+	session, err := LoadSessionPolicy()
+	if err != nil {
+		return nil, err
 	}
 
 	return &AppConfig{
-		ServerPort : ServerPort,
-	} , nil
+		ServerPort: ServerPort,
+		Session:    session,
+	}, nil
+}
+
+// BaseURL returns the public URL of the API used when building links sent to users,
+// taken from APP_BASE_URL and defaulting to the local server.
+func BaseURL() string {
+	if url := os.Getenv("APP_BASE_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:" + os.Getenv("SERVER_PORT")
 }
 
-// NewAuthCookie creates an HTTP cookie for auth with a 10-minute expiry.
+// NewAuthCookie creates the HTTP cookie carrying the access token; it expires with the token.
 func NewAuthCookie(value string) http.Cookie {
 	return http.Cookie{
 		Name:     "auth_token",
 		Value:    value,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   false,
+		Secure:   Session.SecureCookies,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(10 * time.Minute),
+		Expires:  time.Now().Add(Session.AccessTTL),
 	}
 }
+
+// NewRefreshCookie creates the HTTP cookie carrying the refresh token.
+// It is strict same-site so it is never sent along with cross-site requests.
+func NewRefreshCookie(value string) http.Cookie {
+	return http.Cookie{
+		Name:     "refresh_token",
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   Session.SecureCookies,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(Session.RefreshTTL),
+	}
+}
+
+// ExpireCookie turns a cookie into one that tells the browser to delete it.
+func ExpireCookie(cookie http.Cookie) http.Cookie {
+	cookie.Value = ""
+	cookie.MaxAge = -1
+	cookie.Expires = time.Now().Add(-1 * time.Hour)
+	return cookie
+}
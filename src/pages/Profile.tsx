@@ -28,7 +28,7 @@ const Profile = () => {
     const fetchUserData = async () => {
       try {
         const [blogsRes, userRes, networkRes] = await Promise.all([
-          fetch(`${API_BASE_URL}/blogs`),
+          fetch(`${API_BASE_URL}/blogs?author=${user.id}&limit=100`),
           fetch(`${API_BASE_URL}/user/id/${user.id}`, { credentials: "include" }),
           fetch(`${API_BASE_URL}/follow/network?user_id=${user.id}`, { credentials: "include" }),
         ]);